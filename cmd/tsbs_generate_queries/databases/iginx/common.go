@@ -1,16 +1,155 @@
 package iginx
 
 import (
-	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iot"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iot"
+
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
 	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+	"github.com/timescale/tsbs/pkg/targets/iginx/udfs"
 )
 
 // BaseGenerator contains settings specific for Iginx
 type BaseGenerator struct {
+	// PathTemplate is the template of the series paths the data was
+	// loaded with, the default template of the use case if empty
+	PathTemplate string
+	// Tagged is whether the data was loaded as tagged series, the tags
+	// that are not levels of the path template are filtered with WITH
+	// clauses
+	Tagged bool
+}
+
+// pathTemplate parses the path template of the generator, validated against
+// the string tags of the use case
+func (g *BaseGenerator) pathTemplate(tagKeys []string) (*paths.Template, error) {
+	tagTypes := make([]string, len(tagKeys))
+	for i := range tagTypes {
+		tagTypes[i] = "string"
+	}
+
+	raw := g.PathTemplate
+	parse := paths.Parse
+	if g.Tagged {
+		parse = paths.ParseTagged
+	}
+	if raw == "" && g.Tagged {
+		raw = paths.TaggedDefault
+	} else if raw == "" {
+		raw = paths.Default(tagKeys, tagTypes)
+	}
+	tmpl, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	err = tmpl.Validate(&common.GeneratedDataHeaders{TagKeys: tagKeys, TagTypes: tagTypes})
+	return tmpl, err
+}
+
+// splitCommonPrefix splits device paths into the levels they all start with,
+// to be used as FROM clause, and the rest of each path. A path equal to the
+// prefix has an empty rest.
+func splitCommonPrefix(devices []string) (string, []string, error) {
+	levels := make([][]string, len(devices))
+	for i, device := range devices {
+		levels[i] = strings.Split(device, ".")
+	}
+
+	n := len(levels[0])
+	for _, l := range levels[1:] {
+		if len(l) < n {
+			n = len(l)
+		}
+		for j := 0; j < n; j++ {
+			if l[j] != levels[0][j] {
+				n = j
+				break
+			}
+		}
+	}
+	if n == 0 {
+		return "", nil, fmt.Errorf("devices %s have no common path prefix", strings.Join(devices, ", "))
+	}
+
+	rests := make([]string, len(devices))
+	for i, l := range levels {
+		rests[i] = strings.Join(l[n:], ".")
+	}
+	return strings.Join(levels[0][:n], "."), rests, nil
+}
+
+// selection holds what a query reads from the series of a measurement: the
+// FROM clause, the path of each selected device relative to it, and the
+// WITH clause filtering on the tags of tagged series, empty if none
+type selection struct {
+	from    string
+	devices []string
+	with    string
+}
+
+// newSelection selects the devices of a measurement having any of the given
+// tags, or all of them if no tags are given. Each filter either becomes the
+// path of a device, or a WITH condition for the tags that are not levels of
+// the template.
+func newSelection(tmpl *paths.Template, measurement string, filters ...map[string]string) (*selection, error) {
+	if len(filters) == 0 {
+		return &selection{from: tmpl.DevicePattern(measurement, nil), devices: []string{""}}, nil
+	}
+
+	patterns := make([]string, len(filters))
+	var conditions []string
+	for i, tags := range filters {
+		patterns[i] = tmpl.DevicePattern(measurement, tags)
+		if seriesTags := tmpl.SeriesTags(tags); seriesTags != nil {
+			conditions = append(conditions, tagCondition(seriesTags))
+		}
+	}
+	from, devices, err := splitCommonPrefix(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &selection{from: from, devices: unique(devices)}
+	if conditions = unique(conditions); len(conditions) > 0 {
+		s.with = " WITH " + strings.Join(conditions, " OR ")
+	}
+	return s, nil
+}
+
+// tagCondition returns the tag expression matching all the given tags
+func tagCondition(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + tags[k]
+	}
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	return "(" + strings.Join(keys, " AND ") + ")"
+}
+
+// unique removes the duplicates of s, keeping the first occurrence
+func unique(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	u := s[:0]
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			u = append(u, v)
+		}
+	}
+	return u
 }
 
 // GenerateEmptyQuery returns an empty query.Iginx.
@@ -18,12 +157,14 @@ func (g *BaseGenerator) GenerateEmptyQuery() query.Query {
 	return query.NewIginx()
 }
 
-// fillInQuery fills the query struct with data.
+// fillInQuery fills the query struct with data, along with the UDFs the
+// query calls.
 func (g *BaseGenerator) fillInQuery(qi query.Query, humanLabel, humanDesc, sql string) {
 	q := qi.(*query.Iginx)
 	q.HumanLabel = []byte(humanLabel)
 	q.HumanDescription = []byte(humanDesc)
 	q.SqlQuery = []byte(sql)
+	q.UDFs = udfs.Called(sql)
 }
 
 // NewDevops creates a new devops use case query generator.
@@ -34,9 +175,15 @@ func (g *BaseGenerator) NewDevops(start, end time.Time, scale int) (utils.QueryG
 		return nil, err
 	}
 
+	tmpl, err := g.pathTemplate(devopsTagKeys())
+	if err != nil {
+		return nil, err
+	}
+
 	devops := &Devops{
 		BaseGenerator: g,
 		Core:          core,
+		pathTemplate:  tmpl,
 	}
 
 	return devops, nil
@@ -50,9 +197,15 @@ func (g *BaseGenerator) NewIoT(start, end time.Time, scale int) (utils.QueryGene
 		return nil, err
 	}
 
+	tmpl, err := g.pathTemplate(iotTagKeys)
+	if err != nil {
+		return nil, err
+	}
+
 	iot := &IoT{
 		BaseGenerator: g,
 		Core:          core,
+		pathTemplate:  tmpl,
 	}
 
 	return iot, nil
@@ -0,0 +1,139 @@
+package iginx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+func TestBaseGeneratorPathTemplate(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	cases := []struct {
+		desc     string
+		template string
+		tagged   bool
+		want     []string
+	}{
+		{
+			desc: "default template",
+			want: []string{"SELECT level2 AS fleet, level4 AS model,", "FROM diagnostics.*.*.*.*.*)) WHERE level1 != 'unknown'"},
+		},
+		{
+			desc:     "custom template",
+			template: "{measurement}.{model}.{fleet}.{name}.{field}",
+			want:     []string{"SELECT level2 AS fleet, level1 AS model,", "FROM diagnostics.*.*.*)) WHERE level3 != 'unknown'"},
+		},
+		{
+			desc:   "tagged",
+			tagged: true,
+			want:   []string{"SELECT fleet, model,", "FROM diagnostics)) WHERE name != 'unknown'"},
+		},
+	}
+	for _, c := range cases {
+		g := &BaseGenerator{PathTemplate: c.template, Tagged: c.tagged}
+		qg, err := g.NewIoT(start, end, 10)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		q := g.GenerateEmptyQuery()
+		qg.(*IoT).AvgLoad(q)
+		got := string(q.(*query.Iginx).SqlQuery)
+		for _, want := range c.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: incorrect query: got %s want it to contain %s", c.desc, got, want)
+			}
+		}
+	}
+
+	g := &BaseGenerator{PathTemplate: "{measurement}.{hostname}.{field}"}
+	if _, err := g.NewIoT(start, end, 10); err == nil {
+		t.Errorf("expected error for template with tags the use case does not have")
+	}
+	if _, err := g.NewDevops(start, end, 10); err != nil {
+		t.Errorf("unexpected error for devops template: %v", err)
+	}
+}
+
+func TestNewSelection(t *testing.T) {
+	hosts := []map[string]string{{"hostname": "host_1"}, {"hostname": "host_2"}}
+	cases := []struct {
+		desc     string
+		template string
+		tagged   bool
+		filters  []map[string]string
+		want     selection
+	}{
+		{
+			desc:     "all devices",
+			template: "{measurement}.{hostname}.{field}",
+			want:     selection{from: "cpu.*", devices: []string{""}},
+		},
+		{
+			desc:     "path levels",
+			template: "{measurement}.{hostname}.{region}.{field}",
+			filters:  hosts,
+			want:     selection{from: "cpu", devices: []string{"host_1.*", "host_2.*"}},
+		},
+		{
+			desc:     "tagged",
+			template: paths.TaggedDefault,
+			tagged:   true,
+			filters:  hosts,
+			want:     selection{from: "cpu", devices: []string{""}, with: " WITH hostname=host_1 OR hostname=host_2"},
+		},
+		{
+			desc:     "tagged with path levels",
+			template: "{measurement}.{region}.{field}",
+			tagged:   true,
+			filters:  []map[string]string{{"hostname": "host_1", "region": "eu-west-1", "os": "Ubuntu16.10"}},
+			want:     selection{from: "cpu.eu_west_1", devices: []string{""}, with: " WITH (hostname=host_1 AND os=Ubuntu16_10)"},
+		},
+	}
+	for _, c := range cases {
+		parse := paths.Parse
+		if c.tagged {
+			parse = paths.ParseTagged
+		}
+		tmpl, err := parse(c.template)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		got, err := newSelection(tmpl, "cpu", c.filters...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		if !reflect.DeepEqual(*got, c.want) {
+			t.Errorf("%s: incorrect selection: got %+v want %+v", c.desc, *got, c.want)
+		}
+	}
+}
+
+func TestFillInQueryUDFs(t *testing.T) {
+	g := &BaseGenerator{}
+	cases := []struct {
+		sql  string
+		want []string
+	}{
+		{
+			sql:  "SELECT max(usage_user) FROM cpu.*",
+			want: nil,
+		},
+		{
+			sql:  "SELECT name, day, duration FROM (SELECT driving_sessions(*) FROM (SELECT avg(velocity) FROM readings))",
+			want: []string{"driving_sessions"},
+		},
+	}
+	for _, c := range cases {
+		q := g.GenerateEmptyQuery()
+		g.fillInQuery(q, "label", "description", c.sql)
+		if got := q.(*query.Iginx).UDFs; !reflect.DeepEqual(got, c.want) {
+			t.Errorf("incorrect UDFs of %s: got %v want %v", c.sql, got, c.want)
+		}
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	devopsdata "github.com/timescale/tsbs/pkg/data/usecases/devops"
 	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
 )
 
+const devopsCPUTable = "cpu"
+
 func panicIfErr(err error) {
 	if err != nil {
 		panic(err.Error())
@@ -19,24 +23,57 @@ func panicIfErr(err error) {
 type Devops struct {
 	*BaseGenerator
 	*devops.Core
+	pathTemplate *paths.Template
+}
+
+// devopsTagKeys returns the tags of the devops hosts, all of them strings
+func devopsTagKeys() []string {
+	keys := make([]string, len(devopsdata.MachineTagKeys))
+	for i, key := range devopsdata.MachineTagKeys {
+		keys[i] = string(key)
+	}
+	return keys
+}
+
+// selectHosts selects the cpu series of the given hosts, or of all the hosts
+// if none is given
+func (d *Devops) selectHosts(hosts []string) (*selection, error) {
+	filters := make([]map[string]string, len(hosts))
+	for i, host := range hosts {
+		filters[i] = map[string]string{"hostname": host}
+	}
+	return newSelection(d.pathTemplate, devopsCPUTable, filters...)
 }
 
 // getSelectAggClauses builds specified aggregate function clauses for
-// a set of column idents.
+// a set of metrics of each device, relative to the FROM clause. An empty
+// aggFunc selects the metrics as they are.
 //
 // For instance:
-//      max(cpu_time) AS max_cpu_time
-func (d *Devops) getSelectAggClauses(aggFunc string, idents []string) []string {
-	selectAggClauses := make([]string, len(idents))
-	for i, ident := range idents {
-		selectAggClauses[i] =
-			fmt.Sprintf("%[1]s(%[2]s) AS %[1]s_%[2]s", aggFunc, ident)
+//
+//	max(host_1.*.usage_user)
+func getSelectAggClauses(aggFunc string, devices, metrics []string) []string {
+	clauses := make([]string, 0, len(devices)*len(metrics))
+	for _, device := range devices {
+		for _, metric := range metrics {
+			series := metric
+			if device != "" {
+				series = device + "." + metric
+			}
+			if aggFunc != "" {
+				series = fmt.Sprintf("%s(%s)", aggFunc, series)
+			}
+			clauses = append(clauses, series)
+		}
 	}
-	return selectAggClauses
+	return clauses
 }
 
 // MaxAllCPU selects the MAX of all metrics under 'cpu' per hour for N random
-// hosts
+// hosts, e.g.
+//
+// SELECT max(usage_user), ..., max(usage_guest_nice) FROM cpu.host_1.*
+// GROUP [$HOUR_START, $HOUR_END) BY 1h
 //
 // Queries:
 // cpu-max-all-1
@@ -44,56 +81,25 @@ func (d *Devops) getSelectAggClauses(aggFunc string, idents []string) []string {
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int, duration time.Duration) {
 	interval := d.Interval.MustRandWindow(duration)
 	hosts, err := d.GetRandomHosts(nHosts)
-	metrics := devops.GetAllCPUMetrics()
 	panicIfErr(err)
+	sel, err := d.selectHosts(hosts)
+	panicIfErr(err)
+	selectClauses := getSelectAggClauses("max", sel.devices, devops.GetAllCPUMetrics())
 
-	sql := fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
+	sql := fmt.Sprintf("SELECT %s FROM %s%s GROUP [%d, %d) BY 1h",
+		strings.Join(selectClauses, ", "), sel.from, sel.with, interval.StartUnixNano(), interval.EndUnixNano())
 
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "max",
-				"tags": {
-            		"hostname": [
-              			"%s"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i],
-			strings.Join(hosts, "\", \""))
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
 	humanLabel := devops.GetMaxAllLabel("Iginx", nHosts)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
 
 // GroupByTimeAndPrimaryTag selects the AVG of metrics in the group `cpu` per device
-// per hour for a day
+// per hour for a day. IGinX aggregates each series on its own, so there is a
+// result per host without grouping by the hostname, e.g.
+//
+// SELECT avg(usage_user), ..., avg(usage_nice) FROM cpu.*
+// GROUP [$HOUR_START, $HOUR_END) BY 1h
 //
 // Queries:
 // double-groupby-1
@@ -103,45 +109,12 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	sel, err := d.selectHosts(nil)
+	panicIfErr(err)
+	selectClauses := getSelectAggClauses("avg", sel.devices, metrics)
 
-	sql := fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "avg",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i])
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
+	sql := fmt.Sprintf("SELECT %s FROM %s%s GROUP [%d, %d) BY 1h",
+		strings.Join(selectClauses, ", "), sel.from, sel.with, interval.StartUnixNano(), interval.EndUnixNano())
 
 	humanLabel := devops.GetDoubleGroupByLabel("Iginx", numMetrics)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
@@ -149,227 +122,77 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 }
 
 // GroupByOrderByLimit populates a query.Query that has a time WHERE clause,
-// that groups by a truncated date, orders by that date, and takes a limit:
+// that groups by a truncated date, orders by that date, and takes a limit.
+// IGinX needs a start for the groups, so they start at the beginning of the
+// data, e.g.
+//
+// SELECT max(usage_user) FROM cpu.*
+// GROUP [$DATA_START, $TIME) BY 1m ORDER BY time DESC LIMIT 5
 //
 // Queries:
 // groupby-orderby-limit
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
 	interval := d.Interval.MustRandWindow(time.Hour)
+	sel, err := d.selectHosts(nil)
+	panicIfErr(err)
+	selectClauses := getSelectAggClauses("max", sel.devices, []string{"usage_user"})
 
-	metrics := [1]string{"usage_user"}
-
-	sql := fmt.Sprintf(`{
-  		"start_relative": {
-		"value": "5",
-		"unit": "minutes"
-	},
-
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "max",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "minutes"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i])
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
+	sql := fmt.Sprintf("SELECT %s FROM %s%s GROUP [%d, %d) BY 1m ORDER BY time DESC LIMIT 5",
+		strings.Join(selectClauses, ", "), sel.from, sel.with, d.Interval.StartUnixNano(), interval.EndUnixNano())
 
 	humanLabel := "Iginx max cpu over last 5 min-intervals (random end)"
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.EndString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
 
-// LastPointPerHost finds the last row for every host in the dataset
+// LastPointPerHost finds the last row for every host in the dataset, which
+// is the last point of every cpu series, e.g.
+//
+// SELECT LAST(*) FROM cpu.*
 //
 // Queries:
 // lastpoint
 func (d *Devops) LastPointPerHost(qi query.Query) {
-	metrics := devops.GetAllCPUMetrics()
-
-	sql := fmt.Sprintf(`{
-  		"start_absolute": 0,
-  		"end_absolute": 2000000000000,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`)
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "last",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "years"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i])
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
+	sel, err := d.selectHosts(nil)
+	panicIfErr(err)
+	sql := fmt.Sprintf("SELECT LAST(*) FROM %s", sel.from)
 
 	humanLabel := "Iginx last row per host"
-	humanDesc := humanLabel
+	humanDesc := humanLabel + ": cpu"
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
 
 // HighCPUForHosts populates a query that gets CPU metrics when the CPU has
 // high usage between a time period for a number of hosts (if 0, it will
-// search all hosts)
+// search all hosts), e.g.
+//
+// SELECT * FROM cpu.host_1.*
+// WHERE usage_user > 90.0 AND time >= $TIME_START AND time < $TIME_END
+//
+// IGinX filters whole rows, so a row holds the columns of all the hosts when
+// any of them is over 90, unlike the other targets which only return the
+// readings of the hosts over 90.
 //
 // Queries:
 // high-cpu-1
 // high-cpu-all
 func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
-	sql := ""
-	metrics := devops.GetAllCPUMetrics()
 
+	var hosts []string
 	if nHosts > 0 {
-		hosts, err := d.GetRandomHosts(nHosts)
+		var err error
+		hosts, err = d.GetRandomHosts(nHosts)
 		panicIfErr(err)
-
-		sql = fmt.Sprintf(`
-		      SELECT *
-		      FROM cpu
-		      WHERE usage_user > 90.0
-		       AND hostname IN ('%s')
-		       AND timestamp >= '%s'
-		       AND timestamp < '%s'`,
-			strings.Join(hosts, "', '"),
-			interval.StartString(),
-			interval.EndString())
-
-		sql = fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-			interval.StartUnixMillis(),
-			interval.EndUnixMillis())
-		i := 0
-		for i = 0; i < len(metrics); i++ {
-			sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "avg",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-				metrics[i])
-			if i != len(metrics)-1 {
-				sql += ","
-			}
-		}
-		sql += "]}"
-
-	} else {
-		sql = fmt.Sprintf(`
-		      SELECT *
-		      FROM cpu
-		      WHERE usage_user > 90.0
-		       AND timestamp >= '%s'
-		       AND timestamp < '%s'`,
-			interval.StartString(),
-			interval.EndString())
-
-		sql = fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-			interval.StartUnixMillis(),
-			interval.EndUnixMillis())
-		i := 0
-		for i = 0; i < len(metrics); i++ {
-			sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "avg",
-				"tags": {
-            		"hostname": [
-              			"*"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "hours"
-          		}
-        	}]
-    	}
-	`,
-				metrics[i])
-			if i != len(metrics)-1 {
-				sql += ","
-			}
-		}
-		sql += "]}"
-
 	}
+	sel, err := d.selectHosts(hosts)
+	panicIfErr(err)
+	selectClauses := getSelectAggClauses("", sel.devices, []string{"*"})
+	conditions := getSelectAggClauses("", sel.devices, []string{"usage_user > 90.0"})
+
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE (%s) AND time >= %d AND time < %d%s",
+		strings.Join(selectClauses, ", "), sel.from, strings.Join(conditions, " OR "),
+		interval.StartUnixNano(), interval.EndUnixNano(), sel.with)
 
 	humanLabel, err := devops.GetHighCPULabel("Iginx", nHosts)
 	panicIfErr(err)
@@ -378,7 +201,10 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 }
 
 // GroupByTime selects the MAX for metrics under 'cpu', per minute for N random
-// hosts
+// hosts, e.g.
+//
+// SELECT max(host_1.*.usage_user), max(host_2.*.usage_user) FROM cpu
+// GROUP [$TIME_START, $TIME_END) BY 1m
 //
 // Resultsets:
 // single-groupby-1-1-12
@@ -393,46 +219,12 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 	panicIfErr(err)
 	hosts, err := d.GetRandomHosts(nHosts)
 	panicIfErr(err)
+	sel, err := d.selectHosts(hosts)
+	panicIfErr(err)
+	selectClauses := getSelectAggClauses("max", sel.devices, metrics)
 
-	sql := fmt.Sprintf(`{
-  		"start_absolute": %d,
-  		"end_absolute": %d,
-  		"time_zone": "Asia/Kabul",
-  		"metrics": [
-	`,
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
-	i := 0
-	for i = 0; i < len(metrics); i++ {
-		sql += fmt.Sprintf(`
-
-		{
-      		"name": "%s",
-      		"aggregators": [
-			{
-          		"name": "max",
-				"tags": {
-            		"hostname": [
-              			"%s"
-            		],
-            		"type": [
-              			"cpu"
-            		]
-          		},
-          		"sampling": {
-            		"value": 1,
-            		"unit": "minutes"
-          		}
-        	}]
-    	}
-	`,
-			metrics[i],
-			strings.Join(hosts, "\", \""))
-		if i != len(metrics)-1 {
-			sql += ","
-		}
-	}
-	sql += "]}"
+	sql := fmt.Sprintf("SELECT %s FROM %s%s GROUP [%d, %d) BY 1m",
+		strings.Join(selectClauses, ", "), sel.from, sel.with, interval.StartUnixNano(), interval.EndUnixNano())
 
 	humanLabel := fmt.Sprintf(
 		"Iginx %d cpu metric(s), random %4d hosts, random %s by 1m",
@@ -0,0 +1,208 @@
+package iginx
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+func TestSplitCommonPrefix(t *testing.T) {
+	cases := []struct {
+		desc      string
+		devices   []string
+		wantFrom  string
+		wantRests []string
+		shouldErr bool
+	}{
+		{
+			desc:      "single device",
+			devices:   []string{"cpu.host_1.*"},
+			wantFrom:  "cpu.host_1.*",
+			wantRests: []string{""},
+		},
+		{
+			desc:      "multiple devices",
+			devices:   []string{"cpu.host_1.*", "cpu.host_2.*"},
+			wantFrom:  "cpu",
+			wantRests: []string{"host_1.*", "host_2.*"},
+		},
+		{
+			desc:      "no common prefix",
+			devices:   []string{"host_1.cpu", "host_2.cpu"},
+			shouldErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			from, rests, err := splitCommonPrefix(c.devices)
+			if c.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error, got from %s", from)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if from != c.wantFrom {
+				t.Errorf("incorrect from: got %s want %s", from, c.wantFrom)
+			}
+			if len(rests) != len(c.wantRests) {
+				t.Fatalf("incorrect rests: got %v want %v", rests, c.wantRests)
+			}
+			for i := range rests {
+				if rests[i] != c.wantRests[i] {
+					t.Errorf("incorrect rest %d: got %s want %s", i, rests[i], c.wantRests[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDevopsQueries(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	g := &BaseGenerator{PathTemplate: "{measurement}.{hostname}.{field}"}
+	qg, err := g.NewDevops(start, end, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d := qg.(*Devops)
+
+	cases := []struct {
+		desc string
+		fill func(q query.Query)
+		want string
+	}{
+		{
+			desc: "single-groupby",
+			fill: func(q query.Query) { d.GroupByTime(q, 2, 1, time.Hour) },
+			want: "SELECT max(host_9.usage_user), max(host_3.usage_user) FROM cpu GROUP [1451679382646325489, 1451682982646325489) BY 1m",
+		},
+		{
+			desc: "cpu-max-all",
+			fill: func(q query.Query) { d.MaxAllCPU(q, 1, 8*time.Hour) },
+			want: "SELECT max(usage_user), max(usage_system), max(usage_idle), max(usage_nice), max(usage_iowait), max(usage_irq), max(usage_softirq), max(usage_steal), max(usage_guest), max(usage_guest_nice) FROM cpu.host_9 GROUP [1451637432342805883, 1451666232342805883) BY 1h",
+		},
+		{
+			desc: "double-groupby",
+			fill: func(q query.Query) { d.GroupByTimeAndPrimaryTag(q, 2) },
+			want: "SELECT avg(usage_user), avg(usage_system) FROM cpu.* GROUP [1451611388303546563, 1451654588303546563) BY 1h",
+		},
+		{
+			desc: "high-cpu",
+			fill: func(q query.Query) { d.HighCPUForHosts(q, 2) },
+			want: "SELECT host_5.*, host_1.* FROM cpu WHERE (host_5.usage_user > 90.0 OR host_1.usage_user > 90.0) AND time >= 1451621267823888648 AND time < 1451664467823888648",
+		},
+		{
+			desc: "groupby-orderby-limit",
+			fill: func(q query.Query) { d.GroupByOrderByLimit(q) },
+			want: "SELECT max(usage_user) FROM cpu.* GROUP [1451606400000000000, 1451635078487617828) BY 1m ORDER BY time DESC LIMIT 5",
+		},
+		{
+			desc: "lastpoint",
+			fill: func(q query.Query) { d.LastPointPerHost(q) },
+			want: "SELECT LAST(*) FROM cpu.*",
+		},
+		{
+			// the rows are filtered as a whole, see HighCPUForHosts
+			desc: "high-cpu-all",
+			fill: func(q query.Query) { d.HighCPUForHosts(q, 0) },
+			want: "SELECT * FROM cpu.* WHERE (usage_user > 90.0) AND time >= 1451611383975214056 AND time < 1451654583975214056",
+		},
+	}
+
+	rand.Seed(123)
+	for _, c := range cases {
+		q := d.GenerateEmptyQuery()
+		c.fill(q)
+		if got := string(q.(*query.Iginx).SqlQuery); got != c.want {
+			t.Errorf("%s: incorrect query:\ngot\n%s\nwant\n%s", c.desc, got, c.want)
+		}
+	}
+}
+
+// matchesPath reports whether a path pattern matches a series path level by level
+func matchesPath(pattern, path string) bool {
+	patternLevels := strings.Split(pattern, ".")
+	pathLevels := strings.Split(path, ".")
+	if len(patternLevels) != len(pathLevels) {
+		return false
+	}
+	for i, level := range patternLevels {
+		if level != paths.Wildcard && level != pathLevels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDevopsQueriesMatchLoadedSeries(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	for _, template := range []string{"", "{measurement}.{region}.{hostname}.{field}"} {
+		g := &BaseGenerator{PathTemplate: template}
+		qg, err := g.NewDevops(start, end, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d := qg.(*Devops)
+
+		// the loader writes the series with every tag set
+		tags := make(map[string]string)
+		for _, key := range devopsTagKeys() {
+			tags[key] = key + "_0"
+		}
+		tags["hostname"] = "host_3"
+		series := d.pathTemplate.Path(devopsCPUTable, tags, "usage_user")
+
+		sel, err := d.selectHosts([]string{"host_1", "host_3"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !matchesPath(sel.from+"."+sel.devices[1]+".usage_user", series) {
+			t.Errorf("template %q: %s.%s does not match %s", template, sel.from, sel.devices[1], series)
+		}
+		if matchesPath(sel.from+"."+sel.devices[0]+".usage_user", series) {
+			t.Errorf("template %q: %s.%s matches %s of another host", template, sel.from, sel.devices[0], series)
+		}
+
+		sel, err = d.selectHosts(nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if sel.devices[0] != "" || !matchesPath(sel.from+".usage_user", series) {
+			t.Errorf("template %q: %s does not match %s", template, sel.from, series)
+		}
+	}
+}
+
+func TestDevopsTaggedQueries(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	g := &BaseGenerator{Tagged: true}
+	qg, err := g.NewDevops(start, end, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d := qg.(*Devops)
+
+	rand.Seed(123)
+	q := d.GenerateEmptyQuery()
+	d.GroupByTime(q, 2, 1, time.Hour)
+	want := "SELECT max(usage_user) FROM cpu WITH hostname=host_9 OR hostname=host_3 GROUP [1451679382646325489, 1451682982646325489) BY 1m"
+	if got := string(q.(*query.Iginx).SqlQuery); got != want {
+		t.Errorf("incorrect query:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	q = d.GenerateEmptyQuery()
+	d.LastPointPerHost(q)
+	if got, want := string(q.(*query.Iginx).SqlQuery), "SELECT LAST(*) FROM cpu"; got != want {
+		t.Errorf("incorrect query: got %s want %s", got, want)
+	}
+}
@@ -6,63 +6,202 @@ import (
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iot"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
 )
 
 const (
-	iotReadingsTable = "readings"
+	iotReadingsTable    = "readings"
+	iotDiagnosticsTable = "diagnostics"
+
+	// tenMinutes is the size of the time windows of the queries, like the
+	// time_bucket('10 minutes', time) of TimescaleDB
+	tenMinutes = 10 * time.Minute
 )
 
-// IoT produces TimescaleDB-specific queries for all the iot query types.
+// iotTagKeys are the string tags of the trucks, the other tags are loaded
+// as fields
+var iotTagKeys = []string{"name", "fleet", "driver", "model", "device_version"}
+
+// IoT produces IGinX-specific queries for all the iot query types.
+//
+// IGinX returns a column per series, the queries turn them into rows per
+// truck, like the rows of the tables of the other databases, with the Python
+// UDSFs of iginx_py_udfs/udsf_iot.py registered as device_rows,
+// driving_sessions and breakdowns.
 type IoT struct {
 	*iot.Core
 	*BaseGenerator
+	pathTemplate *paths.Template
 }
 
 // NewIoT makes an IoT object ready to generate Queries.
 func NewIoT(start, end time.Time, scale int, g *BaseGenerator) *IoT {
 	c, err := iot.NewCore(start, end, scale)
 	panicIfErr(err)
+	tmpl, err := g.pathTemplate(iotTagKeys)
+	panicIfErr(err)
 	return &IoT{
 		Core:          c,
 		BaseGenerator: g,
+		pathTemplate:  tmpl,
 	}
 }
 
-func (i *IoT) getTrucksWhereWithNames(names []string) string {
-	nameClauses := []string{}
-	for _, s := range names {
-		nameClauses = append(nameClauses, fmt.Sprintf("\"name\" = '%s'", s))
-	}
+// selection selects the devices of a measurement having any of the given
+// tags, or all of them if no tags are given
+func (i *IoT) selection(measurement string, filters ...map[string]string) *selection {
+	sel, err := newSelection(i.pathTemplate, measurement, filters...)
+	panicIfErr(err)
+	return sel
+}
 
-	combinedHostnameClause := strings.Join(nameClauses, " or ")
-	return "(" + combinedHostnameClause + ")"
+// fleet selects the devices of a measurement in a random fleet
+func (i *IoT) fleet(measurement string) *selection {
+	return i.selection(measurement, map[string]string{"fleet": i.GetRandomFleet()})
 }
 
-func (i *IoT) getTruckWhereString(nTrucks int) string {
+func (i *IoT) getTrucks(nTrucks int) *selection {
 	names, err := i.GetRandomTrucks(nTrucks)
 	if err != nil {
 		panic(err.Error())
 	}
-	return i.getTrucksWhereWithNames(names)
+	filters := make([]map[string]string, len(names))
+	for j, name := range names {
+		filters[j] = map[string]string{"name": name}
+	}
+	return i.selection(iotReadingsTable, filters...)
+}
+
+// tagColumn returns the column of the rows of the UDSFs holding a tag of the
+// trucks: the path level of the tag, or the tag itself for tagged series.
+// Returns "" if the series do not keep the tag.
+func (i *IoT) tagColumn(tag string) string {
+	if level := i.pathTemplate.Level(tag); level >= 0 {
+		return fmt.Sprintf("level%d", level)
+	}
+	if i.pathTemplate.Tagged() {
+		return paths.Sanitize(tag)
+	}
+	return ""
+}
+
+// tagColumns returns the tags the series keep among the given ones and the
+// columns holding them. If the series do not keep the name of the trucks,
+// their device path is used instead so the rows of different trucks are
+// not merged, the other missing tags are left out.
+func (i *IoT) tagColumns(tags ...string) (kept, columns []string) {
+	for _, tag := range tags {
+		column := i.tagColumn(tag)
+		if column == "" && tag == "name" {
+			column = "device"
+		}
+		if column == "" {
+			continue
+		}
+		kept = append(kept, tag)
+		columns = append(columns, column)
+	}
+	return kept, columns
+}
+
+// selectList returns the select list of the columns holding the given tags,
+// named after the tags, followed by the other given expressions
+func (i *IoT) selectList(tags []string, expressions ...string) string {
+	kept, columns := i.tagColumns(tags...)
+	for j, column := range columns {
+		if column != kept[j] {
+			columns[j] = column + " AS " + kept[j]
+		}
+	}
+	return strings.Join(append(columns, expressions...), ", ")
+}
+
+// groupByTags returns the columns holding the given tags, to group by
+func (i *IoT) groupByTags(tags ...string) []string {
+	_, columns := i.tagColumns(tags...)
+	return columns
+}
+
+// knownTags returns the conditions excluding the trucks without the given
+// tags, like the 'IS NOT NULL' conditions of TimescaleDB. The loader sets
+// the path levels of missing tags to "unknown", and so does the UDSFs for
+// missing IGinX tags.
+func (i *IoT) knownTags(tags ...string) []string {
+	var conditions []string
+	for _, tag := range tags {
+		if column := i.tagColumn(tag); column != "" {
+			conditions = append(conditions, fmt.Sprintf("%s != '%s'", column, paths.MissingTagValue))
+		}
+	}
+	return conditions
+}
+
+// whereClause returns the WHERE clause of all the given conditions, or ""
+// if there are none
+func whereClause(conditions ...string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// groupByClause returns the GROUP BY clause of the given columns, or "" if
+// there are none
+func groupByClause(columns ...string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return " GROUP BY " + strings.Join(columns, ", ")
+}
+
+// timeCondition returns the condition selecting the points of an interval
+func timeCondition(interval *internalutils.TimeInterval) string {
+	return fmt.Sprintf("time >= %d AND time < %d", interval.StartUnixNano(), interval.EndUnixNano())
+}
+
+// tenMinuteWindows returns the GROUP clause splitting an interval in 10
+// minutes windows. The windows are aligned on the epoch like the buckets of
+// time_bucket, so the first and last ones may start before and end after
+// the interval.
+func tenMinuteWindows(interval *internalutils.TimeInterval) string {
+	size := tenMinutes.Nanoseconds()
+	start := interval.StartUnixNano()
+	start -= start % size
+	end := interval.EndUnixNano()
+	if end%size != 0 {
+		end += size - end%size
+	}
+	return fmt.Sprintf(" GROUP [%d, %d) BY 10m", start, end)
+}
+
+// lastValues returns the query of the rows holding the last value of the
+// given fields of each selected truck
+func lastValues(sel *selection, fields ...string) string {
+	return fmt.Sprintf("SELECT device_rows(*) FROM (SELECT %s FROM %s%s)",
+		strings.Join(getSelectAggClauses("last_value", sel.devices, fields), ", "), sel.from, sel.with)
 }
 
 // LastLocByTruck finds the truck location for nTrucks.
 func (i *IoT) LastLocByTruck(qi query.Query, nTrucks int) {
-	iginxql := fmt.Sprintf("SELECT last(longitude), last(latitude) FROM readings.%s.*.*.*.*",
-		i.getTruckWhereString(nTrucks))
+	iginxql := fmt.Sprintf("SELECT %s FROM (%s)",
+		i.selectList([]string{"name", "driver"}, "last_value_longitude AS longitude", "last_value_latitude AS latitude"),
+		lastValues(i.getTrucks(nTrucks), "longitude", "latitude"))
 
 	humanLabel := "Iginx last location by specific truck"
 	humanDesc := fmt.Sprintf("%s: random %4d trucks", humanLabel, nTrucks)
-	fmt.Printf("query: %s\n", iginxql)
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
 }
 
 // LastLocPerTruck finds all the truck locations along with truck and driver names.
 func (i *IoT) LastLocPerTruck(qi query.Query) {
-	//iginxql := "SELECT last(longitude), last(latitude) FROM readings.*.*.*.*.*"
-	iginxql := "select a.longitude as longitude, b.latitude as latitude, a.truck as truck from (select truck, last_value(value) as longitude from (select transposition(*) from (select latitude, longitude from readings.*.*.*.*.*)) group by truck, name having name = 'longitude') as a, (select truck, last_value(value) as latitude from (select transposition(*) from (select latitude, longitude from readings.*.*.*.*.*)) group by truck, name having name = 'latitude') as b where a.truck = b.truck"
+	iginxql := fmt.Sprintf("SELECT %s FROM (%s)%s",
+		i.selectList([]string{"name", "driver"}, "last_value_longitude AS longitude", "last_value_latitude AS latitude"),
+		lastValues(i.fleet(iotReadingsTable), "longitude", "latitude"),
+		whereClause(i.knownTags("name")...))
+
 	humanLabel := "Iginx last location per truck"
 	humanDesc := humanLabel
 
@@ -71,8 +210,10 @@ func (i *IoT) LastLocPerTruck(qi query.Query) {
 
 // TrucksWithLowFuel finds all trucks with low fuel (less than 10%).
 func (i *IoT) TrucksWithLowFuel(qi query.Query) {
-	iginxql := fmt.Sprintf("select truck, last_value(value) as fuel from (select transposition(fuel_state) from diagnostics.*.%s.*.*.*) group by truck having last_value(value) < 0.1;",
-		i.GetRandomFleet())
+	iginxql := fmt.Sprintf("SELECT %s FROM (%s)%s",
+		i.selectList([]string{"name", "driver"}, "last_value_fuel_state AS fuel_state"),
+		lastValues(i.fleet(iotDiagnosticsTable), "fuel_state"),
+		whereClause(append(i.knownTags("name"), "last_value_fuel_state < 0.1")...))
 
 	humanLabel := "Iginx trucks with low fuel"
 	humanDesc := fmt.Sprintf("%s: under 10 percent", humanLabel)
@@ -82,10 +223,15 @@ func (i *IoT) TrucksWithLowFuel(qi query.Query) {
 
 // TrucksWithHighLoad finds all trucks that have load over 90%.
 func (i *IoT) TrucksWithHighLoad(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	fleet := i.GetRandomFleet()
-	iginxql := fmt.Sprintf("select a.curr_load as load, a.truck as truck, b.capacity as capacity from (select truck, last_value(value) as curr_load from (select transposition(*) from diagnostics.*.%s.*.*.*) group by name, truck having name = 'current_load') as a, (select truck, last_value(value) as capacity from (select transposition(*) from diagnostics.*.%s.*.*.*) group by name, truck having name = 'load_capacity') as b where a.truck = b.truck",
-		fleet, fleet)
+	tags := []string{"name", "driver"}
+	kept, _ := i.tagColumns(tags...)
+	loads := fmt.Sprintf("SELECT %s FROM (%s)%s",
+		i.selectList(tags, "last_value_current_load AS current_load",
+			"last_value_current_load / last_value_load_capacity AS load_ratio"),
+		lastValues(i.fleet(iotDiagnosticsTable), "current_load", "load_capacity"),
+		whereClause(i.knownTags("name")...))
+	iginxql := fmt.Sprintf("SELECT %s FROM (%s) WHERE load_ratio > 0.9",
+		strings.Join(append(kept, "current_load"), ", "), loads)
 
 	humanLabel := "Iginx trucks with high load"
 	humanDesc := fmt.Sprintf("%s: over 90 percent", humanLabel)
@@ -95,11 +241,12 @@ func (i *IoT) TrucksWithHighLoad(qi query.Query) {
 
 // StationaryTrucks finds all trucks that have low average velocity in a time window.
 func (i *IoT) StationaryTrucks(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
 	interval := i.Interval.MustRandWindow(iot.StationaryDuration)
-
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s where time >=%d and time <= %d",
-		i.GetRandomFleet(), interval.Start().Unix()*1000, interval.End().Unix()*1000)
+	readings := i.fleet(iotReadingsTable)
+	iginxql := fmt.Sprintf("SELECT %s FROM (SELECT device_rows(*) FROM (SELECT avg(velocity) FROM %s WHERE %s%s))%s",
+		i.selectList([]string{"name", "driver"}),
+		readings.from, timeCondition(interval), readings.with,
+		whereClause(append(i.knownTags("name"), "avg_velocity < 1")...))
 
 	humanLabel := "Iginx stationary trucks"
 	humanDesc := fmt.Sprintf("%s: with low avg velocity in last 10 minutes", humanLabel)
@@ -107,12 +254,24 @@ func (i *IoT) StationaryTrucks(qi query.Query) {
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
 }
 
+// drivingTrucks returns the query of the trucks of a random fleet driving,
+// with an average velocity over 1, in more than the given number of 10
+// minutes windows of an interval
+func (i *IoT) drivingTrucks(interval *internalutils.TimeInterval, periods int) string {
+	tags := []string{"name", "driver"}
+	readings := i.fleet(iotReadingsTable)
+	return fmt.Sprintf("SELECT %s FROM (SELECT device_rows(*) FROM (SELECT avg(velocity) FROM %s WHERE %s%s%s))%s%s HAVING count(avg_velocity) > %d",
+		i.selectList(tags),
+		readings.from, timeCondition(interval), readings.with, tenMinuteWindows(interval),
+		whereClause(append(i.knownTags("name"), "avg_velocity > 1")...),
+		groupByClause(i.groupByTags(tags...)...), periods)
+}
+
 // TrucksWithLongDrivingSessions finds all trucks that have not stopped at least 20 mins in the last 4 hours.
 func (i *IoT) TrucksWithLongDrivingSessions(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	interval := i.Interval.MustRandWindow(iot.StationaryDuration)
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.* GROUP [%d, %d] BY 10ms",
-		i.GetRandomFleet(), interval.Start().Unix(), interval.End().Unix())
+	interval := i.Interval.MustRandWindow(iot.LongDrivingSessionDuration)
+	// Calculate number of 10 min intervals that is the max driving duration for the session if we rest 5 mins per hour.
+	iginxql := i.drivingTrucks(interval, tenMinutePeriods(5, iot.LongDrivingSessionDuration))
 
 	humanLabel := "Iginx trucks with longer driving sessions"
 	humanDesc := fmt.Sprintf("%s: stopped less than 20 mins in 4 hour period", humanLabel)
@@ -122,20 +281,24 @@ func (i *IoT) TrucksWithLongDrivingSessions(qi query.Query) {
 
 // TrucksWithLongDailySessions finds all trucks that have driven more than 10 hours in the last 24 hours.
 func (i *IoT) TrucksWithLongDailySessions(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	interval := i.Interval.MustRandWindow(iot.StationaryDuration)
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.* GROUP [%d, %d] BY 10ms",
-		i.GetRandomFleet(), interval.Start().Unix(), interval.End().Unix())
+	interval := i.Interval.MustRandWindow(iot.DailyDrivingDuration)
+	// Calculate number of 10 min intervals that is the max driving duration for the session if we rest 35 mins per hour.
+	iginxql := i.drivingTrucks(interval, tenMinutePeriods(35, iot.DailyDrivingDuration))
 
-	humanLabel := "Iginx trucks with longer driving sessions"
-	humanDesc := fmt.Sprintf("%s: stopped less than 20 mins in 4 hour period", humanLabel)
+	humanLabel := "Iginx trucks with longer daily sessions"
+	humanDesc := fmt.Sprintf("%s: drove more than 10 hours in the last 24 hours", humanLabel)
 
 	i.fillInQuery(qi, humanLabel, humanDesc, iginxql)
 }
 
 // AvgVsProjectedFuelConsumption calculates average and projected fuel consumption per fleet.
 func (i *IoT) AvgVsProjectedFuelConsumption(qi query.Query) {
-	iginxql := fmt.Sprintf("select sum(fuel_consumption) from readings.*.*.*.*.* agg level = 2")
+	iginxql := fmt.Sprintf("SELECT %s FROM (SELECT device_rows(*) FROM (SELECT fuel_consumption, velocity, nominal_fuel_consumption FROM %s))%s%s",
+		i.selectList([]string{"fleet"}, "avg(fuel_consumption) AS avg_fuel_consumption",
+			"avg(nominal_fuel_consumption) AS projected_fuel_consumption"),
+		i.selection(iotReadingsTable).from,
+		whereClause(append(i.knownTags("fleet", "name"), "velocity > 1")...),
+		groupByClause(i.groupByTags("fleet")...))
 
 	humanLabel := "Iginx average vs projected fuel consumption per fleet"
 	humanDesc := humanLabel
@@ -145,8 +308,16 @@ func (i *IoT) AvgVsProjectedFuelConsumption(qi query.Query) {
 
 // AvgDailyDrivingDuration finds the average driving duration per driver.
 func (i *IoT) AvgDailyDrivingDuration(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.*", i.GetRandomFleet())
+	tags := []string{"fleet", "name", "driver"}
+	kept, _ := i.tagColumns(tags...)
+	// the hours each truck drove per day, counting the 10 minutes windows
+	// with an average velocity over 1
+	daily := fmt.Sprintf("SELECT %s FROM (SELECT device_rows(*) FROM (SELECT avg(velocity) FROM %s%s)) WHERE avg_velocity > 1%s",
+		i.selectList(tags, "count(avg_velocity) / 6 AS hours"),
+		i.selection(iotReadingsTable).from, tenMinuteWindows(i.Interval),
+		groupByClause(append([]string{"device", "day"}, i.groupByTags(tags...)...)...))
+	iginxql := fmt.Sprintf("SELECT %s FROM (%s)%s",
+		strings.Join(append(kept, "avg(hours) AS avg_daily_hours"), ", "), daily, groupByClause(kept...))
 
 	humanLabel := "Iginx average driver driving duration per day"
 	humanDesc := humanLabel
@@ -156,8 +327,11 @@ func (i *IoT) AvgDailyDrivingDuration(qi query.Query) {
 
 // AvgDailyDrivingSession finds the average driving session without stopping per driver per day.
 func (i *IoT) AvgDailyDrivingSession(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	iginxql := fmt.Sprintf("SELECT AVG(velocity) FROM readings.*.%s.*", i.GetRandomFleet())
+	iginxql := fmt.Sprintf("SELECT %s FROM (SELECT driving_sessions(*) FROM (SELECT avg(velocity) FROM %s%s))%s ORDER BY %s",
+		i.selectList([]string{"name"}, "day", "duration"),
+		i.selection(iotReadingsTable).from, tenMinuteWindows(i.Interval),
+		whereClause(i.knownTags("name")...),
+		strings.Join(append(i.groupByTags("name"), "day"), ", "))
 
 	humanLabel := "Iginx average driver driving session without stopping per day"
 	humanDesc := humanLabel
@@ -167,7 +341,18 @@ func (i *IoT) AvgDailyDrivingSession(qi query.Query) {
 
 // AvgLoad finds the average load per truck model per fleet.
 func (i *IoT) AvgLoad(qi query.Query) {
-	iginxql := fmt.Sprintf("select avg(current_load) from diagnostics.*.*.*.*.* agg level=1,2")
+	tags := []string{"fleet", "model"}
+	kept, _ := i.tagColumns(tags...)
+	// the load capacity of a truck does not change, its average is its value
+	loads := fmt.Sprintf("SELECT %s FROM (SELECT device_rows(*) FROM (SELECT avg(current_load), avg(load_capacity) FROM %s))%s",
+		i.selectList(tags, "avg_load_capacity AS load_capacity",
+			"avg_current_load / avg_load_capacity AS load_percentage"),
+		i.selection(iotDiagnosticsTable).from,
+		whereClause(i.knownTags("name")...))
+	groups := append(kept, "load_capacity")
+	iginxql := fmt.Sprintf("SELECT %s FROM (%s)%s",
+		strings.Join(append(groups, "avg(load_percentage) AS avg_load_percentage"), ", "), loads,
+		groupByClause(groups...))
 
 	humanLabel := "Iginx average load per truck model per fleet"
 	humanDesc := humanLabel
@@ -177,10 +362,12 @@ func (i *IoT) AvgLoad(qi query.Query) {
 
 // DailyTruckActivity returns the number of hours trucks has been active (not out-of-commission) per day per fleet per model.
 func (i *IoT) DailyTruckActivity(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	start := i.Interval.Start().Unix()
-	end := i.Interval.End().Unix()
-	iginxql := fmt.Sprintf(`SELECT AVG(status) FROM diagnostics.*.*.*.* GROUP [%d, %d] BY time(1d)`, start, end)
+	tags := []string{"fleet", "model"}
+	iginxql := fmt.Sprintf("SELECT %s FROM (SELECT device_rows(*) FROM (SELECT avg(status), count(status) FROM %s%s))%s%s ORDER BY day",
+		i.selectList(tags, "day", "sum(count_status) / 144 AS daily_activity"),
+		i.selection(iotDiagnosticsTable).from, tenMinuteWindows(i.Interval),
+		whereClause(append(i.knownTags("name"), "avg_status < 1")...),
+		groupByClause(append(i.groupByTags(tags...), "day")...))
 
 	humanLabel := "Iginx daily truck activity per fleet per model"
 	humanDesc := humanLabel
@@ -190,10 +377,13 @@ func (i *IoT) DailyTruckActivity(qi query.Query) {
 
 // TruckBreakdownFrequency calculates the amount of times a truck model broke down in the last period.
 func (i *IoT) TruckBreakdownFrequency(qi query.Query) {
-	// not all implemented limited by iginx sql grammar
-	start := i.Interval.Start().Unix()
-	end := i.Interval.End().Unix()
-	iginxql := fmt.Sprintf(`SELECT AVG(status) FROM diagnostics.*.*.*.* GROUP [%d, %d] BY time(1d)`, start, end)
+	// the trucks that never broke down are left out, so are the models
+	// without any breakdown like in the other databases
+	iginxql := fmt.Sprintf("SELECT %s FROM (SELECT breakdowns(status) FROM %s)%s%s",
+		i.selectList([]string{"model"}, "sum(breakdowns) AS total_breakdowns"),
+		i.selection(iotDiagnosticsTable).from,
+		whereClause(append(i.knownTags("name"), "breakdowns > 0")...),
+		groupByClause(i.groupByTags("model")...))
 
 	humanLabel := "Iginx truck breakdown frequency per model"
 	humanDesc := humanLabel
@@ -0,0 +1,118 @@
+package iginx
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	internalutils "github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+func TestIoTQueries(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	cases := []struct {
+		desc     string
+		template string
+		tagged   bool
+		fill     func(i *IoT, q query.Query)
+		want     string
+	}{
+		{
+			desc: "single-last-loc",
+			fill: func(i *IoT, q query.Query) { i.LastLocByTruck(q, 2) },
+			want: "SELECT level1 AS name, level3 AS driver, last_value_longitude AS longitude, last_value_latitude AS latitude FROM (SELECT device_rows(*) FROM (SELECT last_value(truck_0005.*.*.*.*.longitude), last_value(truck_0005.*.*.*.*.latitude), last_value(truck_0009.*.*.*.*.longitude), last_value(truck_0009.*.*.*.*.latitude) FROM readings))",
+		},
+		{
+			desc: "high-load",
+			fill: func(i *IoT, q query.Query) { i.TrucksWithHighLoad(q) },
+			want: "SELECT name, driver, current_load FROM (SELECT level1 AS name, level3 AS driver, last_value_current_load AS current_load, last_value_current_load / last_value_load_capacity AS load_ratio FROM (SELECT device_rows(*) FROM (SELECT last_value(current_load), last_value(load_capacity) FROM diagnostics.*.South.*.*.*)) WHERE level1 != 'unknown') WHERE load_ratio > 0.9",
+		},
+		{
+			desc: "long-driving-sessions",
+			fill: func(i *IoT, q query.Query) { i.TrucksWithLongDrivingSessions(q) },
+			want: "SELECT level1 AS name, level3 AS driver FROM (SELECT device_rows(*) FROM (SELECT avg(velocity) FROM readings.*.West.*.*.* WHERE time >= 1451614582646325489 AND time < 1451628982646325489 GROUP [1451614200000000000, 1451629200000000000) BY 10m)) WHERE level1 != 'unknown' AND avg_velocity > 1 GROUP BY level1, level3 HAVING count(avg_velocity) > 22",
+		},
+		{
+			desc: "avg-daily-driving-duration",
+			fill: func(i *IoT, q query.Query) { i.AvgDailyDrivingDuration(q) },
+			want: "SELECT fleet, name, driver, avg(hours) AS avg_daily_hours FROM (SELECT level2 AS fleet, level1 AS name, level3 AS driver, count(avg_velocity) / 6 AS hours FROM (SELECT device_rows(*) FROM (SELECT avg(velocity) FROM readings.*.*.*.*.* GROUP [1451606400000000000, 1451692800000000000) BY 10m)) WHERE avg_velocity > 1 GROUP BY device, day, level2, level1, level3) GROUP BY fleet, name, driver",
+		},
+		{
+			desc: "daily-activity",
+			fill: func(i *IoT, q query.Query) { i.DailyTruckActivity(q) },
+			want: "SELECT level2 AS fleet, level4 AS model, day, sum(count_status) / 144 AS daily_activity FROM (SELECT device_rows(*) FROM (SELECT avg(status), count(status) FROM diagnostics.*.*.*.*.* GROUP [1451606400000000000, 1451692800000000000) BY 10m)) WHERE level1 != 'unknown' AND avg_status < 1 GROUP BY level2, level4, day ORDER BY day",
+		},
+		{
+			desc: "breakdown-frequency",
+			fill: func(i *IoT, q query.Query) { i.TruckBreakdownFrequency(q) },
+			want: "SELECT level4 AS model, sum(breakdowns) AS total_breakdowns FROM (SELECT breakdowns(status) FROM diagnostics.*.*.*.*.*) WHERE level1 != 'unknown' AND breakdowns > 0 GROUP BY level4",
+		},
+		{
+			desc:   "tagged low-fuel",
+			tagged: true,
+			fill:   func(i *IoT, q query.Query) { i.TrucksWithLowFuel(q) },
+			want:   "SELECT name, driver, last_value_fuel_state AS fuel_state FROM (SELECT device_rows(*) FROM (SELECT last_value(fuel_state) FROM diagnostics WITH fleet=South)) WHERE name != 'unknown' AND last_value_fuel_state < 0.1",
+		},
+		{
+			desc:   "tagged avg-daily-driving-session",
+			tagged: true,
+			fill:   func(i *IoT, q query.Query) { i.AvgDailyDrivingSession(q) },
+			want:   "SELECT name, day, duration FROM (SELECT driving_sessions(*) FROM (SELECT avg(velocity) FROM readings GROUP [1451606400000000000, 1451692800000000000) BY 10m)) WHERE name != 'unknown' ORDER BY name, day",
+		},
+		{
+			desc:     "avg-load without model",
+			template: "{measurement}.{fleet}.{name}.{field}",
+			fill:     func(i *IoT, q query.Query) { i.AvgLoad(q) },
+			want:     "SELECT fleet, load_capacity, avg(load_percentage) AS avg_load_percentage FROM (SELECT level1 AS fleet, avg_load_capacity AS load_capacity, avg_current_load / avg_load_capacity AS load_percentage FROM (SELECT device_rows(*) FROM (SELECT avg(current_load), avg(load_capacity) FROM diagnostics.*.*)) WHERE level2 != 'unknown') GROUP BY fleet, load_capacity",
+		},
+		{
+			desc:     "stationary-trucks without name",
+			template: "{measurement}.{fleet}.{field}",
+			fill:     func(i *IoT, q query.Query) { i.StationaryTrucks(q) },
+			want:     "SELECT device AS name FROM (SELECT device_rows(*) FROM (SELECT avg(velocity) FROM readings.West WHERE time >= 1451668582646325489 AND time < 1451669182646325489)) WHERE avg_velocity < 1",
+		},
+	}
+
+	for _, c := range cases {
+		g := &BaseGenerator{PathTemplate: c.template, Tagged: c.tagged}
+		qg, err := g.NewIoT(start, end, 10)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		rand.Seed(123)
+		q := qg.GenerateEmptyQuery()
+		c.fill(qg.(*IoT), q)
+		if got := string(q.(*query.Iginx).SqlQuery); got != c.want {
+			t.Errorf("%s: incorrect query:\ngot\n%s\nwant\n%s", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestTenMinuteWindows(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 3, 0, 0, time.UTC)
+	cases := []struct {
+		end  time.Time
+		want string
+	}{
+		{
+			end:  start.Add(time.Hour),
+			want: " GROUP [1451606400000000000, 1451610600000000000) BY 10m",
+		},
+		{
+			end:  start.Add(57 * time.Minute),
+			want: " GROUP [1451606400000000000, 1451610000000000000) BY 10m",
+		},
+	}
+	for _, c := range cases {
+		interval, err := internalutils.NewTimeInterval(start, c.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tenMinuteWindows(interval); got != c.want {
+			t.Errorf("incorrect windows for %v: got %s want %s", c.end, got, c.want)
+		}
+	}
+}
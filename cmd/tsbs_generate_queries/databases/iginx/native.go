@@ -0,0 +1,221 @@
+package iginx
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	iginxuse "github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iginx"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+const (
+	devopsMemTable = "mem"
+	// materializedPrefix is the path the select-into queries write their
+	// results under, next to the series of the benchmark
+	materializedPrefix = "tsbs_materialized"
+)
+
+// Native produces the queries of the iginx use case, which run on the
+// devops data.
+type Native struct {
+	*Devops
+}
+
+// NewIginx creates a new iginx use case query generator.
+func (g *BaseGenerator) NewIginx(start, end time.Time, scale int) (utils.QueryGenerator, error) {
+	core, err := iginxuse.NewCore(start, end, scale)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := g.pathTemplate(devopsTagKeys())
+	if err != nil {
+		return nil, err
+	}
+
+	native := &Native{
+		Devops: &Devops{
+			BaseGenerator: g,
+			Core:          core.Core,
+			pathTemplate:  tmpl,
+		},
+	}
+
+	return native, nil
+}
+
+// selectMeasurement selects the series of a measurement of the given hosts,
+// or of all the hosts if none is given
+func (n *Native) selectMeasurement(measurement string, hosts []string) (*selection, error) {
+	filters := make([]map[string]string, len(hosts))
+	for i, host := range hosts {
+		filters[i] = map[string]string{"hostname": host}
+	}
+	return newSelection(n.pathTemplate, measurement, filters...)
+}
+
+// SlidingWindow selects the AVG of usage_user of N random hosts over
+// windows of 5 minutes sliding by a minute for 12 hours, e.g.
+//
+// SELECT avg(host_1.*.usage_user), avg(host_2.*.usage_user) FROM cpu
+// GROUP [$TIME_START, $TIME_END) BY 5m, 1m
+//
+// Queries:
+// sliding-window-1
+// sliding-window-8
+func (n *Native) SlidingWindow(qi query.Query, nHosts int) {
+	interval := n.Interval.MustRandWindow(iginxuse.SlidingWindowDuration)
+	hosts, err := n.GetRandomHosts(nHosts)
+	panicIfErr(err)
+	sel, err := n.selectHosts(hosts)
+	panicIfErr(err)
+	selectClauses := getSelectAggClauses("avg", sel.devices, []string{"usage_user"})
+
+	sql := fmt.Sprintf("SELECT %s FROM %s%s GROUP [%d, %d) BY 5m, 1m",
+		strings.Join(selectClauses, ", "), sel.from, sel.with, interval.StartUnixNano(), interval.EndUnixNano())
+
+	humanLabel := fmt.Sprintf("Iginx %d host(s), avg of usage_user over 5m windows sliding by 1m, random %s by 1m",
+		nHosts, iginxuse.SlidingWindowDuration)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	n.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// ValueFilter selects the points of all the hosts in an hour where any of
+// the first N cpu metrics is over 90, a filter on the values of N series
+// per host, e.g.
+//
+// SELECT usage_user, usage_system FROM cpu.*
+// WHERE (usage_user > 90.0 OR usage_system > 90.0)
+// AND time >= $TIME_START AND time < $TIME_END
+//
+// A row holds the columns of all the hosts when any of them is over 90, as
+// IGinX filters whole rows.
+//
+// Queries:
+// value-filter-1
+// value-filter-5
+func (n *Native) ValueFilter(qi query.Query, nMetrics int) {
+	interval := n.Interval.MustRandWindow(iginxuse.ValueFilterDuration)
+	metrics, err := devops.GetCPUMetricsSlice(nMetrics)
+	panicIfErr(err)
+	sel, err := n.selectHosts(nil)
+	panicIfErr(err)
+	conditions := make([]string, len(metrics))
+	for i, metric := range metrics {
+		conditions[i] = metric + " > 90.0"
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE (%s) AND time >= %d AND time < %d%s",
+		strings.Join(metrics, ", "), sel.from, strings.Join(conditions, " OR "),
+		interval.StartUnixNano(), interval.EndUnixNano(), sel.with)
+
+	humanLabel := fmt.Sprintf("Iginx %d cpu metric(s) over 90 for all hosts, random %s", nMetrics, iginxuse.ValueFilterDuration)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	n.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// ShowTimeSeries lists the cpu series of the hosts whose name starts with the
+// name of a random host, a metadata lookup matching a path pattern, e.g.
+//
+// SHOW TIME SERIES cpu.host_1*.*
+//
+// Queries:
+// show-time-series
+func (n *Native) ShowTimeSeries(qi query.Query) {
+	hosts, err := n.GetRandomHosts(1)
+	panicIfErr(err)
+	pattern := hosts[0] + paths.Wildcard
+	sel, err := n.selectHosts([]string{pattern})
+	panicIfErr(err)
+
+	sql := fmt.Sprintf("SHOW TIME SERIES %s.%s%s", sel.from, paths.Wildcard, sel.with)
+
+	humanLabel := "Iginx cpu series of the hosts matching a pattern"
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, pattern)
+	n.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// CrossJoin joins the cpu and mem series of a random host over an hour on
+// their timestamps, keeping the points where the host used over 90% of both.
+// The measurements can be stored in different storage engines, IGinX then
+// joins the rows read from each, e.g.
+//
+// SELECT cpu.host_1.*.usage_user, mem.host_1.*.used_percent
+// FROM cpu.host_1.*, mem.host_1.*
+// WHERE cpu.host_1.*.time = mem.host_1.*.time AND ...
+//
+// Queries:
+// cross-join
+func (n *Native) CrossJoin(qi query.Query) {
+	interval := n.Interval.MustRandWindow(iginxuse.CrossJoinDuration)
+	hosts, err := n.GetRandomHosts(1)
+	panicIfErr(err)
+	cpu, err := n.selectMeasurement(devopsCPUTable, hosts)
+	panicIfErr(err)
+	mem, err := n.selectMeasurement(devopsMemTable, hosts)
+	panicIfErr(err)
+
+	sql := fmt.Sprintf("SELECT %[1]s.usage_user, %[2]s.used_percent FROM %[1]s, %[2]s "+
+		"WHERE %[1]s.time = %[2]s.time AND %[1]s.time >= %[3]d AND %[1]s.time < %[4]d "+
+		"AND %[1]s.usage_user > 90.0 AND %[2]s.used_percent > 90.0%[5]s",
+		cpu.from, mem.from, interval.StartUnixNano(), interval.EndUnixNano(), cpu.with)
+
+	humanLabel := fmt.Sprintf("Iginx cpu and mem of a host over 90, joined, random %s", iginxuse.CrossJoinDuration)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	n.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// DeleteRange deletes a minute of the cpu series of a random host, e.g.
+//
+// DELETE FROM cpu.host_1.*.* WHERE time >= $TIME_START AND time < $TIME_END
+//
+// The query modifies the data, the other queries run afterwards read less
+// points.
+//
+// Queries:
+// delete-range
+func (n *Native) DeleteRange(qi query.Query) {
+	interval := n.Interval.MustRandWindow(iginxuse.DeleteRangeDuration)
+	hosts, err := n.GetRandomHosts(1)
+	panicIfErr(err)
+	sel, err := n.selectHosts(hosts)
+	panicIfErr(err)
+
+	sql := fmt.Sprintf("DELETE FROM %s.%s WHERE time >= %d AND time < %d%s",
+		sel.from, paths.Wildcard, interval.StartUnixNano(), interval.EndUnixNano(), sel.with)
+
+	humanLabel := fmt.Sprintf("Iginx delete %s of the cpu series of a host", iginxuse.DeleteRangeDuration)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	n.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// SelectInto materializes the MAX of usage_user per minute of a random host
+// over 12 hours into new series, IGinX's form of SELECT ... INTO, e.g.
+//
+// INSERT INTO tsbs_materialized.host_1 (TIMESTAMP, max_usage_user)
+// VALUES (SELECT max(usage_user) FROM cpu.host_1.* GROUP [$TIME_START, $TIME_END) BY 1m)
+//
+// The series written are not removed when the benchmark series are deleted
+// before loading, only with 'clear-data'.
+//
+// Queries:
+// select-into
+func (n *Native) SelectInto(qi query.Query) {
+	interval := n.Interval.MustRandWindow(iginxuse.SelectIntoDuration)
+	hosts, err := n.GetRandomHosts(1)
+	panicIfErr(err)
+	sel, err := n.selectHosts(hosts)
+	panicIfErr(err)
+
+	sql := fmt.Sprintf("INSERT INTO %s.%s (TIMESTAMP, max_usage_user) VALUES (SELECT max(usage_user) FROM %s%s GROUP [%d, %d) BY 1m)",
+		materializedPrefix, paths.Sanitize(hosts[0]), sel.from, sel.with, interval.StartUnixNano(), interval.EndUnixNano())
+
+	humanLabel := fmt.Sprintf("Iginx materialize max of usage_user per minute of a host, random %s", iginxuse.SelectIntoDuration)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	n.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
@@ -0,0 +1,68 @@
+package iginx
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// TestNativeQueries pins the SQL of each native query, in the dialect of the
+// pinned IGinX client: SHOW TIME SERIES, TIMESTAMP and time
+func TestNativeQueries(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	g := &BaseGenerator{PathTemplate: "{measurement}.{hostname}.{field}"}
+	qg, err := g.NewIginx(start, end, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := qg.(*Native)
+
+	cases := []struct {
+		desc string
+		fill func(q query.Query)
+		want string
+	}{
+		{
+			desc: "sliding-window",
+			fill: func(q query.Query) { n.SlidingWindow(q, 2) },
+			want: "SELECT avg(host_9.usage_user), avg(host_3.usage_user) FROM cpu GROUP [1451628982646325489, 1451672182646325489) BY 5m, 1m",
+		},
+		{
+			desc: "value-filter",
+			fill: func(q query.Query) { n.ValueFilter(q, 2) },
+			want: "SELECT usage_user, usage_system FROM cpu.* WHERE (usage_user > 90.0 OR usage_system > 90.0) AND time >= 1451615832342805883 AND time < 1451619432342805883",
+		},
+		{
+			desc: "show-time-series",
+			fill: n.ShowTimeSeries,
+			want: "SHOW TIME SERIES cpu.host_9*.*",
+		},
+		{
+			desc: "cross-join",
+			fill: n.CrossJoin,
+			want: "SELECT cpu.host_5.usage_user, mem.host_5.used_percent FROM cpu.host_5, mem.host_5 WHERE cpu.host_5.time = mem.host_5.time AND cpu.host_5.time >= 1451672588303546563 AND cpu.host_5.time < 1451676188303546563 AND cpu.host_5.usage_user > 90.0 AND mem.host_5.used_percent > 90.0",
+		},
+		{
+			desc: "delete-range",
+			fill: n.DeleteRange,
+			want: "DELETE FROM cpu.host_1.* WHERE time >= 1451649222680080991 AND time < 1451649282680080991",
+		},
+		{
+			desc: "select-into",
+			fill: n.SelectInto,
+			want: "INSERT INTO tsbs_materialized.host_7 (TIMESTAMP, max_usage_user) VALUES (SELECT max(usage_user) FROM cpu.host_7 GROUP [1451631478487617828, 1451674678487617828) BY 1m)",
+		},
+	}
+
+	rand.Seed(123)
+	for _, c := range cases {
+		q := n.GenerateEmptyQuery()
+		c.fill(q)
+		if got := string(q.(*query.Iginx).SqlQuery); got != c.want {
+			t.Errorf("%s: incorrect query:\ngot\n%s\nwant\n%s", c.desc, got, c.want)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/blagojts/viper"
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iginx"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iot"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
 	"github.com/timescale/tsbs/internal/inputs"
@@ -51,6 +52,16 @@ var useCaseMatrix = map[string]map[string]utils.QueryFillerMaker{
 		iot.LabelDailyActivity:                 iot.NewDailyTruckActivity,
 		iot.LabelBreakdownFrequency:            iot.NewTruckBreakdownFrequency,
 	},
+	"iginx": {
+		iginx.LabelSlidingWindow + "-1": iginx.NewSlidingWindow(1),
+		iginx.LabelSlidingWindow + "-8": iginx.NewSlidingWindow(8),
+		iginx.LabelValueFilter + "-1":   iginx.NewValueFilter(1),
+		iginx.LabelValueFilter + "-5":   iginx.NewValueFilter(5),
+		iginx.LabelShowTimeSeries:       iginx.NewShowTimeSeries,
+		iginx.LabelCrossJoin:            iginx.NewCrossJoin,
+		iginx.LabelDeleteRange:          iginx.NewDeleteRange,
+		iginx.LabelSelectInto:           iginx.NewSelectInto,
+	},
 }
 
 var conf = &config.QueryGeneratorConfig{}
@@ -0,0 +1,79 @@
+// Package iginx holds the query types of the iginx use case, which exercise
+// features of IGinX the queries ported from the other databases do not.
+// It has no data of its own, the queries run on the devops data.
+package iginx
+
+import (
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+const (
+	// SlidingWindowDuration is how big the time range for SlidingWindow query is
+	SlidingWindowDuration = 12 * time.Hour
+	// ValueFilterDuration is how big the time range for ValueFilter query is
+	ValueFilterDuration = time.Hour
+	// CrossJoinDuration is how big the time range for CrossJoin query is
+	CrossJoinDuration = time.Hour
+	// DeleteRangeDuration is how big the time range for DeleteRange query is
+	DeleteRangeDuration = time.Minute
+	// SelectIntoDuration is how big the time range for SelectInto query is
+	SelectIntoDuration = 12 * time.Hour
+
+	// LabelSlidingWindow is the label prefix for queries of the sliding window variety
+	LabelSlidingWindow = "sliding-window"
+	// LabelValueFilter is the label prefix for queries of the value filter variety
+	LabelValueFilter = "value-filter"
+	// LabelShowTimeSeries is the label for the show-time-series query
+	LabelShowTimeSeries = "show-time-series"
+	// LabelCrossJoin is the label for the cross-join query
+	LabelCrossJoin = "cross-join"
+	// LabelDeleteRange is the label for the delete-range query
+	LabelDeleteRange = "delete-range"
+	// LabelSelectInto is the label for the select-into query
+	LabelSelectInto = "select-into"
+)
+
+// Core is the common component of all generators for the iginx use case,
+// the same as the one of devops since the queries run on its data
+type Core struct {
+	*devops.Core
+}
+
+// NewCore returns a new Core for the given time range and cardinality
+func NewCore(start, end time.Time, scale int) (*Core, error) {
+	c, err := devops.NewCore(start, end, scale)
+	return &Core{Core: c}, err
+}
+
+// SlidingWindowFiller is a type that can fill in a sliding window query
+type SlidingWindowFiller interface {
+	SlidingWindow(query.Query, int)
+}
+
+// ValueFilterFiller is a type that can fill in a value filter query
+type ValueFilterFiller interface {
+	ValueFilter(query.Query, int)
+}
+
+// ShowTimeSeriesFiller is a type that can fill in a show-time-series query
+type ShowTimeSeriesFiller interface {
+	ShowTimeSeries(query.Query)
+}
+
+// CrossJoinFiller is a type that can fill in a cross-join query
+type CrossJoinFiller interface {
+	CrossJoin(query.Query)
+}
+
+// DeleteRangeFiller is a type that can fill in a delete-range query
+type DeleteRangeFiller interface {
+	DeleteRange(query.Query)
+}
+
+// SelectIntoFiller is a type that can fill in a select-into query
+type SelectIntoFiller interface {
+	SelectInto(query.Query)
+}
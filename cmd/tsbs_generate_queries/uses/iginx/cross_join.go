@@ -0,0 +1,27 @@
+package iginx
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// CrossJoin produces a QueryFiller for the iginx cross-join case
+type CrossJoin struct {
+	core utils.QueryGenerator
+}
+
+// NewCrossJoin produces a new function that produces a new CrossJoin
+func NewCrossJoin(core utils.QueryGenerator) utils.QueryFiller {
+	return &CrossJoin{core: core}
+}
+
+// Fill fills in the query.Query with query details
+func (i *CrossJoin) Fill(q query.Query) query.Query {
+	fc, ok := i.core.(CrossJoinFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(i.core)
+	}
+	fc.CrossJoin(q)
+	return q
+}
@@ -0,0 +1,27 @@
+package iginx
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// DeleteRange produces a QueryFiller for the iginx delete-range case
+type DeleteRange struct {
+	core utils.QueryGenerator
+}
+
+// NewDeleteRange produces a new function that produces a new DeleteRange
+func NewDeleteRange(core utils.QueryGenerator) utils.QueryFiller {
+	return &DeleteRange{core: core}
+}
+
+// Fill fills in the query.Query with query details
+func (i *DeleteRange) Fill(q query.Query) query.Query {
+	fc, ok := i.core.(DeleteRangeFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(i.core)
+	}
+	fc.DeleteRange(q)
+	return q
+}
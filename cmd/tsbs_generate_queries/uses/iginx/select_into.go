@@ -0,0 +1,27 @@
+package iginx
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// SelectInto produces a QueryFiller for the iginx select-into case
+type SelectInto struct {
+	core utils.QueryGenerator
+}
+
+// NewSelectInto produces a new function that produces a new SelectInto
+func NewSelectInto(core utils.QueryGenerator) utils.QueryFiller {
+	return &SelectInto{core: core}
+}
+
+// Fill fills in the query.Query with query details
+func (i *SelectInto) Fill(q query.Query) query.Query {
+	fc, ok := i.core.(SelectIntoFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(i.core)
+	}
+	fc.SelectInto(q)
+	return q
+}
@@ -0,0 +1,27 @@
+package iginx
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// ShowTimeSeries produces a QueryFiller for the iginx show-time-series case
+type ShowTimeSeries struct {
+	core utils.QueryGenerator
+}
+
+// NewShowTimeSeries produces a new function that produces a new ShowTimeSeries
+func NewShowTimeSeries(core utils.QueryGenerator) utils.QueryFiller {
+	return &ShowTimeSeries{core: core}
+}
+
+// Fill fills in the query.Query with query details
+func (i *ShowTimeSeries) Fill(q query.Query) query.Query {
+	fc, ok := i.core.(ShowTimeSeriesFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(i.core)
+	}
+	fc.ShowTimeSeries(q)
+	return q
+}
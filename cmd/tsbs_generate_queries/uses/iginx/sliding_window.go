@@ -0,0 +1,33 @@
+package iginx
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// SlidingWindow produces a QueryFiller for the iginx sliding-window cases
+type SlidingWindow struct {
+	core  utils.QueryGenerator
+	hosts int
+}
+
+// NewSlidingWindow produces a new function that produces a new SlidingWindow
+func NewSlidingWindow(hosts int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &SlidingWindow{
+			core:  core,
+			hosts: hosts,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (i *SlidingWindow) Fill(q query.Query) query.Query {
+	fc, ok := i.core.(SlidingWindowFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(i.core)
+	}
+	fc.SlidingWindow(q, i.hosts)
+	return q
+}
@@ -0,0 +1,33 @@
+package iginx
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/common"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/pkg/query"
+)
+
+// ValueFilter produces a QueryFiller for the iginx value-filter cases
+type ValueFilter struct {
+	core    utils.QueryGenerator
+	metrics int
+}
+
+// NewValueFilter produces a new function that produces a new ValueFilter
+func NewValueFilter(metrics int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &ValueFilter{
+			core:    core,
+			metrics: metrics,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (i *ValueFilter) Fill(q query.Query) query.Query {
+	fc, ok := i.core.(ValueFilterFiller)
+	if !ok {
+		common.PanicUnimplementedQuery(i.core)
+	}
+	fc.ValueFilter(q, i.metrics)
+	return q
+}
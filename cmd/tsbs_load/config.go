@@ -29,6 +29,13 @@ type RunnerConfig struct {
 	InsertIntervals string `yaml:"insert-intervals" mapstructure:"insert-intervals"`
 	FlowControl     bool   `yaml:"flow-control" mapstructure:"flow-control"`
 	ChannelCapacity uint   `yaml:"channel-capacity" mapstructure:"channel-capacity"`
+	HDRLatencies    string `yaml:"hdr-latencies" mapstructure:"hdr-latencies"`
+	Duration        time.Duration
+	TargetRate      float64 `yaml:"target-rate" mapstructure:"target-rate"`
+	Checkpoint      string
+	Resume          bool
+	MetricsListen   string `yaml:"metrics-listen" mapstructure:"metrics-listen"`
+	Warmup          string
 }
 
 type DataSourceConfig struct {
@@ -67,6 +67,45 @@ func addLoaderRunnerFlags(fs *pflag.FlagSet) {
 			"Default 0 means that:\n\tif hash-workers=false then capacity = 5 * number of workers\n\t"+
 			"if hash-workers=true, then capacity = 5 for each worker",
 	)
+	fs.String(
+		"loader.runner.hdr-latencies",
+		"",
+		"Write the High Dynamic Range (HDR) Histogram of the batch insert latencies to this file",
+	)
+	fs.Duration(
+		"loader.runner.duration",
+		0,
+		"Time to load data for, stopping earlier at the limit or the end of the data (0 = no time limit)",
+	)
+	fs.Float64(
+		"loader.runner.target-rate",
+		0,
+		"Items (rows, or points for the formats with one point per item) per second to release to the workers on "+
+			"a fixed schedule, however fast they load them, reporting how far behind the schedule the load falls "+
+			"(0 = as fast as the workers load them)",
+	)
+	fs.String(
+		"loader.runner.checkpoint",
+		"",
+		"Keep the number of items loaded in this file as the load goes, to resume it from (needs flow-control=true)",
+	)
+	fs.String(
+		"loader.runner.warmup",
+		"",
+		"Number of items (e.g. 100000) or duration (e.g. 30s) at the start of the load left out of the post "+
+			"warm-up throughput and latency stats",
+	)
+	fs.String(
+		"loader.runner.metrics-listen",
+		"",
+		"Serve the metrics of the load on /metrics at this address (e.g. ':9090'), in the Prometheus format",
+	)
+	fs.Bool(
+		"loader.runner.resume",
+		false,
+		"Resume the load from the checkpoint file, skipping the items it loaded and keeping the existing data, "+
+			"or start it if there is no checkpoint file yet",
+	)
 }
 
 func addDataSourceFlags(fs *pflag.FlagSet) {
@@ -78,7 +117,7 @@ func addDataSourceFlags(fs *pflag.FlagSet) {
 	fs.String(
 		"data-source.file.location",
 		"./file-from-tsbs-generate-data",
-		"If data-source.type=FILE, load the data from this file location",
+		"If data-source.type=FILE, load the data from this file location, or a comma separated list or glob of files, possibly gzip or zstd compressed",
 	)
 	fs.String("data-source.simulator.use-case", "devops-generic", fmt.Sprintf("Use case to generate."))
 	fs.String("data-source.simulator.timestamp-start", defaultTimeStart, "Beginning timestamp (RFC3339).")
@@ -61,19 +61,26 @@ func parseRunnerConfig(v *viper.Viper) (*RunnerConfig, error) {
 
 func convertRunnerConfigToInternalRep(r *RunnerConfig) *load.BenchmarkRunnerConfig {
 	return &load.BenchmarkRunnerConfig{
-		DBName:          r.DBName,
-		BatchSize:       r.BatchSize,
-		Workers:         r.Workers,
-		Limit:           r.Limit,
-		DoLoad:          r.DoLoad,
-		DoCreateDB:      r.DoCreateDB,
-		DoAbortOnExist:  r.DoAbortOnExist,
-		ReportingPeriod: r.ReportingPeriod,
-		Seed:            r.Seed,
-		HashWorkers:     r.HashWorkers,
-		InsertIntervals: r.InsertIntervals,
-		NoFlowControl:   !r.FlowControl,
-		ChannelCapacity: r.ChannelCapacity,
+		DBName:           r.DBName,
+		BatchSize:        r.BatchSize,
+		Workers:          r.Workers,
+		Limit:            r.Limit,
+		DoLoad:           r.DoLoad,
+		DoCreateDB:       r.DoCreateDB,
+		DoAbortOnExist:   r.DoAbortOnExist,
+		ReportingPeriod:  r.ReportingPeriod,
+		Seed:             r.Seed,
+		HashWorkers:      r.HashWorkers,
+		InsertIntervals:  r.InsertIntervals,
+		NoFlowControl:    !r.FlowControl,
+		ChannelCapacity:  r.ChannelCapacity,
+		HDRLatenciesFile: r.HDRLatencies,
+		Duration:         r.Duration,
+		TargetRate:       r.TargetRate,
+		Checkpoint:       r.Checkpoint,
+		Resume:           r.Resume,
+		MetricsListen:    r.MetricsListen,
+		Warmup:           r.Warmup,
 	}
 }
 
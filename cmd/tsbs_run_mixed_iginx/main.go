@@ -0,0 +1,155 @@
+// tsbs_run_mixed_iginx loads IGinX with data from a file at a given write
+// rate while running queries on the data loaded so far, to measure the
+// latency of the queries under sustained ingestion.
+//
+// The queries are generated as they run, on the last --query-lookback of
+// the data up to its high-water mark: the latest timestamp up to which all
+// the data read was written.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blagojts/viper"
+	"github.com/spf13/pflag"
+	iginxq "github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/iginx"
+	"github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/load"
+	"github.com/timescale/tsbs/pkg/data/source"
+	"github.com/timescale/tsbs/pkg/targets/iginx"
+	"github.com/timescale/tsbs/pkg/targets/iginx/endpoints"
+)
+
+// mixedConfig holds the options of the queries run while loading
+type mixedConfig struct {
+	WriteRate     float64       `mapstructure:"write-rate"`
+	QueryWorkers  uint          `mapstructure:"query-workers"`
+	QueryTypes    string        `mapstructure:"query-types"`
+	QueryRate     float64       `mapstructure:"query-rate"`
+	QueryLookback time.Duration `mapstructure:"query-lookback"`
+	Scale         uint64        `mapstructure:"scale"`
+	FetchSize     int32         `mapstructure:"fetch-size"`
+}
+
+func addMixedFlags(fs *pflag.FlagSet) {
+	fs.Float64("write-rate", 0, "Rows written per second (0 = as fast as possible)")
+	fs.Uint("query-workers", 1, "Number of concurrent query clients")
+	fs.String("query-types", "single-groupby-1-1-1,lastpoint", "Comma separated query types run while loading, picked at random, choose from: "+strings.Join(queryTypeNames(), ", "))
+	fs.Float64("query-rate", 0, "Queries run per second by all the query clients (0 = as fast as the clients run them)")
+	fs.Duration("query-lookback", 12*time.Hour, "Time range of the loaded data the queries are generated on, ending at its high-water mark. Must be at least the time range of the query types.")
+	fs.Uint64("scale", 1, "Scale of the data loaded, the number of hosts of the devops data")
+	fs.Int32("fetch-size", 100, "Number of rows fetched from IGinX at a time")
+}
+
+// Parse args:
+func initProgramOptions() (*iginx.SpecificConfig, *load.BenchmarkRunnerConfig, *mixedConfig) {
+	target := iginx.NewTarget()
+	loaderConf := load.BenchmarkRunnerConfig{}
+	loaderConf.AddToFlagSet(pflag.CommandLine)
+	target.TargetSpecificFlags("", pflag.CommandLine)
+	addMixedFlags(pflag.CommandLine)
+	pflag.Parse()
+
+	err := utils.SetupConfigFile()
+
+	if err != nil {
+		panic(fmt.Errorf("fatal error config file: %s", err))
+	}
+
+	if err := viper.Unmarshal(&loaderConf); err != nil {
+		panic(fmt.Errorf("unable to decode config: %s", err))
+	}
+	var mixedConf mixedConfig
+	if err := viper.Unmarshal(&mixedConf); err != nil {
+		panic(fmt.Errorf("unable to decode config: %s", err))
+	}
+	if mixedConf.QueryWorkers == 0 {
+		log.Fatal("query-workers must be positive")
+	}
+	if mixedConf.FetchSize <= 0 {
+		log.Fatal("fetch-size must be positive")
+	}
+
+	conf := iginx.SpecificConfig{}
+	conf.ConnStr = viper.GetString("connStr")
+	conf.PathTemplate = viper.GetString("path-template")
+	conf.Tagged = viper.GetBool("tagged")
+	conf.MaxRetries = viper.GetInt("max-retries")
+	conf.RetryBackoff = viper.GetDuration("retry-backoff")
+	conf.RetryMaxBackoff = viper.GetDuration("retry-max-backoff")
+	conf.ClearData = viper.GetBool("clear-data")
+	conf.SetupConfig = viper.GetString("setup-config")
+
+	return &conf, &loaderConf, &mixedConf
+}
+
+func main() {
+	conf, loaderConf, mixedConf := initProgramOptions()
+
+	seed := loaderConf.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rand.Seed(seed)
+
+	benchmark, err := iginx.NewBenchmark(conf, &source.DataSourceConfig{
+		Type: source.FileDataSourceType,
+		File: &source.FileDataSourceConfig{Location: loaderConf.FileName},
+	})
+	if err != nil {
+		panic(err)
+	}
+	mixed := newMixedBenchmark(benchmark, mixedConf.WriteRate)
+
+	var types []string
+	for _, t := range strings.Split(mixedConf.QueryTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	base := &iginxq.BaseGenerator{PathTemplate: conf.PathTemplate, Tagged: conf.Tagged}
+	generator, err := newQueryGenerator(base, int(mixedConf.Scale), types, mixedConf.QueryLookback, mixed.mark)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the write rate and query latencies are reported together, instead
+	// of by the loader
+	reportingPeriod := loaderConf.ReportingPeriod
+	loaderConf.ReportingPeriod = 0
+	loader := load.GetBenchmarkRunner(*loaderConf)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stats := newQueryStats()
+	pool := endpoints.NewPool(conf.ConnectionSocketList(), endpoints.DefaultDownTime)
+	queries := make(chan *typedQuery, mixedConf.QueryWorkers)
+	wg := &sync.WaitGroup{}
+	wg.Add(int(mixedConf.QueryWorkers))
+	for i := uint(0); i < mixedConf.QueryWorkers; i++ {
+		go queryWorker(ctx, wg, pool, queries, stats, mixedConf.FetchSize)
+	}
+	go generate(ctx, generator, queries, mixedConf.QueryRate)
+
+	reportDone := make(chan struct{})
+	if reportingPeriod > 0 {
+		go report(os.Stdout, reportingPeriod, mixed, stats, reportDone)
+	}
+
+	loader.RunBenchmark(mixed)
+
+	// the queries stop with the load
+	cancel()
+	wg.Wait()
+	close(reportDone)
+	stats.summary(os.Stdout)
+	if summary := pool.Summary(); summary != "" {
+		fmt.Printf("query endpoints:\n%s\n", summary)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	iginxq "github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/iginx"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/iginx"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	internalutils "github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/endpoints"
+)
+
+// queryTypes are the query types that can run while loading: the ones of
+// tsbs_generate_queries reading the devops data, except groupby-orderby-limit
+// which reads from the start of the data, and the read-only ones of the
+// iginx use case
+var queryTypes = map[string]utils.QueryFillerMaker{
+	devops.LabelSingleGroupby + "-1-1-1":  devops.NewSingleGroupby(1, 1, 1),
+	devops.LabelSingleGroupby + "-1-1-12": devops.NewSingleGroupby(1, 1, 12),
+	devops.LabelSingleGroupby + "-1-8-1":  devops.NewSingleGroupby(1, 8, 1),
+	devops.LabelSingleGroupby + "-5-1-1":  devops.NewSingleGroupby(5, 1, 1),
+	devops.LabelSingleGroupby + "-5-1-12": devops.NewSingleGroupby(5, 1, 12),
+	devops.LabelSingleGroupby + "-5-8-1":  devops.NewSingleGroupby(5, 8, 1),
+	devops.LabelMaxAll + "-1":             devops.NewMaxAllCPU(1, devops.MaxAllDuration),
+	devops.LabelMaxAll + "-8":             devops.NewMaxAllCPU(8, devops.MaxAllDuration),
+	devops.LabelMaxAll + "-32-24":         devops.NewMaxAllCPU(32, 24*time.Hour),
+	devops.LabelDoubleGroupby + "-1":      devops.NewGroupBy(1),
+	devops.LabelDoubleGroupby + "-5":      devops.NewGroupBy(5),
+	devops.LabelDoubleGroupby + "-all":    devops.NewGroupBy(devops.GetCPUMetricsLen()),
+	devops.LabelHighCPU + "-all":          devops.NewHighCPU(0),
+	devops.LabelHighCPU + "-1":            devops.NewHighCPU(1),
+	devops.LabelLastpoint:                 devops.NewLastPointPerHost,
+	iginx.LabelSlidingWindow + "-1":       iginx.NewSlidingWindow(1),
+	iginx.LabelSlidingWindow + "-8":       iginx.NewSlidingWindow(8),
+	iginx.LabelValueFilter + "-1":         iginx.NewValueFilter(1),
+	iginx.LabelValueFilter + "-5":         iginx.NewValueFilter(5),
+	iginx.LabelShowTimeSeries:             iginx.NewShowTimeSeries,
+	iginx.LabelCrossJoin:                  iginx.NewCrossJoin,
+}
+
+// queryTypeNames returns the names of the query types, sorted
+func queryTypeNames() []string {
+	names := make([]string, 0, len(queryTypes))
+	for name := range queryTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// queryGenerator generates the queries on the last lookback of the data
+// loaded, up to the high-water mark when the query is generated
+type queryGenerator struct {
+	gen      *iginxq.Native
+	fillers  []utils.QueryFiller
+	names    []string
+	lookback time.Duration
+	mark     *watermark
+}
+
+func newQueryGenerator(base *iginxq.BaseGenerator, scale int, types []string, lookback time.Duration, mark *watermark) (*queryGenerator, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no query types given, choose from: %s", strings.Join(queryTypeNames(), ", "))
+	}
+	if lookback <= 0 {
+		return nil, fmt.Errorf("query-lookback must be positive")
+	}
+	// the time range is replaced before each query
+	epoch := time.Unix(0, 0).UTC()
+	qg, err := base.NewIginx(epoch, epoch.Add(lookback), scale)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &queryGenerator{gen: qg.(*iginxq.Native), lookback: lookback, mark: mark}
+	for _, name := range types {
+		maker, ok := queryTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown query type %s, choose from: %s", name, strings.Join(queryTypeNames(), ", "))
+		}
+		g.fillers = append(g.fillers, maker(g.gen))
+		g.names = append(g.names, name)
+	}
+	return g, nil
+}
+
+// typedQuery is a generated query along with its query type, which labels
+// it in the report
+type typedQuery struct {
+	queryType string
+	*query.Iginx
+}
+
+// next generates a query of a random type, false if less than the lookback
+// of data is loaded yet
+func (g *queryGenerator) next() (*typedQuery, bool, error) {
+	first, mark, ok := g.mark.get()
+	if !ok || time.Duration(mark-first) < g.lookback {
+		return nil, false, nil
+	}
+	end := time.Unix(0, mark+1).UTC()
+	interval, err := internalutils.NewTimeInterval(end.Add(-g.lookback), end)
+	if err != nil {
+		return nil, false, err
+	}
+	g.gen.Interval = interval
+
+	i := rand.Intn(len(g.fillers))
+	q := query.NewIginx()
+	if err := fill(g.fillers[i], q); err != nil {
+		return nil, false, fmt.Errorf("cannot generate query %s, the query-lookback may be shorter than its time range: %v", g.names[i], err)
+	}
+	return &typedQuery{queryType: g.names[i], Iginx: q}, true, nil
+}
+
+// fill fills in a query, recovering from the panics of the generators
+func fill(filler utils.QueryFiller, q query.Query) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	filler.Fill(q)
+	return nil
+}
+
+// generate sends the generated queries to out at rate queries per second,
+// as fast as the workers run them if rate is 0, until ctx is done
+func generate(ctx context.Context, g *queryGenerator, out chan<- *typedQuery, rate float64) {
+	defer close(out)
+	waiting := false
+	var sent uint64
+	var start time.Time
+	for ctx.Err() == nil {
+		if rate > 0 && sent > 0 {
+			// the query is generated when it is due, on the data loaded
+			// by then
+			due := start.Add(time.Duration(float64(sent) / rate * float64(time.Second)))
+			sleep(ctx, time.Until(due))
+		}
+		q, ok, err := g.next()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			if !waiting {
+				log.Printf("waiting for %s of data to be loaded before querying\n", g.lookback)
+				waiting = true
+			}
+			sleep(ctx, 100*time.Millisecond)
+			continue
+		}
+		if waiting {
+			log.Println("querying")
+			waiting = false
+		}
+
+		if sent == 0 {
+			start = time.Now()
+		}
+		select {
+		case out <- q:
+			sent++
+		case <-ctx.Done():
+			q.Release()
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// queryWorker runs the queries on a session of its own until they are all
+// sent or ctx is done, recording their latency
+func queryWorker(ctx context.Context, wg *sync.WaitGroup, pool *endpoints.Pool, queries <-chan *typedQuery, stats *queryStats, fetchSize int32) {
+	defer wg.Done()
+	session := pool.NewSession()
+	defer session.Close()
+
+	for q := range queries {
+		if ctx.Err() != nil {
+			// the load is over, the queries left are not run
+			q.Release()
+			continue
+		}
+		sql := string(q.SqlQuery)
+		start := time.Now()
+		err := session.Do(func(s *client_v2.Session) error {
+			return runQuery(s, sql, fetchSize)
+		})
+		stats.record(q.queryType, time.Since(start), err)
+		q.Release()
+	}
+}
+
+// runQuery runs a query and reads all its rows
+func runQuery(session *client_v2.Session, sql string, fetchSize int32) error {
+	cursor, err := session.ExecuteQuery(sql, fetchSize)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for {
+		hasMore, err := cursor.HasMore()
+		if err != nil || !hasMore {
+			return err
+		}
+		if _, err := cursor.NextRow(); err != nil {
+			return err
+		}
+	}
+}
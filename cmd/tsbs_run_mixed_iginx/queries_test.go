@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math/rand"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	iginxq "github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/iginx"
+)
+
+func TestQueryGeneratorWindow(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	w := newWatermark()
+	base := &iginxq.BaseGenerator{PathTemplate: "{measurement}.{hostname}.{field}"}
+	g, err := newQueryGenerator(base, 10, []string{"single-groupby-1-1-1"}, 2*time.Hour, w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b := &trackedBatch{last: start + int64(time.Hour)}
+	w.read(b, start)
+	w.write(b)
+	if _, ok, err := g.next(); ok || err != nil {
+		t.Fatalf("unexpected query with less than the lookback loaded: %v", err)
+	}
+
+	b = &trackedBatch{last: start + int64(5*time.Hour)}
+	w.read(b, start+int64(time.Hour)+1)
+	w.write(b)
+	rand.Seed(123)
+	re := regexp.MustCompile(`GROUP \[(\d+), (\d+)\)`)
+	for i := 0; i < 10; i++ {
+		q, ok, err := g.next()
+		if !ok || err != nil {
+			t.Fatalf("expected a query: %v", err)
+		}
+		if q.queryType != "single-groupby-1-1-1" {
+			t.Errorf("incorrect query type: %s", q.queryType)
+		}
+		m := re.FindStringSubmatch(string(q.SqlQuery))
+		if m == nil {
+			t.Fatalf("no time range in %s", q.SqlQuery)
+		}
+		from, _ := strconv.ParseInt(m[1], 10, 64)
+		to, _ := strconv.ParseInt(m[2], 10, 64)
+		// the window is in the last 2 hours up to the mark
+		if from < start+int64(3*time.Hour) || to > start+int64(5*time.Hour)+1 {
+			t.Errorf("window [%d, %d) out of the lookback of the mark", from, to)
+		}
+	}
+}
+
+func TestQueryGeneratorErrors(t *testing.T) {
+	base := &iginxq.BaseGenerator{}
+	if _, err := newQueryGenerator(base, 10, []string{"unknown"}, time.Hour, newWatermark()); err == nil {
+		t.Errorf("expected an error for an unknown query type")
+	}
+	if _, err := newQueryGenerator(base, 10, nil, time.Hour, newWatermark()); err == nil {
+		t.Errorf("expected an error without query types")
+	}
+
+	// the window of high-cpu is longer than the lookback
+	w := newWatermark()
+	b := &trackedBatch{last: int64(2 * time.Hour)}
+	w.read(b, 1)
+	w.write(b)
+	g, err := newQueryGenerator(base, 10, []string{"high-cpu-1"}, time.Hour, w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := g.next(); err == nil {
+		t.Errorf("expected an error for a lookback too short")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// latencies are recorded in microseconds, up to an hour
+const (
+	maxLatency         = int64(time.Hour / time.Microsecond)
+	microsPerMilli     = 1e3
+	latencySignificant = 3
+)
+
+// labelStats are the latencies and errors of the queries of a query type,
+// over the whole run and since the previous report
+type labelStats struct {
+	total, period             *hdrhistogram.Histogram
+	errors, periodErrors      uint64
+	periodQueries, allQueries uint64
+}
+
+func newLabelStats() *labelStats {
+	return &labelStats{
+		total:  hdrhistogram.New(1, maxLatency, latencySignificant),
+		period: hdrhistogram.New(1, maxLatency, latencySignificant),
+	}
+}
+
+// queryStats collects the latencies of the queries labeled by their query
+// type, the labels of the queries themselves contain commas
+type queryStats struct {
+	mu     sync.Mutex
+	labels map[string]*labelStats
+}
+
+func newQueryStats() *queryStats {
+	return &queryStats{labels: make(map[string]*labelStats)}
+}
+
+// record records the latency of a query, or its error
+func (s *queryStats) record(label string, took time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls, ok := s.labels[label]
+	if !ok {
+		ls = newLabelStats()
+		s.labels[label] = ls
+	}
+	ls.periodQueries++
+	ls.allQueries++
+	if err != nil {
+		if ls.errors == 0 {
+			log.Printf("query %s failed, the next errors of the label are only counted: %v\n", label, err)
+		}
+		ls.errors++
+		ls.periodErrors++
+		return
+	}
+	micros := int64(took / time.Microsecond)
+	_ = ls.total.RecordValue(micros)
+	_ = ls.period.RecordValue(micros)
+}
+
+// periodLine is the report of the queries of a label since the previous
+// report
+type periodLine struct {
+	label          string
+	queries        uint64
+	errors         uint64
+	mean, p50, p99 float64
+	max            float64
+}
+
+// period returns the report of each label since the previous call, sorted
+// by label, and starts a new period
+func (s *queryStats) period() []periodLine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := make([]periodLine, 0, len(s.labels))
+	for label, ls := range s.labels {
+		lines = append(lines, periodLine{
+			label:   label,
+			queries: ls.periodQueries,
+			errors:  ls.periodErrors,
+			mean:    ls.period.Mean() / microsPerMilli,
+			p50:     float64(ls.period.ValueAtQuantile(50)) / microsPerMilli,
+			p99:     float64(ls.period.ValueAtQuantile(99)) / microsPerMilli,
+			max:     float64(ls.period.Max()) / microsPerMilli,
+		})
+		ls.period.Reset()
+		ls.periodQueries, ls.periodErrors = 0, 0
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].label < lines[j].label })
+	return lines
+}
+
+// summary writes the latencies of each label over the whole run
+func (s *queryStats) summary(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	labels := make([]string, 0, len(s.labels))
+	for label := range s.labels {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	fmt.Fprintf(w, "\nQuery summary:\n")
+	if len(labels) == 0 {
+		fmt.Fprintf(w, "no queries ran\n")
+	}
+	for _, label := range labels {
+		ls := s.labels[label]
+		h := ls.total
+		fmt.Fprintf(w, "%s:\n", label)
+		fmt.Fprintf(w, "min: %8.2fms, med: %8.2fms, mean: %8.2fms, p99: %8.2fms, max: %7.2fms, count: %d, errors: %d\n",
+			float64(h.Min())/microsPerMilli,
+			float64(h.ValueAtQuantile(50))/microsPerMilli,
+			h.Mean()/microsPerMilli,
+			float64(h.ValueAtQuantile(99))/microsPerMilli,
+			float64(h.Max())/microsPerMilli,
+			ls.allQueries, ls.errors)
+	}
+}
+
+const reportHeader = "time,per. row/s,row total,high-water mark,query type,per. queries,per. errors,per. mean ms,per. p50 ms,per. p99 ms,per. max ms\n"
+
+// report writes every period the rows written and the high-water mark of
+// the data, along with the latencies of the queries of each label, until
+// done is closed
+func report(w io.Writer, period time.Duration, b *mixedBenchmark, stats *queryStats, done <-chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	prevTime := time.Now()
+	prevRows := uint64(0)
+
+	fmt.Fprint(w, reportHeader)
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			rows := atomic.LoadUint64(&b.rows)
+			rowRate := float64(rows-prevRows) / now.Sub(prevTime).Seconds()
+			mark := "-"
+			if _, hw, ok := b.mark.get(); ok {
+				mark = time.Unix(0, hw).UTC().Format(time.RFC3339)
+			}
+			writePeriod(w, now, rowRate, rows, mark, stats.period())
+			prevTime, prevRows = now, rows
+		}
+	}
+}
+
+// writePeriod writes the report of a period, a line per query label
+func writePeriod(w io.Writer, now time.Time, rowRate float64, rows uint64, mark string, lines []periodLine) {
+	prefix := fmt.Sprintf("%d,%0.2f,%d,%s", now.Unix(), rowRate, rows, mark)
+	if len(lines) == 0 {
+		fmt.Fprintf(w, "%s,-,-,-,-,-,-,-\n", prefix)
+		return
+	}
+	for _, l := range lines {
+		if l.queries == l.errors {
+			fmt.Fprintf(w, "%s,%s,%d,%d,-,-,-,-\n", prefix, l.label, l.queries, l.errors)
+			continue
+		}
+		fmt.Fprintf(w, "%s,%s,%d,%d,%0.2f,%0.2f,%0.2f,%0.2f\n",
+			prefix, l.label, l.queries, l.errors, l.mean, l.p50, l.p99, l.max)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryStatsPeriod(t *testing.T) {
+	// the latencies are below 2ms, recorded exactly with 3 significant digits
+	s := newQueryStats()
+	s.record("lastpoint", time.Millisecond, nil)
+	s.record("lastpoint", 2*time.Millisecond, nil)
+	s.record("cross-join", time.Second, errors.New("failed"))
+
+	var buf bytes.Buffer
+	now := time.Unix(100, 0)
+	writePeriod(&buf, now, 1000, 5000, "2016-01-01T00:00:00Z", s.period())
+	want := "100,1000.00,5000,2016-01-01T00:00:00Z,cross-join,1,1,-,-,-,-\n" +
+		"100,1000.00,5000,2016-01-01T00:00:00Z,lastpoint,2,0,1.50,1.00,2.00,2.00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect period:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	// a new period starts after each report
+	buf.Reset()
+	s.record("lastpoint", 1500*time.Microsecond, nil)
+	writePeriod(&buf, now, 0, 5000, "-", s.period())
+	want = "100,0.00,5000,-,cross-join,0,0,-,-,-,-\n" +
+		"100,0.00,5000,-,lastpoint,1,0,1.50,1.50,1.50,1.50\n"
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect period:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	buf.Reset()
+	writePeriod(&buf, now, 0, 0, "-", newQueryStats().period())
+	if got, want := buf.String(), "100,0.00,0,-,-,-,-,-,-,-,-\n"; got != want {
+		t.Errorf("incorrect empty period: got %s want %s", got, want)
+	}
+}
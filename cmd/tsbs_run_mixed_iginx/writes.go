@@ -0,0 +1,207 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/iginx"
+)
+
+// watermark tracks the high-water mark of the loaded data: the latest
+// timestamp up to which all the data read was written. The data is read in
+// time order, so the mark is bounded by the earliest point still in flight.
+type watermark struct {
+	mu sync.Mutex
+	// first is the timestamp of the first point read
+	first   int64
+	started bool
+	// pending holds the earliest timestamp of each batch read and not
+	// written yet
+	pending map[*trackedBatch]int64
+	// written is the latest timestamp of the written batches
+	written    int64
+	hasWritten bool
+}
+
+func newWatermark() *watermark {
+	return &watermark{pending: make(map[*trackedBatch]int64)}
+}
+
+// read records the first point of a batch, read at timestamp
+func (w *watermark) read(b *trackedBatch, timestamp int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		w.first = timestamp
+		w.started = true
+	}
+	w.pending[b] = timestamp
+}
+
+// write records that a batch was written, up to the latest timestamp
+func (w *watermark) write(b *trackedBatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.pending, b)
+	if !w.hasWritten || b.last > w.written {
+		w.written = b.last
+	}
+	w.hasWritten = true
+}
+
+// get returns the first timestamp read and the high-water mark, false if no
+// data was written yet
+func (w *watermark) get() (first, mark int64, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	mark = w.written
+	for _, earliest := range w.pending {
+		if earliest-1 < mark {
+			mark = earliest - 1
+		}
+	}
+	return w.first, mark, w.hasWritten && mark >= w.first
+}
+
+// pacedDataSource releases the items of a data source at a fixed rate of
+// items per second, as fast as they are read if rate is 0
+type pacedDataSource struct {
+	targets.DataSource
+	rate float64
+
+	start time.Time
+	items uint64
+	// sleep is replaced in tests
+	sleep func(time.Duration)
+}
+
+func (d *pacedDataSource) NextItem() data.LoadedPoint {
+	if d.rate > 0 {
+		now := time.Now()
+		if d.items == 0 {
+			d.start = now
+		}
+		due := d.start.Add(time.Duration(float64(d.items) / d.rate * float64(time.Second)))
+		if wait := due.Sub(now); wait > 0 {
+			d.sleep(wait)
+		}
+	}
+	d.items++
+	return d.DataSource.NextItem()
+}
+
+// trackedBatch is a batch of the target recording the time range of its
+// points for the watermark
+type trackedBatch struct {
+	targets.Batch
+	mark *watermark
+	last int64
+}
+
+func (b *trackedBatch) Append(item data.LoadedPoint) {
+	timestamp, err := iginx.Timestamp(item)
+	if err != nil {
+		log.Fatalf("cannot track the loaded data: %v", err)
+	}
+	if b.Len() == 0 {
+		b.mark.read(b, timestamp)
+		b.last = timestamp
+	} else if timestamp > b.last {
+		b.last = timestamp
+	}
+	b.Batch.Append(item)
+}
+
+type trackedFactory struct {
+	targets.BatchFactory
+	mark *watermark
+}
+
+func (f *trackedFactory) New() targets.Batch {
+	return &trackedBatch{Batch: f.BatchFactory.New(), mark: f.mark}
+}
+
+// trackedProcessor writes the batches with the processor of the target,
+// then advances the watermark and counts the rows written for the report.
+// A batch that failed stays pending, so the watermark stops before it.
+type trackedProcessor struct {
+	targets.Processor
+	mark *watermark
+	rows *uint64
+	// failures holds the write failures of the processor of the target
+	// since the previous call to Failures
+	failures targets.WriteFailures
+}
+
+func (p *trackedProcessor) ProcessBatch(b targets.Batch, doLoad bool) (uint64, uint64) {
+	tb := b.(*trackedBatch)
+	metrics, rows := p.Processor.ProcessBatch(tb.Batch, doLoad)
+	failed := false
+	if fc, ok := p.Processor.(targets.ProcessorFailureCounter); ok {
+		f := fc.Failures()
+		p.failures.Batches += f.Batches
+		p.failures.Metrics += f.Metrics
+		p.failures.Rows += f.Rows
+		p.failures.Retries += f.Retries
+		failed = f.Batches > 0
+	}
+	if !failed {
+		p.mark.write(tb)
+	}
+	atomic.AddUint64(p.rows, rows)
+	return metrics, rows
+}
+
+func (p *trackedProcessor) Close(doLoad bool) {
+	if c, ok := p.Processor.(targets.ProcessorCloser); ok {
+		c.Close(doLoad)
+	}
+}
+
+func (p *trackedProcessor) Failures() targets.WriteFailures {
+	f := p.failures
+	p.failures = targets.WriteFailures{}
+	return f
+}
+
+// mixedBenchmark loads the data of the IGinX benchmark at the write rate,
+// tracking the high-water mark of the data written
+type mixedBenchmark struct {
+	// rows is first to be aligned for atomic operations
+	rows uint64
+	targets.Benchmark
+	source targets.DataSource
+	mark   *watermark
+}
+
+func newMixedBenchmark(b targets.Benchmark, rate float64) *mixedBenchmark {
+	return &mixedBenchmark{
+		Benchmark: b,
+		source:    &pacedDataSource{DataSource: b.GetDataSource(), rate: rate, sleep: time.Sleep},
+		mark:      newWatermark(),
+	}
+}
+
+func (b *mixedBenchmark) GetDataSource() targets.DataSource {
+	return b.source
+}
+
+func (b *mixedBenchmark) GetBatchFactory() targets.BatchFactory {
+	return &trackedFactory{BatchFactory: b.Benchmark.GetBatchFactory(), mark: b.mark}
+}
+
+func (b *mixedBenchmark) GetProcessor() targets.Processor {
+	return &trackedProcessor{Processor: b.Benchmark.GetProcessor(), mark: b.mark, rows: &b.rows}
+}
+
+// Summary returns the summary of the IGinX benchmark
+func (b *mixedBenchmark) Summary() string {
+	if bs, ok := b.Benchmark.(targets.BenchmarkSummarizer); ok {
+		return bs.Summary()
+	}
+	return ""
+}
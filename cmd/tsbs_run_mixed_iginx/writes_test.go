@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+func TestWatermark(t *testing.T) {
+	w := newWatermark()
+	if _, _, ok := w.get(); ok {
+		t.Fatalf("unexpected mark before any write")
+	}
+
+	b1, b2, b3 := &trackedBatch{last: 19}, &trackedBatch{last: 29}, &trackedBatch{last: 39}
+	w.read(b1, 10)
+	w.read(b2, 20)
+	w.read(b3, 30)
+	// the later batches are written first, the mark stays before b1
+	w.write(b3)
+	w.write(b2)
+	if _, _, ok := w.get(); ok {
+		t.Errorf("unexpected mark while the first batch is pending")
+	}
+	w.write(b1)
+	if first, mark, ok := w.get(); !ok || first != 10 || mark != 39 {
+		t.Errorf("incorrect mark: got %d, %d, %v want 10, 39, true", first, mark, ok)
+	}
+
+	b4 := &trackedBatch{last: 49}
+	w.read(b4, 40)
+	if _, mark, _ := w.get(); mark != 39 {
+		t.Errorf("incorrect mark with a pending batch: got %d want 39", mark)
+	}
+}
+
+type countingDataSource struct{ items int }
+
+func (d *countingDataSource) NextItem() data.LoadedPoint {
+	d.items++
+	return data.NewLoadedPoint(d.items)
+}
+
+func (d *countingDataSource) Headers() *common.GeneratedDataHeaders { return nil }
+
+func TestPacedDataSource(t *testing.T) {
+	var last time.Duration
+	d := &pacedDataSource{
+		DataSource: &countingDataSource{},
+		rate:       1000,
+		sleep:      func(d time.Duration) { last = d },
+	}
+	for i := 0; i < 101; i++ {
+		d.NextItem()
+	}
+	// the sleeps do not pass, so the 101st item is due 100ms after the
+	// first one at 1000 items per second, minus the time spent reading
+	if last < 90*time.Millisecond || last > 100*time.Millisecond {
+		t.Errorf("incorrect wait for the last item: got %v want about 100ms", last)
+	}
+
+	d = &pacedDataSource{DataSource: &countingDataSource{}, sleep: func(time.Duration) { t.Fatal("unexpected sleep") }}
+	d.NextItem()
+	d.NextItem()
+}
+
+// failingProcessor fails the writes of the batches in fail
+type failingProcessor struct {
+	fail     map[targets.Batch]bool
+	failures targets.WriteFailures
+}
+
+func (p *failingProcessor) Init(int, bool, bool) {}
+
+func (p *failingProcessor) ProcessBatch(b targets.Batch, _ bool) (uint64, uint64) {
+	if p.fail[b] {
+		p.failures.Batches++
+		p.failures.Rows++
+		return 0, 0
+	}
+	return 1, 1
+}
+
+func (p *failingProcessor) Failures() targets.WriteFailures {
+	f := p.failures
+	p.failures = targets.WriteFailures{}
+	return f
+}
+
+func TestTrackedProcessorFailedBatch(t *testing.T) {
+	w := newWatermark()
+	b1, b2 := &trackedBatch{last: 19}, &trackedBatch{last: 29}
+	w.read(b1, 10)
+	w.read(b2, 20)
+	b1.Batch, b2.Batch = &testBatch{}, &testBatch{}
+	var rows uint64
+	p := &trackedProcessor{
+		Processor: &failingProcessor{fail: map[targets.Batch]bool{b2.Batch: true}},
+		mark:      w,
+		rows:      &rows,
+	}
+	p.ProcessBatch(b1, true)
+	p.ProcessBatch(b2, true)
+	// the failed batch stays pending, the mark stops before it
+	if _, mark, ok := w.get(); !ok || mark != 19 {
+		t.Errorf("incorrect mark after a failed batch: got %d, %v want 19, true", mark, ok)
+	}
+	if f := p.Failures(); f.Batches != 1 || f.Rows != 1 {
+		t.Errorf("incorrect failures: got %+v want 1 batch and 1 row", f)
+	}
+	if f := p.Failures(); f.Batches != 0 {
+		t.Errorf("failures not reset: got %+v", f)
+	}
+	if rows != 1 {
+		t.Errorf("incorrect rows written: got %d want 1", rows)
+	}
+}
+
+type testBatch struct{ n uint }
+
+func (b *testBatch) Len() uint               { return b.n }
+func (b *testBatch) Append(data.LoadedPoint) { b.n++ }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// plan holds the plans IGinX printed for a query with EXPLAIN, and with
+// EXPLAIN PHYSICAL along with the time spent in each operator. Each row of
+// a plan is a JSON array.
+type plan struct {
+	ID       uint64            `json:"id"`
+	Label    string            `json:"label"`
+	Query    string            `json:"query"`
+	Logical  []json.RawMessage `json:"logical,omitempty"`
+	Physical []json.RawMessage `json:"physical,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// planSet collects the plans of a run. Either the first query of each label
+// is explained, or the first limit queries.
+type planSet struct {
+	limit    int
+	physical bool
+
+	mu        sync.Mutex
+	plans     map[uint64]*plan
+	explained map[string]bool
+	claimed   int
+}
+
+func newPlanSet(limit int, physical bool) *planSet {
+	return &planSet{
+		limit:     limit,
+		physical:  physical,
+		plans:     make(map[uint64]*plan),
+		explained: make(map[string]bool),
+	}
+}
+
+// claim reports whether the query of the given label is to be explained,
+// the workers then explain it once
+func (s *planSet) claim(label string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit > 0 {
+		if s.claimed >= s.limit {
+			return false
+		}
+	} else if s.explained[label] {
+		return false
+	}
+	s.explained[label] = true
+	s.claimed++
+	return true
+}
+
+// statements returns the statements explaining a query
+func (s *planSet) statements(query string) []string {
+	statements := []string{"EXPLAIN " + query}
+	if s.physical {
+		statements = append(statements, "EXPLAIN PHYSICAL "+query)
+	}
+	return statements
+}
+
+// add adds the plan of a query from the rows of its statements, or the
+// error explaining it
+func (s *planSet) add(id uint64, label, query string, results [][][]interface{}, err error) {
+	p := &plan{ID: id, Label: label, Query: query}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	for i, rows := range results {
+		encoded := make([]json.RawMessage, 0, len(rows))
+		for _, row := range rows {
+			raw, err := json.Marshal(row)
+			if err != nil {
+				p.Error = fmt.Sprintf("cannot encode plan row: %v", err)
+				break
+			}
+			encoded = append(encoded, raw)
+		}
+		if i == 0 {
+			p.Logical = encoded
+		} else {
+			p.Physical = encoded
+		}
+	}
+	s.mu.Lock()
+	s.plans[id] = p
+	s.mu.Unlock()
+}
+
+// write writes the plans ordered by query ID, a JSON object per line, so
+// the plans of two IGinX versions can be compared with diff
+func (s *planSet) write(w io.Writer) error {
+	ids := make([]uint64, 0, len(s.plans))
+	for id := range s.plans {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, id := range ids {
+		if err := enc.Encode(s.plans[id]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
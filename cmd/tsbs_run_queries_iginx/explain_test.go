@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPlanSetClaim(t *testing.T) {
+	labels := []string{"a", "b", "a", "c", "b"}
+	cases := []struct {
+		desc  string
+		limit int
+		want  []bool
+	}{
+		{
+			desc: "first of each label",
+			want: []bool{true, true, false, true, false},
+		},
+		{
+			desc:  "first two queries",
+			limit: 2,
+			want:  []bool{true, true, false, false, false},
+		},
+	}
+	for _, c := range cases {
+		s := newPlanSet(c.limit, false)
+		for i, label := range labels {
+			if got := s.claim(label); got != c.want[i] {
+				t.Errorf("%s: incorrect claim of query %d: got %v want %v", c.desc, i, got, c.want[i])
+			}
+		}
+	}
+}
+
+func TestPlanSetWrite(t *testing.T) {
+	s := newPlanSet(0, true)
+	if got := s.statements("SELECT a FROM b"); len(got) != 2 || got[1] != "EXPLAIN PHYSICAL SELECT a FROM b" {
+		t.Errorf("incorrect statements: %v", got)
+	}
+	s.add(2, "label", "SELECT a FROM b", nil, errors.New("failed"))
+	s.add(1, "label", "SELECT a FROM b", [][][]interface{}{
+		{{"Project", "a"}, {"Select", "b"}},
+		{{"Project", int64(3)}},
+	}, nil)
+
+	var buf bytes.Buffer
+	if err := s.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":1,"label":"label","query":"SELECT a FROM b","logical":[["Project","a"],["Select","b"]],"physical":[["Project",3]]}
+{"id":2,"label":"label","query":"SELECT a FROM b","error":"failed"}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect plans:\ngot\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRowBytes(t *testing.T) {
+	cases := []struct {
+		desc string
+		row  []interface{}
+		want int
+	}{
+		{
+			desc: "no values",
+			row:  nil,
+			want: 0,
+		},
+		{
+			desc: "every type",
+			row:  []interface{}{true, int32(1), float32(1), int64(1), 1.0, "truck_1"},
+			want: 1 + 1 + 4 + 4 + 8 + 8 + 4 + 7,
+		},
+		{
+			desc: "null values",
+			row:  []interface{}{nil, nil, nil, nil, nil, nil, nil, nil, 1.0},
+			want: 2 + 8,
+		},
+	}
+	for _, c := range cases {
+		if got := rowBytes(c.row); got != c.want {
+			t.Errorf("%s: incorrect size: got %d want %d", c.desc, got, c.want)
+		}
+	}
+}
@@ -6,23 +6,40 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/blagojts/viper"
 	"github.com/iznauy/IGinX-client-go/client_v2"
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/endpoints"
 )
 
 // Global vars:
 var (
-	runner               *query.BenchmarkRunner
-	connectionStringList []string
+	runner      *query.BenchmarkRunner
+	pool        *endpoints.Pool
+	responses   *responseSet
+	provisioner *udfProvisioner
+	plans       *planSet
+	udfSession  *endpoints.Session
+	detached    *detachLimit
+
+	responsesFile      string
+	expectedResponses  string
+	responsesPrecision int
+	fetchSize          int32
+	explainFile        string
 )
 
 // Parse args:
@@ -32,6 +49,16 @@ func init() {
 	var config query.BenchmarkRunnerConfig
 	config.AddToFlagSet(pflag.CommandLine)
 	pflag.String("connStr", "127.0.0.1:6888", "Iginx addresses (ip:port,ip:port,...)")
+	pflag.String("responses-file", "", "Write the response of each query in canonical form to this file, to validate the results")
+	pflag.String("expected-responses", "", "Compare the response of each query to the ones in this file, written by a previous run with --responses-file")
+	pflag.Int("responses-precision", 6, "Number of significant digits the floats of the responses are rounded to")
+	pflag.Int32("fetch-size", 100, "Number of rows fetched from IGinX at a time")
+	pflag.Bool("explain", false, "Explain the first query of each label with EXPLAIN after running it, writing the plans to --explain-file")
+	pflag.String("explain-file", "explain.json", "File to write the plans of --explain to")
+	pflag.Int("explain-limit", 0, "With --explain, explain the first N queries instead of the first query of each label")
+	pflag.Bool("explain-physical", false, "With --explain, also run EXPLAIN PHYSICAL for the time spent in each operator")
+	pflag.Int("max-detached-sessions", 16, "Number of sessions left to statements abandoned by --query-timeout that IGinX keeps executing, a query timing out past it waits for its statement to return")
+	pflag.String("udf-dir", "iginx_py_udfs", "Directory of the Python UDFs registered when IGinX does not have the ones the queries call, empty to not register any")
 
 	pflag.Parse()
 
@@ -46,99 +73,354 @@ func init() {
 	}
 
 	var connectionStrings = viper.GetString("connStr")
-	connectionStringList = strings.Split(connectionStrings, ",")
-	if len(connectionStringList) == 0 {
+	if connectionStrings == "" {
 		log.Fatal("missing 'connStr' flag")
 	}
+	pool = endpoints.NewPool(strings.Split(connectionStrings, ","), endpoints.DefaultDownTime)
 
-	runner = query.NewBenchmarkRunner(config)
-}
+	responsesFile = viper.GetString("responses-file")
+	expectedResponses = viper.GetString("expected-responses")
+	responsesPrecision = viper.GetInt("responses-precision")
+	fetchSize = viper.GetInt32("fetch-size")
+	if fetchSize <= 0 {
+		log.Fatal("fetch-size must be positive")
+	}
+	maxDetached := viper.GetInt("max-detached-sessions")
+	if maxDetached < 0 {
+		log.Fatal("max-detached-sessions can not be negative")
+	}
+	detached = newDetachLimit(maxDetached)
+	if responsesFile != "" || expectedResponses != "" {
+		responses = newResponseSet()
+	}
 
-func shuffle(nums []int) []int {
-	for i := len(nums); i > 0; i-- {
-		last := i - 1
-		idx := rand.Intn(i)
-		nums[last], nums[idx] = nums[idx], nums[last]
+	if viper.GetBool("explain") {
+		plans = newPlanSet(viper.GetInt("explain-limit"), viper.GetBool("explain-physical"))
+		explainFile = viper.GetString("explain-file")
+	}
+
+	if udfDir := viper.GetString("udf-dir"); udfDir != "" {
+		udfDir, err = filepath.Abs(udfDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		udfSession = pool.NewSession()
+		provisioner = newUDFProvisioner(udfDir, func(statement string) (rows [][]interface{}, err error) {
+			err = udfSession.Do(func(session *client_v2.Session) error {
+				rows, err = queryRows(session, statement)
+				return err
+			})
+			return rows, err
+		})
 	}
-	return nums
+
+	runner = query.NewBenchmarkRunner(config)
 }
 
 func main() {
+	if provisioner != nil {
+		// the UDFs of the queries read later are registered when the
+		// first query calling them runs
+		if err := provisioner.ensure(peekUDFs(runner.GetBufferedReader())); err != nil {
+			log.Fatal(err)
+		}
+	}
 	runner.Run(&query.IginxPool, newProcessor)
+	if udfSession != nil {
+		_ = udfSession.Close()
+	}
+	if summary := pool.Summary(); summary != "" {
+		fmt.Println(summary)
+	}
+	if plans != nil {
+		writePlans()
+	}
+	if responses != nil {
+		validateResponses()
+	}
+}
+
+// writePlans writes the plans of --explain to the explain file
+func writePlans() {
+	f, err := os.Create(explainFile)
+	if err != nil {
+		log.Fatalf("cannot create explain file: %v", err)
+	}
+	if err := plans.write(f); err != nil {
+		log.Fatalf("cannot write explain file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("cannot write explain file: %v", err)
+	}
+	fmt.Printf("wrote the plans of %d queries to %s\n", len(plans.plans), explainFile)
+}
+
+// validateResponses writes the responses of the run and compares them to
+// the expected ones, exiting with an error if any differs
+func validateResponses() {
+	if responsesFile != "" {
+		f, err := os.Create(responsesFile)
+		if err != nil {
+			log.Fatalf("cannot create responses file: %v", err)
+		}
+		if err := responses.write(f); err != nil {
+			log.Fatalf("cannot write responses file: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("cannot write responses file: %v", err)
+		}
+		fmt.Printf("wrote the responses of %d queries to %s\n", len(responses.responses), responsesFile)
+	}
+	if expectedResponses == "" {
+		return
+	}
+	f, err := os.Open(expectedResponses)
+	if err != nil {
+		log.Fatalf("cannot open expected responses: %v", err)
+	}
+	expected, err := readResponses(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("cannot read expected responses: %v", err)
+	}
+	diffs := responses.diff(expected)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) > 0 {
+		log.Fatalf("%d of %d queries have different responses than %s", len(diffs), len(expected.responses), expectedResponses)
+	}
+	fmt.Printf("the responses of all %d queries are the same as %s\n", len(expected.responses), expectedResponses)
 }
 
 type processor struct {
-	session *client_v2.Session
+	session *endpoints.Session
 }
 
 func newProcessor() query.Processor { return &processor{} }
 
 func (p *processor) Init(_ int) {
-	connectionStrings := ""
-	numbers := make([]int, 0, len(connectionStringList))
-	for i := 0; i < len(connectionStringList); i++ {
-		numbers = append(numbers, i)
+	p.session = pool.NewSession()
+	if err := p.session.Open(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, error) {
+	return p.ProcessQueryContext(context.Background(), q, isWarm)
+}
+
+// ProcessQueryContext runs the query until ctx is done, which is how
+// --query-timeout cancels it. IGinX keeps executing a statement abandoned
+// by the timeout, its session is closed once it returns, in the background
+// up to --max-detached-sessions, and the next query opens another one.
+func (p *processor) ProcessQueryContext(ctx context.Context, q query.Query, isWarm bool) ([]*query.Stat, error) {
+	hq := q.(*query.Iginx)
+	if provisioner != nil {
+		if err := provisioner.ensure(hq.UDFs); err != nil {
+			return nil, err
+		}
 	}
-	numbers = shuffle(numbers)
-	for i := 0; i < len(numbers); i++ {
-		if i > 0 {
-			connectionStrings += ","
+	collect := runner.DoPrintResponses() || (responses != nil && !isWarm)
+	var fs fetchStats
+	var rows [][]interface{}
+	// a query failing on an endpoint runs again on another one
+	err := p.session.Do(func(session *client_v2.Session) error {
+		var err error
+		fs, rows, err = Do(ctx, hq, session, collect)
+		return err
+	})
+	if err != nil && err == ctx.Err() {
+		// Do closes the connection once the cancelled query has returned
+		p.session.Detach()
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if collect {
+		resp, err := newResponse(hq.GetID(), string(hq.HumanLabel), string(hq.SqlQuery), rows, responsesPrecision)
+		if err != nil {
+			return nil, err
 		}
-		connectionStrings += connectionStringList[numbers[i]]
+		if runner.DoPrintResponses() {
+			prettyPrintResponse(resp)
+		}
+		if responses != nil && !isWarm {
+			responses.add(resp)
+		}
+	}
+	if plans != nil && !isWarm && plans.claim(string(hq.HumanLabel)) {
+		p.explain(hq)
 	}
+	return fs.stats(q.HumanLabelName()), nil
+}
 
-	settings, err := client_v2.NewSessionSettings(connectionStrings)
-	if err != nil {
-		log.Fatal(err)
+// explain runs the EXPLAIN statements of a query and adds its plans, or the
+// error explaining it
+func (p *processor) explain(q *query.Iginx) {
+	var results [][][]interface{}
+	var err error
+	for _, statement := range plans.statements(string(q.SqlQuery)) {
+		var rows [][]interface{}
+		err = p.session.Do(func(session *client_v2.Session) error {
+			var err error
+			rows, err = queryRows(session, statement)
+			return err
+		})
+		if err != nil {
+			break
+		}
+		results = append(results, rows)
 	}
+	plans.add(q.GetID(), string(q.HumanLabel), string(q.SqlQuery), results, err)
+}
 
-	p.session = client_v2.NewSession(settings)
-	if err := p.session.Open(); err != nil {
-		log.Fatal(err)
+// ErrorClass classes the errors of the queries for the error counts of
+// --continue-on-error: network errors, errors of IGinX executing the query,
+// and errors of the client
+func (p *processor) ErrorClass(err error) string {
+	switch {
+	case endpoints.IsNetworkError(err):
+		return "network"
+	case errors.Cause(err) == client_v2.ErrExecution:
+		return "execution"
+	default:
+		return "client"
 	}
 }
 
-func (p *processor) ProcessQuery(q query.Query, _ bool) ([]*query.Stat, error) {
-	hq := q.(*query.Iginx)
-	lag, err := Do(hq, p.session)
+func prettyPrintResponse(resp *response) {
+	line, err := json.MarshalIndent(resp, "", "  ")
 	if err != nil {
-		return nil, err
+		panic(err)
+	}
+
+	fmt.Println(string(line) + "\n")
+}
+
+// fetchStats are the timings and sizes of the response of a query
+type fetchStats struct {
+	total      float64 // milliseconds
+	firstBatch float64 // milliseconds until the first batch of rows
+	fetch      float64 // milliseconds from the first batch to the last row
+	rows       int
+	bytes      int
+}
+
+// stats returns the partial stats of the response, under sub-labels of the
+// label of the query, along with the stat of the query
+func (fs fetchStats) stats(label []byte) []*query.Stat {
+	sub := func(suffix string) []byte {
+		return append(append(make([]byte, 0, len(label)+len(suffix)), label...), suffix...)
+	}
+	return []*query.Stat{
+		query.GetPartialStat().Init(sub("-first-batch"), fs.firstBatch),
+		query.GetPartialStat().Init(sub("-fetch"), fs.fetch),
+		query.GetPartialCountStat("rows").Init(sub("-rows"), float64(fs.rows)),
+		query.GetPartialCountStat("KB").Init(sub("-received"), float64(fs.bytes)/1024),
+		query.GetStat().Init(label, fs.total),
+	}
+}
+
+// rowBytes returns the size of a row as received from IGinX: a bitmap of
+// the null values followed by the values that are not null
+func rowBytes(row []interface{}) int {
+	n := (len(row) + 7) / 8
+	for _, v := range row {
+		switch v := v.(type) {
+		case bool:
+			n++
+		case int32, float32:
+			n += 4
+		case int64, float64:
+			n += 8
+		case string:
+			n += 4 + len(v)
+		}
 	}
-	stat := query.GetStat()
-	stat.Init(q.HumanLabelName(), lag)
-	return []*query.Stat{stat}, nil
+	return n
 }
 
-// Do performs the action specified by the given Query. It uses fasthttp, and
-// tries to minimize heap allocations.
-func Do(q *query.Iginx, session *client_v2.Session) (lag float64, err error) {
+// Do performs the action specified by the given Query, reading all the rows
+// of the result. The rows are returned if collect is set. When ctx is done
+// before all the rows are read, Do returns ctx.Err() and closes the query
+// and then the session, in the background if IGinX is still executing it.
+func Do(ctx context.Context, q *query.Iginx, session *client_v2.Session, collect bool) (fs fetchStats, rows [][]interface{}, err error) {
 	sql := string(q.SqlQuery)
 	start := time.Now()
 	// execute sql
-	cursor, err := session.ExecuteQuery(sql, 100)
+	cursor, err := executeQuery(ctx, session, sql)
 	if err != nil {
-		return 0, err
+		return fs, nil, err
 	}
 
+	// the query is executed by IGinX before the first batch is fetched
 	if _, err := cursor.GetFields(); err != nil {
-		return 0, err
+		return fs, nil, err
 	}
+	firstBatch := time.Now()
 	for {
+		if ctx.Err() != nil {
+			// closing the cursor closes the query on IGinX, which
+			// drops the rows left to fetch
+			_ = cursor.Close()
+			_ = session.Close()
+			return fs, nil, ctx.Err()
+		}
 		hasMore, err := cursor.HasMore()
 		if err != nil {
-			log.Fatal(err)
+			return fs, nil, err
 		}
 		if !hasMore {
 			break
 		}
-		if _, err := cursor.NextRow(); err != nil {
-			return 0, err
+		row, err := cursor.NextRow()
+		if err != nil {
+			return fs, nil, err
+		}
+		fs.rows++
+		fs.bytes += rowBytes(row)
+		if collect {
+			rows = append(rows, row)
 		}
 	}
+	end := time.Now()
 	if err := cursor.Close(); err != nil {
-		return 0, err
+		return fs, nil, err
 	}
 
-	lag = float64(time.Since(start).Nanoseconds()) / 1e6 // milliseconds
-	return lag, err
+	fs.firstBatch = float64(firstBatch.Sub(start).Nanoseconds()) / 1e6 // milliseconds
+	fs.fetch = float64(end.Sub(firstBatch).Nanoseconds()) / 1e6
+	fs.total = float64(time.Since(start).Nanoseconds()) / 1e6
+	return fs, rows, err
+}
+
+// executeQuery executes a statement, returning ctx.Err() as soon as ctx is
+// done. The client can not interrupt a statement IGinX is executing, so a
+// cancelled statement is closed on IGinX as soon as it returns, along with
+// the session, which can not be used until then. Past the limit of detached
+// sessions, executeQuery waits for it.
+func executeQuery(ctx context.Context, session *client_v2.Session, sql string) (*client_v2.IGinXStream, error) {
+	type result struct {
+		cursor *client_v2.IGinXStream
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		cursor, err := session.ExecuteQuery(sql, fetchSize)
+		done <- result{cursor, err}
+	}()
+	select {
+	case r := <-done:
+		return r.cursor, r.err
+	case <-ctx.Done():
+		detached.abandon(func() {
+			if r := <-done; r.err == nil {
+				_ = r.cursor.Close()
+			}
+			_ = session.Close()
+		})
+		return nil, ctx.Err()
+	}
 }
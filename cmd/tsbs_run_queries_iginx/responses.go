@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// response is the result set of a query in canonical form, so that the
+// responses of two runs can be compared query by query: the floats are
+// rounded and the rows are sorted, each row encoded as a JSON array
+type response struct {
+	ID    uint64            `json:"id"`
+	Label string            `json:"label"`
+	Query string            `json:"query"`
+	Rows  []json.RawMessage `json:"rows"`
+}
+
+// newResponse returns the canonical form of the rows of a query, with the
+// floats rounded to the given number of significant digits
+func newResponse(id uint64, label, query string, rows [][]interface{}, precision int) (*response, error) {
+	r := &response{ID: id, Label: label, Query: query, Rows: make([]json.RawMessage, len(rows))}
+	for i, row := range rows {
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			values[j] = canonicalValue(v, precision)
+		}
+		raw, err := json.Marshal(values)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode row %d of query %d: %v", i, id, err)
+		}
+		r.Rows[i] = raw
+	}
+	sort.Slice(r.Rows, func(i, j int) bool {
+		return bytes.Compare(r.Rows[i], r.Rows[j]) < 0
+	})
+	return r, nil
+}
+
+// canonicalValue rounds floats and widens integers, so that the same value
+// read with different types compares equal. NaN and infinities, which JSON
+// can not encode, are written as strings.
+func canonicalValue(v interface{}, precision int) interface{} {
+	var f float64
+	switch v := v.(type) {
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case int32:
+		return int64(v)
+	case []byte:
+		return string(v)
+	default:
+		return v
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', precision, 64), 64)
+	return rounded
+}
+
+// equal reports whether two responses have the same rows
+func (r *response) equal(other *response) bool {
+	if len(r.Rows) != len(other.Rows) {
+		return false
+	}
+	for i := range r.Rows {
+		if !bytes.Equal(r.Rows[i], other.Rows[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// responseSet collects the responses of the queries of a run, the workers
+// add to it concurrently
+type responseSet struct {
+	mu        sync.Mutex
+	responses map[uint64]*response
+}
+
+func newResponseSet() *responseSet {
+	return &responseSet{responses: make(map[uint64]*response)}
+}
+
+func (s *responseSet) add(r *response) {
+	s.mu.Lock()
+	s.responses[r.ID] = r
+	s.mu.Unlock()
+}
+
+// write writes the responses ordered by query ID, a JSON object per line, so
+// the files of two runs can also be compared with diff
+func (s *responseSet) write(w io.Writer) error {
+	ids := make([]uint64, 0, len(s.responses))
+	for id := range s.responses {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, id := range ids {
+		if err := enc.Encode(s.responses[id]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readResponses reads the responses written by write
+func readResponses(r io.Reader) (*responseSet, error) {
+	s := newResponseSet()
+	dec := json.NewDecoder(r)
+	for {
+		var resp response
+		err := dec.Decode(&resp)
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read response %d: %v", len(s.responses)+1, err)
+		}
+		s.add(&resp)
+	}
+}
+
+// diff compares the responses with the expected ones query by query and
+// returns a description of each difference, in query ID order. Queries
+// missing from either set are differences too. Only the rows are compared,
+// the expected responses may come from another database.
+func (s *responseSet) diff(expected *responseSet) []string {
+	ids := make(map[uint64]bool, len(s.responses))
+	for id := range s.responses {
+		ids[id] = true
+	}
+	for id := range expected.responses {
+		ids[id] = true
+	}
+	sorted := make([]uint64, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var diffs []string
+	for _, id := range sorted {
+		got, want := s.responses[id], expected.responses[id]
+		switch {
+		case got == nil:
+			diffs = append(diffs, fmt.Sprintf("query %d (%s): not run", id, want.Label))
+		case want == nil:
+			diffs = append(diffs, fmt.Sprintf("query %d (%s): no expected response", id, got.Label))
+		case !got.equal(want):
+			diffs = append(diffs, fmt.Sprintf("query %d (%s): got %d rows, want %d rows\n  first different row: got %s want %s",
+				id, got.Label, len(got.Rows), len(want.Rows), firstDiff(got.Rows, want.Rows), firstDiff(want.Rows, got.Rows)))
+		}
+	}
+	return diffs
+}
+
+// firstDiff returns the first row of rows which is not the same in other,
+// or "none"
+func firstDiff(rows, other []json.RawMessage) string {
+	for i, row := range rows {
+		if i >= len(other) || !bytes.Equal(row, other[i]) {
+			return string(row)
+		}
+	}
+	return "none"
+}
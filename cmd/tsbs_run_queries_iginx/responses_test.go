@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewResponse(t *testing.T) {
+	rows := [][]interface{}{
+		{"truck_2", int32(3), 0.123456789},
+		{"truck_1", int64(7), float32(1.5)},
+		{"truck_3", nil, math.NaN()},
+	}
+	r, err := newResponse(5, "label", "SELECT", rows, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range r.Rows {
+		got = append(got, string(row))
+	}
+	want := []string{
+		`["truck_1",7,1.5]`,
+		`["truck_2",3,0.1235]`,
+		`["truck_3",null,"NaN"]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect rows: got %v want %v", got, want)
+	}
+}
+
+func TestResponsesDiff(t *testing.T) {
+	newSet := func(rows map[uint64][][]interface{}) *responseSet {
+		s := newResponseSet()
+		for id, r := range rows {
+			resp, err := newResponse(id, "label", "SELECT", r, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.add(resp)
+		}
+		return s
+	}
+	expected := newSet(map[uint64][][]interface{}{
+		1: {{"a", 1.0}, {"b", 2.0}},
+		2: {{"c", 3.0}},
+		3: {},
+	})
+
+	// the responses read back from a file are the same
+	var buf bytes.Buffer
+	if err := expected.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readResponses(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := read.diff(expected); len(diffs) != 0 {
+		t.Errorf("unexpected differences: %v", diffs)
+	}
+
+	// rows in another order and floats within the precision are the same
+	got := newSet(map[uint64][][]interface{}{
+		1: {{"b", 2.0000001}, {"a", int32(1)}},
+		2: {{"c", 4.0}},
+		4: {},
+	})
+	diffs := got.diff(expected)
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 differences, got %v", diffs)
+	}
+	for i, prefix := range []string{"query 2 ", "query 3 (label): not run", "query 4 (label): no expected response"} {
+		if !strings.HasPrefix(diffs[i], prefix) {
+			t.Errorf("difference %d: got %s want prefix %s", i, diffs[i], prefix)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+// detachLimit bounds the statements abandoned by --query-timeout that IGinX
+// is still executing. The client can not interrupt them, so each keeps a
+// goroutine and a session open until IGinX returns.
+type detachLimit struct {
+	slots chan struct{}
+}
+
+func newDetachLimit(max int) *detachLimit {
+	return &detachLimit{slots: make(chan struct{}, max)}
+}
+
+// abandon runs closeFn, which waits for an abandoned statement to return and
+// closes it along with its session, in the background while fewer than the
+// limit are, or else right away, so the worker waits for it
+func (l *detachLimit) abandon(closeFn func()) {
+	select {
+	case l.slots <- struct{}{}:
+		go func() {
+			closeFn()
+			<-l.slots
+		}()
+	default:
+		closeFn()
+	}
+}
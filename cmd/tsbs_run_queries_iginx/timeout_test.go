@@ -0,0 +1,50 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDetachLimit(t *testing.T) {
+	l := newDetachLimit(2)
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	// the first two statements are closed in the background
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		l.abandon(func() {
+			<-release
+			wg.Done()
+		})
+	}
+
+	// the third one is waited for
+	closed := false
+	l.abandon(func() {
+		closed = true
+	})
+	if !closed {
+		t.Errorf("statement past the limit not closed before abandon returned")
+	}
+
+	close(release)
+	wg.Wait()
+	// the slots are released once the statements are closed
+	deadline := time.Now().Add(time.Second)
+	for len(l.slots) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := len(l.slots); n != 0 {
+		t.Errorf("got %d slots taken after the statements were closed, want 0", n)
+	}
+
+	l = newDetachLimit(0)
+	closed = false
+	l.abandon(func() {
+		closed = true
+	})
+	if !closed {
+		t.Errorf("statement not closed before abandon returned with no detached sessions")
+	}
+}
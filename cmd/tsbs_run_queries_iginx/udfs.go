@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/udfs"
+)
+
+// udfProvisioner registers the UDFs the queries call when IGinX does not
+// have them, from the files of a directory the IGinX node can read
+type udfProvisioner struct {
+	dir string
+	// run runs a statement and returns its rows, replaced in tests
+	run func(statement string) ([][]interface{}, error)
+
+	// mu serialises the registrations, the UDFs checked are read without
+	// it so the queries calling them take no lock
+	mu      sync.Mutex
+	checked sync.Map
+}
+
+func newUDFProvisioner(dir string, run func(statement string) ([][]interface{}, error)) *udfProvisioner {
+	return &udfProvisioner{dir: dir, run: run}
+}
+
+// unchecked returns the UDFs of names not checked yet
+func (p *udfProvisioner) unchecked(names []string) []string {
+	var unchecked []string
+	for _, name := range names {
+		if _, ok := p.checked.Load(name); !ok {
+			unchecked = append(unchecked, name)
+		}
+	}
+	return unchecked
+}
+
+// registered returns the names of the UDFs IGinX has
+func (p *udfProvisioner) registered() (map[string]bool, error) {
+	rows, err := p.run(udfs.ShowStatement)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the UDFs: %v", err)
+	}
+	names := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if len(row) > 0 {
+			names[fmt.Sprint(row[0])] = true
+		}
+	}
+	return names, nil
+}
+
+// ensure registers the UDFs of names IGinX does not have and checks that
+// it has them all afterwards. UDFs are only checked once per run.
+func (p *udfProvisioner) ensure(names []string) error {
+	if len(p.unchecked(names)) == 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// another worker may have checked them in the meantime
+	unchecked := p.unchecked(names)
+	if len(unchecked) == 0 {
+		return nil
+	}
+
+	registered, err := p.registered()
+	if err != nil {
+		return err
+	}
+	added := false
+	for _, name := range unchecked {
+		if registered[name] {
+			continue
+		}
+		udf, ok := udfs.Queries[name]
+		if !ok {
+			return fmt.Errorf("unknown UDF %s, it must be registered by hand", name)
+		}
+		udf.File = filepath.Join(p.dir, udf.File)
+		if _, err := p.run(udf.RegisterStatement()); err != nil {
+			return fmt.Errorf("cannot register UDF %s: %v", name, err)
+		}
+		log.Printf("registered UDF %s from %s\n", name, udf.File)
+		added = true
+	}
+	if added {
+		if registered, err = p.registered(); err != nil {
+			return err
+		}
+	}
+	for _, name := range unchecked {
+		if !registered[name] {
+			return fmt.Errorf("UDF %s is not registered after registering it", name)
+		}
+		p.checked.Store(name, true)
+	}
+	return nil
+}
+
+// peekUDFs returns the UDFs called by the queries at the start of the
+// input, decoded from the bytes the reader buffers without consuming them
+func peekUDFs(br *bufio.Reader) []string {
+	// the buffer may end in the middle of a query, the queries before it
+	// are still decoded
+	buf, _ := br.Peek(br.Size())
+	dec := gob.NewDecoder(bytes.NewReader(buf))
+	called := make(map[string]bool)
+	for {
+		var q query.Iginx
+		if err := dec.Decode(&q); err != nil {
+			break
+		}
+		for _, name := range q.UDFs {
+			called[name] = true
+		}
+	}
+	names := make([]string, 0, len(called))
+	for name := range called {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// queryRows runs a statement on the session and returns all its rows
+func queryRows(session *client_v2.Session, statement string) ([][]interface{}, error) {
+	cursor, err := session.ExecuteQuery(statement, fetchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	var rows [][]interface{}
+	for {
+		hasMore, err := cursor.HasMore()
+		if err != nil {
+			return nil, err
+		}
+		if !hasMore {
+			return rows, nil
+		}
+		row, err := cursor.NextRow()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/query"
+	"github.com/timescale/tsbs/pkg/targets/iginx/udfs"
+)
+
+// fakeIGinX registers UDFs like IGinX, the UDFs in broken are not
+// registered without an error
+type fakeIGinX struct {
+	registered map[string]bool
+	broken     map[string]bool
+	statements []string
+}
+
+func (f *fakeIGinX) run(statement string) ([][]interface{}, error) {
+	f.statements = append(f.statements, statement)
+	if statement == udfs.ShowStatement {
+		var rows [][]interface{}
+		for name := range f.registered {
+			rows = append(rows, []interface{}{name, "Class", "file.py", "127.0.0.1", "UDSF"})
+		}
+		return rows, nil
+	}
+	for name, udf := range udfs.Queries {
+		if strings.HasSuffix(statement, `AS "`+udf.Name+`"`) && !f.broken[name] {
+			f.registered[name] = true
+		}
+	}
+	return nil, nil
+}
+
+func TestUDFProvisionerEnsure(t *testing.T) {
+	f := &fakeIGinX{registered: map[string]bool{"device_rows": true}}
+	p := newUDFProvisioner("/udfs", f.run)
+	if err := p.ensure([]string{"device_rows", "breakdowns"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		udfs.ShowStatement,
+		`REGISTER UDSF PYTHON TASK "UDSFBreakdowns" IN "/udfs/udsf_iot.py" AS "breakdowns"`,
+		udfs.ShowStatement,
+	}
+	if !reflect.DeepEqual(f.statements, want) {
+		t.Errorf("incorrect statements:\ngot  %v\nwant %v", f.statements, want)
+	}
+
+	// checked UDFs are not checked again
+	f.statements = nil
+	if err := p.ensure([]string{"breakdowns"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.statements) != 0 {
+		t.Errorf("unexpected statements: %v", f.statements)
+	}
+
+	// nor do they take the lock of the registrations
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.ensure([]string{"device_rows", "breakdowns"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUDFProvisionerErrors(t *testing.T) {
+	f := &fakeIGinX{registered: map[string]bool{}, broken: map[string]bool{"breakdowns": true}}
+	p := newUDFProvisioner("/udfs", f.run)
+	if err := p.ensure([]string{"breakdowns"}); err == nil {
+		t.Errorf("expected an error for a UDF not registered")
+	}
+	if err := p.ensure([]string{"transposition"}); err == nil {
+		t.Errorf("expected an error for an unknown UDF")
+	}
+}
+
+func TestPeekUDFs(t *testing.T) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, called := range [][]string{nil, {"device_rows"}, {"breakdowns", "device_rows"}} {
+		q := &query.Iginx{SqlQuery: []byte("SELECT"), UDFs: called}
+		if err := enc.Encode(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	br := bufio.NewReader(&buf)
+	if got, want := peekUDFs(br), []string{"breakdowns", "device_rows"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect UDFs: got %v want %v", got, want)
+	}
+	// the queries are still read from the start
+	var q query.Iginx
+	if err := gob.NewDecoder(br).Decode(&q); err != nil || len(q.UDFs) != 0 {
+		t.Errorf("incorrect first query: %v, %v", q.UDFs, err)
+	}
+}
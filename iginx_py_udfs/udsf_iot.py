@@ -0,0 +1,216 @@
+# UDSFs used by the IGinX iot queries of TSBS.
+#
+# IGinX returns a column per series, named by the series path, e.g.
+# readings.truck_1.West.Seth.velocity, wrapped in the aggregate function if
+# any, e.g. avg(readings.truck_1.West.Seth.velocity), and followed by the
+# IGinX tags of tagged series, e.g. readings.velocity{name=truck_1}. The
+# functions below turn these columns into a row per device, like a row of
+# the readings or diagnostics tables of the other databases, with:
+#
+#   key       the timestamp, if the input has one
+#   day       the start of the UTC day of the key, if the input has one
+#   device    the path of the device followed by its tags
+#   level<i>  the i-th level of the device path, e.g. level1 is the truck
+#             name with the default path template
+#   <tag>     the IGinX tags of tagged series, "unknown" if missing
+#
+# followed by the columns of the function.
+
+import re
+
+KEY = "key"
+UNKNOWN = b"unknown"
+
+TYPE_BOOLEAN = 1
+TYPE_INTEGER = 2
+TYPE_LONG = 3
+TYPE_FLOAT = 4
+TYPE_DOUBLE = 5
+TYPE_BINARY = 6
+
+NANOS_PER_MINUTE = 60 * 1000 * 1000 * 1000
+TEN_MINUTES = 10 * NANOS_PER_MINUTE
+DAY = 24 * 60 * NANOS_PER_MINUTE
+
+_FUNCTION = re.compile(r"^(\w+)\((.*)\)$")
+
+
+class Series:
+    """A column of the input, parsed from its name"""
+
+    def __init__(self, name, type):
+        self.type = type
+        self.function = None
+        m = _FUNCTION.match(name)
+        if m:
+            self.function = m.group(1).lower()
+            name = m.group(2)
+        self.tags = {}
+        if name.endswith("}") and "{" in name:
+            name, tags = name[:-1].split("{", 1)
+            for tag in tags.split(","):
+                k, _, v = tag.partition("=")
+                self.tags[k] = v.encode()
+        levels = name.split(".")
+        self.field = levels[-1]
+        self.levels = [level.encode() for level in levels[:-1]]
+        self.device = ".".join(levels[:-1])
+        if self.tags:
+            self.device += "{" + ",".join(k + "=" + self.tags[k].decode() for k in sorted(self.tags)) + "}"
+        self.device = self.device.encode()
+
+    def column(self):
+        """the name of the output column of the series values"""
+        if self.function:
+            return self.function + "_" + self.field
+        return self.field
+
+
+class Input:
+    """The series of the input rows grouped by device"""
+
+    def __init__(self, rows):
+        self.has_key = len(rows[0]) > 0 and rows[0][0] == KEY
+        start = 1 if self.has_key else 0
+        self.series = [None] * start
+        for i in range(start, len(rows[0])):
+            self.series.append(Series(rows[0][i], rows[1][i]))
+        self.rows = rows[2:]
+
+        self.levels = 0
+        tag_keys = set()
+        self.devices = {}
+        for s in self.series[start:]:
+            self.levels = max(self.levels, len(s.levels))
+            tag_keys.update(s.tags.keys())
+            self.devices.setdefault(s.device, s)
+        self.tag_keys = sorted(tag_keys)
+
+    def device_header(self):
+        """the names and types of the columns identifying a device"""
+        paths = ["device"] + ["level%d" % i for i in range(self.levels)] + self.tag_keys
+        return paths, [TYPE_BINARY] * len(paths)
+
+    def device_values(self, device):
+        """the values of the columns identifying a device"""
+        s = self.devices[device]
+        levels = s.levels + [UNKNOWN] * (self.levels - len(s.levels))
+        return [device] + levels + [s.tags.get(k, UNKNOWN) for k in self.tag_keys]
+
+    def values_by_device(self):
+        """the values of every device in the rows, as a list of (key, value
+        of each column) per device"""
+        by_device = {}
+        for row in self.rows:
+            key = row[0] if self.has_key else None
+            for i in range(1 if self.has_key else 0, len(row)):
+                s = self.series[i]
+                if row[i] is None:
+                    continue
+                values = by_device.setdefault(s.device, {})
+                values.setdefault(key, {})[s.column()] = row[i]
+        return by_device
+
+
+def _day(key):
+    return key - key % DAY
+
+
+class UDSFDeviceRows:
+    """Turns the columns of the series into a row per device and key, with a
+    column per field, named after the field and the function if any, e.g.
+    velocity or avg_velocity."""
+
+    def transform(self, rows):
+        data = Input(rows)
+        columns = []
+        types = {}
+        for s in data.series:
+            if s is not None and s.column() not in types:
+                columns.append(s.column())
+                types[s.column()] = s.type
+
+        paths, path_types = data.device_header()
+        if data.has_key:
+            paths = [KEY, "day"] + paths
+            path_types = [TYPE_LONG, TYPE_LONG] + path_types
+        ret = [paths + columns, path_types + [types[c] for c in columns]]
+
+        by_device = data.values_by_device()
+        out = []
+        for device in sorted(by_device):
+            for key, values in by_device[device].items():
+                row = data.device_values(device) + [values.get(c) for c in columns]
+                if data.has_key:
+                    row = [key, _day(key)] + row
+                out.append(row)
+        if data.has_key:
+            out.sort(key=lambda r: (r[0], r[2]))
+        return ret + out
+
+
+class UDSFDrivingSessions:
+    """Finds the average driving session per device per day, from the
+    average velocity of each device over 10 minutes windows. A device is
+    driving in a window with an average velocity over 5, a session lasts
+    from a window where the device starts driving to the next window where
+    it stops. Outputs the device columns, the day of the start of the
+    sessions and their average duration in nanoseconds."""
+
+    def transform(self, rows):
+        data = Input(rows)
+        paths, path_types = data.device_header()
+        ret = [paths + ["day", "duration"], path_types + [TYPE_LONG, TYPE_DOUBLE]]
+
+        by_device = data.values_by_device()
+        out = []
+        for device in sorted(by_device):
+            windows = sorted(by_device[device].items())
+            # the windows where the driving status changes
+            changes = []
+            prev = None
+            for key, values in windows:
+                driving = list(values.values())[0] > 5
+                if prev is not None and driving != prev:
+                    changes.append((key, driving))
+                prev = driving
+            durations = {}
+            for j, (start, driving) in enumerate(changes):
+                if not driving or j + 1 == len(changes):
+                    continue
+                durations.setdefault(_day(start), []).append(changes[j + 1][0] - start)
+            for day in sorted(durations):
+                d = durations[day]
+                out.append(data.device_values(device) + [day, float(sum(d)) / len(d)])
+        return ret + out
+
+
+class UDSFBreakdowns:
+    """Counts the breakdowns of each device from its status. A device is
+    broken down in a 10 minutes window if at least half of its status are 0,
+    a breakdown is a window where it is broken down after one where it is
+    not. Outputs the device columns and the number of breakdowns."""
+
+    def transform(self, rows):
+        data = Input(rows)
+        paths, path_types = data.device_header()
+        ret = [paths + ["breakdowns"], path_types + [TYPE_LONG]]
+
+        by_device = data.values_by_device()
+        out = []
+        for device in sorted(by_device):
+            windows = {}
+            for key, values in by_device[device].items():
+                status = list(values.values())[0]
+                zeros, count = windows.get(key - key % TEN_MINUTES, (0, 0))
+                windows[key - key % TEN_MINUTES] = (zeros + (1 if status == 0 else 0), count + 1)
+            breakdowns = 0
+            prev = None
+            for window in sorted(windows):
+                zeros, count = windows[window]
+                broken_down = zeros * 2 >= count
+                if prev is False and broken_down:
+                    breakdowns += 1
+                prev = broken_down
+            out.append(data.device_values(device) + [breakdowns])
+        return ret + out
@@ -1,3 +1,7 @@
+# Deprecated: the iot queries use the UDSFs of udsf_iot.py since they compute
+# the same results as the TimescaleDB ones. This UDSF is kept for the setup
+# configs that still register it as "transposition".
+
 KEY = "key"
 
 TYPE_BOOLEAN = 1
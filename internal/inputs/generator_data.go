@@ -131,6 +131,8 @@ func (g *DataGenerator) getSerializer(sim common.Simulator, target targets.Imple
 	switch target.TargetName() {
 	case constants.FormatCrateDB:
 		fallthrough
+	case constants.FormatIginx, constants.FormatIginxBinary:
+		fallthrough
 	case constants.FormatClickhouse:
 		fallthrough
 	case constants.FormatTimescaleDB:
@@ -340,7 +340,8 @@ func TestGetSerializer(t *testing.T) {
 	checkWriteHeader(constants.FormatTimescaleDB, true)
 	checkWriteHeader(constants.FormatVictoriaMetrics, false)
 	checkWriteHeader(constants.FormatQuestDB, false)
-	checkWriteHeader(constants.FormatIginx, false)
+	checkWriteHeader(constants.FormatIginx, true)
+	checkWriteHeader(constants.FormatIginxBinary, true)
 }
 
 type mockSerializer struct {
@@ -40,6 +40,11 @@ type IoTGeneratorMaker interface {
 	NewIoT(start, end time.Time, scale int) (queryUtils.QueryGenerator, error)
 }
 
+// IginxGeneratorMaker creates a query generator for the iginx use case
+type IginxGeneratorMaker interface {
+	NewIginx(start, end time.Time, scale int) (queryUtils.QueryGenerator, error)
+}
+
 // QueryGenerator is a type of Generator for creating queries to test against a
 // database. The output is specific to the type of database (due to each using
 // different querying techniques, e.g. SQL or REST), but is consumed by TSBS
@@ -158,7 +163,7 @@ func (g *QueryGenerator) addFactory(database string, factory interface{}) error
 	validFactory := false
 
 	switch factory.(type) {
-	case DevopsGeneratorMaker, IoTGeneratorMaker:
+	case DevopsGeneratorMaker, IoTGeneratorMaker, IginxGeneratorMaker:
 		validFactory = true
 	}
 
@@ -196,6 +201,13 @@ func (g *QueryGenerator) getUseCaseGenerator(c *config.QueryGeneratorConfig) (qu
 		}
 
 		return devopsFactory.NewDevops(g.tsStart, g.tsEnd, scale)
+	case config.UseCaseIginx:
+		iginxFactory, ok := factory.(IginxGeneratorMaker)
+		if !ok {
+			return nil, fmt.Errorf(errUseCaseNotImplementedFmt, c.Use, c.Format)
+		}
+
+		return iginxFactory.NewIginx(g.tsStart, g.tsEnd, scale)
 	default:
 		return nil, fmt.Errorf(errUnknownUseCaseFmt, c.Use)
 	}
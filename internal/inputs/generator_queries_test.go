@@ -150,6 +150,15 @@ func TestQueryGeneratorInit(t *testing.T) {
 		InterleavedNumGroups: 1,
 	}
 
+	// Test the use case of the queries only is valid
+	c.Use = config.UseCaseIginx
+	c.QueryType = "sliding-window-1"
+	if err := c.Validate(); err != nil {
+		t.Errorf("unexpected error with use case %s: %v", config.UseCaseIginx, err)
+	}
+	c.Use = common.UseCaseCPUOnly
+	c.QueryType = "unknown query type"
+
 	// Test use case not in matrix
 	err = g.init(c)
 	want := fmt.Sprintf(errBadUseFmt, common.UseCaseCPUOnly)
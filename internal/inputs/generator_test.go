@@ -79,6 +79,12 @@ func TestBaseConfigValidate(t *testing.T) {
 		t.Errorf("unexpected error with Use '%s': %v", common.UseCaseCPUOnly, err)
 	}
 
+	// the use case of the queries only has no data
+	c.Use = "iginx"
+	if err = c.Validate(); err == nil {
+		t.Errorf("unexpected lack of error for use 'iginx'")
+	}
+
 	c.Use = "bad use"
 	err = c.Validate()
 	if err == nil {
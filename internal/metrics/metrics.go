@@ -0,0 +1,275 @@
+// Package metrics exposes the progress of the benchmarks over HTTP in the
+// Prometheus text exposition format, so long runs can be watched on a
+// dashboard. The metrics have at most one label.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of the latency
+// histograms, in seconds
+var LatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// metric is a metric family of the registry
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds the metrics exposed by the endpoint, in the order they
+// were created
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+}
+
+// Write writes all the metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	r.mu.Lock()
+	for _, m := range r.metrics {
+		m.write(bw)
+	}
+	r.mu.Unlock()
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(w); err != nil {
+		log.Printf("cannot write the metrics: %v\n", err)
+	}
+}
+
+// Serve serves the metrics of r on /metrics at the address addr until the
+// program exits. Only listening on the address can fail.
+func Serve(addr string, r *Registry) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			log.Printf("metrics endpoint stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// family is the name, help and label of a metric family
+type family struct {
+	name, help, kind, label string
+}
+
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+}
+
+// labels returns the label pairs of a sample of the label value v, along
+// with the extra pair, if any
+func (f *family) labels(v string, extra ...string) string {
+	var pairs []string
+	if f.label != "" {
+		pairs = append(pairs, f.label+`="`+escape(v)+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escape(extra[1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the label values of a vector, sorted
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterFunc is a counter whose value is read from a function, for the
+// counters the benchmarks already keep
+type CounterFunc struct {
+	family
+	fn func() float64
+}
+
+// NewCounterFunc adds a counter read from fn to the registry
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) *CounterFunc {
+	c := &CounterFunc{family: family{name: name, help: help, kind: "counter"}, fn: fn}
+	r.add(c)
+	return c
+}
+
+func (c *CounterFunc) write(w io.Writer) {
+	c.header(w)
+	fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.fn()))
+}
+
+// vec is a metric family of values by label value
+type vec struct {
+	family
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func (v *vec) write(w io.Writer) {
+	v.header(w)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, k := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labels(k), formatFloat(v.values[k]))
+	}
+}
+
+// CounterVec is a counter by label value
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec adds a counter by the given label to the registry
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{vec{family: family{name: name, help: help, kind: "counter", label: label}, values: make(map[string]float64)}}
+	r.add(c)
+	return c
+}
+
+// Add adds d to the counter of the label value v
+func (c *CounterVec) Add(v string, d float64) {
+	c.mu.Lock()
+	c.values[v] += d
+	c.mu.Unlock()
+}
+
+// Inc increments the counter of the label value v
+func (c *CounterVec) Inc(v string) {
+	c.Add(v, 1)
+}
+
+// GaugeVec is a gauge by label value
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec adds a gauge by the given label to the registry
+func (r *Registry) NewGaugeVec(name, help, label string) *GaugeVec {
+	g := &GaugeVec{vec{family: family{name: name, help: help, kind: "gauge", label: label}, values: make(map[string]float64)}}
+	r.add(g)
+	return g
+}
+
+// Set sets the gauge of the label value v
+func (g *GaugeVec) Set(v string, value float64) {
+	g.mu.Lock()
+	g.values[v] = value
+	g.mu.Unlock()
+}
+
+// histogram is the buckets, sum and count of the observations of a label
+// value
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a histogram by label value
+type HistogramVec struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+// NewHistogramVec adds a histogram by the given label to the registry, with
+// buckets of the given increasing upper bounds
+func (r *Registry) NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	h := &HistogramVec{
+		family:  family{name: name, help: help, kind: "histogram", label: label},
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+	r.add(h)
+	return h
+}
+
+// Observe adds an observation to the histogram of the label value v
+func (h *HistogramVec) Observe(v string, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist, ok := h.values[v]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[v] = hist
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		hist.counts[i]++
+	}
+	hist.sum += value
+	hist.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.header(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		hist := h.values[k]
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(k, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(k, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labels(k), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labels(k), hist.count)
+	}
+}
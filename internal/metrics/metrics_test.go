@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	done := 3.0
+	r.NewCounterFunc("test_done_total", "Number of things done.", func() float64 { return done })
+	c := r.NewCounterVec("test_queries_total", "Number of queries.", "label")
+	c.Inc("b")
+	c.Add("a \"quoted\"", 2)
+	g := r.NewGaugeVec("test_in_flight", "Work in flight.", "worker")
+	g.Set("0", 1)
+	h := r.NewHistogramVec("test_duration_seconds", "Latency.", "", []float64{0.1, 1})
+	h.Observe("", 0.05)
+	h.Observe("", 0.5)
+	h.Observe("", 0.1)
+	h.Observe("", 2)
+
+	want := `# HELP test_done_total Number of things done.
+# TYPE test_done_total counter
+test_done_total 3
+# HELP test_queries_total Number of queries.
+# TYPE test_queries_total counter
+test_queries_total{label="a \"quoted\""} 2
+test_queries_total{label="b"} 1
+# HELP test_in_flight Work in flight.
+# TYPE test_in_flight gauge
+test_in_flight{worker="0"} 1
+# HELP test_duration_seconds Latency.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 2
+test_duration_seconds_bucket{le="1"} 3
+test_duration_seconds_bucket{le="+Inf"} 4
+test_duration_seconds_sum 2.65
+test_duration_seconds_count 4
+`
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got metrics\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.", "label").Inc("x")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %s", ct)
+	}
+	if !strings.Contains(rec.Body.String(), `test_total{label="x"} 1`) {
+		t.Errorf("counter missing from\n%s", rec.Body.String())
+	}
+}
+
+func TestServe(t *testing.T) {
+	if err := Serve("not an address", NewRegistry()); err == nil {
+		t.Errorf("no error listening on a wrong address")
+	}
+}
@@ -2,25 +2,215 @@ package load
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 const (
 	defaultReadSize = 4 << 20 // 4 MB
+	// maxHeaderSize is the largest data header looked for at the start of
+	// the files, to skip it in the files after the first
+	maxHeaderSize = 1 << 20 // 1 MB
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	// headerPrefix starts the data headers written by tsbs_generate_data
+	headerPrefix = []byte("tags,")
 )
 
 // GetBufferedReader returns the buffered Reader that should be used by the file loader
-// if no file name is specified a buffer for STDIN is returned
+// if no file name is specified a buffer for STDIN is returned.
+// The file name can be a comma separated list of files and glob patterns,
+// whose files are read one after the other, in order. Files compressed with
+// gzip or zstd are decompressed. The files after the first may repeat the
+// data headers of the first, e.g. when generated in interleaved groups,
+// those are skipped. A file with other headers is an error.
 func GetBufferedReader(fileName string) *bufio.Reader {
 	if len(fileName) == 0 {
 		// Read from STDIN
-		return bufio.NewReaderSize(os.Stdin, defaultReadSize)
+		r, _, err := decompress(bufio.NewReaderSize(os.Stdin, defaultReadSize))
+		if err != nil {
+			fatal("cannot decompress STDIN: %v", err)
+			return nil
+		}
+		return bufio.NewReaderSize(r, defaultReadSize)
+	}
+	fileNames, err := FileNames(fileName)
+	if err != nil {
+		fatal("%v", err)
+		return nil
+	}
+	r := &multiFileReader{fileNames: fileNames}
+	// Open the first file now, for the errors to show at once
+	if err := r.open(); err != nil {
+		fatal("%v", err)
+		return nil
 	}
-	// Read from specified file
+	return bufio.NewReaderSize(r, defaultReadSize)
+}
+
+// FileNames returns the files of a comma separated list of files and glob
+// patterns, in order, the matches of a pattern sorted by name
+func FileNames(fileName string) ([]string, error) {
+	var fileNames []string
+	for _, name := range strings.Split(fileName, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !strings.ContainsAny(name, "*?[") {
+			fileNames = append(fileNames, name)
+			continue
+		}
+		matches, err := filepath.Glob(name)
+		if err != nil {
+			return nil, fmt.Errorf("bad file pattern %s: %v", name, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no file matches %s", name)
+		}
+		fileNames = append(fileNames, matches...)
+	}
+	if len(fileNames) == 0 {
+		return nil, fmt.Errorf("no file in %q", fileName)
+	}
+	return fileNames, nil
+}
+
+// decompress returns a reader of the data of br, decompressed if it starts
+// with the magic number of gzip or zstd, along with a function closing the
+// decompressor
+func decompress(br *bufio.Reader) (io.Reader, func(), error) {
+	start, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(start, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gr, func() { gr.Close() }, nil
+	case bytes.HasPrefix(start, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	}
+	return br, func() {}, nil
+}
+
+// multiFileReader reads files one after the other, skipping the headers of
+// the first file at the start of the next files
+type multiFileReader struct {
+	fileNames []string
+	// header is the data header of the first file, empty if it has none
+	header []byte
+
+	next    int
+	current io.Reader
+	close   func()
+	// err is the error that stopped the reading, returned by the next reads
+	err error
+}
+
+// open opens the next file
+func (r *multiFileReader) open() error {
+	fileName := r.fileNames[r.next]
 	file, err := os.Open(fileName)
 	if err != nil {
-		fatal("cannot open file for read %s: %v", fileName, err)
+		return fmt.Errorf("cannot open file for read %s: %v", fileName, err)
+	}
+	dr, closeDecompressor, err := decompress(bufio.NewReaderSize(file, defaultReadSize))
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("cannot decompress file %s: %v", fileName, err)
+	}
+	closeFile := func() {
+		closeDecompressor()
+		file.Close()
+	}
+	first := r.next == 0
+	r.next++
+	br := bufio.NewReaderSize(dr, defaultReadSize)
+	if len(r.fileNames) == 1 {
+		r.current, r.close = br, closeFile
+		return nil
+	}
+
+	header, err := readHeader(br)
+	if err != nil {
+		closeFile()
+		return fmt.Errorf("cannot read file %s: %v", fileName, err)
+	}
+	if first {
+		r.header = header
+		r.current, r.close = io.MultiReader(bytes.NewReader(header), br), closeFile
 		return nil
 	}
-	return bufio.NewReaderSize(file, defaultReadSize)
+	if len(header) > 0 && !bytes.Equal(header, r.header) {
+		closeFile()
+		return fmt.Errorf("file %s has other data headers than %s", fileName, r.fileNames[0])
+	}
+	r.current, r.close = br, closeFile
+	return nil
+}
+
+// readHeader reads the data header at the start of br, up to and including
+// the blank line ending it, or what was read of it when the file or
+// maxHeaderSize is reached first
+func readHeader(br *bufio.Reader) ([]byte, error) {
+	if start, _ := br.Peek(len(headerPrefix)); !bytes.Equal(start, headerPrefix) {
+		return nil, nil
+	}
+	var header []byte
+	for len(header) < maxHeaderSize {
+		line, err := br.ReadBytes('\n')
+		header = append(header, line...)
+		if err == io.EOF {
+			return header, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			break
+		}
+	}
+	return header, nil
+}
+
+// Read reads the current file, and the next ones once it ends
+func (r *multiFileReader) Read(p []byte) (int, error) {
+	for r.err == nil {
+		if r.current == nil {
+			if r.next == len(r.fileNames) {
+				r.err = io.EOF
+				break
+			}
+			if err := r.open(); err != nil {
+				r.err = err
+				break
+			}
+		}
+		n, err := r.current.Read(p)
+		if err != nil {
+			r.close()
+			r.current = nil
+			if err != io.EOF {
+				r.err = err
+			} else if n == 0 {
+				continue
+			}
+		}
+		return n, r.err
+	}
+	return 0, r.err
 }
@@ -0,0 +1,187 @@
+package load
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func writeTestFile(t *testing.T, fileName string, content []byte) {
+	if err := ioutil.WriteFile(fileName, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFileNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"data-2.gz", "data-1.gz", "data-3.zst", "other"} {
+		writeTestFile(t, filepath.Join(dir, name), nil)
+	}
+	in := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	cases := []struct {
+		desc     string
+		fileName string
+		want     []string
+		wantErr  bool
+	}{
+		{desc: "single file", fileName: in("other"), want: []string{in("other")}},
+		{desc: "list", fileName: in("other") + ", " + in("data-1.gz"), want: []string{in("other"), in("data-1.gz")}},
+		{desc: "glob sorted", fileName: in("data-*"), want: []string{in("data-1.gz"), in("data-2.gz"), in("data-3.zst")}},
+		{desc: "list of globs", fileName: in("*.zst") + "," + in("*.gz"), want: []string{in("data-3.zst"), in("data-1.gz"), in("data-2.gz")}},
+		{desc: "no match", fileName: in("*.csv"), wantErr: true},
+		{desc: "empty list", fileName: " , ", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := FileNames(c.fileName)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", c.desc, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestGetBufferedReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const header = "tags,hostname string\ncpu,usage_user\n\n"
+
+	cases := []struct {
+		desc    string
+		files   map[string][]byte
+		want    string
+		wantErr bool
+	}{
+		{
+			desc:  "plain file",
+			files: map[string][]byte{"a": []byte(header + "line 1\n")},
+			want:  header + "line 1\n",
+		},
+		{
+			desc: "compressed files in order",
+			files: map[string][]byte{
+				"a.gz":  gzipped(t, "line 1\nline 2\n"),
+				"b.zst": zstded(t, "line 3\n"),
+				"c":     []byte("line 4\n"),
+			},
+			want: "line 1\nline 2\nline 3\nline 4\n",
+		},
+		{
+			desc: "repeated headers",
+			files: map[string][]byte{
+				"a.gz":  gzipped(t, header+"line 1\n"),
+				"b.zst": zstded(t, header+"line 2\n"),
+				"c":     []byte("line 3\n"),
+			},
+			want: header + "line 1\nline 2\nline 3\n",
+		},
+		{
+			desc: "other headers",
+			files: map[string][]byte{
+				"a": []byte(header + "line 1\n"),
+				"b": []byte("tags,other string\n\nline 2\n"),
+			},
+			wantErr: true,
+		},
+		{
+			desc: "headers after the first file only",
+			files: map[string][]byte{
+				"a": []byte("line 1\n"),
+				"b": []byte(header + "line 2\n"),
+			},
+			wantErr: true,
+		},
+	}
+	for i, c := range cases {
+		caseDir := filepath.Join(dir, string(rune('a'+i)))
+		if err := os.Mkdir(caseDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range c.files {
+			writeTestFile(t, filepath.Join(caseDir, name), content)
+		}
+		br := GetBufferedReader(filepath.Join(caseDir, "*"))
+		got, err := ioutil.ReadAll(br)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", c.desc, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("%s: got %q want %q", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestGetBufferedReaderNoFile(t *testing.T) {
+	oldFatal := fatal
+	defer func() {
+		fatal = oldFatal
+	}()
+	var msg string
+	fatal = func(format string, args ...interface{}) {
+		msg = format
+	}
+	if br := GetBufferedReader(filepath.Join(os.TempDir(), "no-such-file-*.gz")); br != nil {
+		t.Errorf("expected no reader")
+	}
+	if msg == "" {
+		t.Errorf("expected a fatal error")
+	}
+}
@@ -0,0 +1,216 @@
+package load
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// checkpointPeriod is the least time between two writes of the checkpoint
+// file, it is written once more at the end of the load
+const checkpointPeriod = time.Second
+
+// checkpoint is the progress of a load: the first items of the data that
+// were all loaded, and the metrics and rows they held
+type checkpoint struct {
+	Items   uint64 `json:"items"`
+	Metrics uint64 `json:"metrics"`
+	Rows    uint64 `json:"rows"`
+}
+
+// readCheckpoint reads the checkpoint file, ok is false if there is none
+func readCheckpoint(fileName string) (cp checkpoint, ok bool, err error) {
+	b, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return cp, false, nil
+	}
+	if err != nil {
+		return cp, false, err
+	}
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return cp, false, err
+	}
+	return cp, true, nil
+}
+
+// writeCheckpoint replaces the checkpoint file, through a temporary file so
+// a crash never leaves a partial checkpoint behind
+func writeCheckpoint(fileName string, cp checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fileName)
+}
+
+// batchRecord is what the checkpointer knows of a batch: its first and last
+// items, in the order they were read, and once acknowledged whether it was
+// loaded and what it held
+type batchRecord struct {
+	first, last   uint64
+	acked, loaded bool
+	metrics, rows uint64
+}
+
+// checkpointer keeps the checkpoint of a load up to date from the
+// acknowledgements of the batches. With several channels the batches are
+// acknowledged out of order, and a batch may hold items far apart, so the
+// checkpoint only moves past a batch once it and all the batches holding
+// items before its last one are loaded. A batch that failed holds it back
+// for good.
+type checkpointer struct {
+	fileName string
+	base     checkpoint
+	done     checkpoint
+	written  time.Time
+
+	// records of the batches not in the checkpoint yet, by first item
+	records []*batchRecord
+	// record of the batch being filled, per channel
+	filling []*batchRecord
+	// records of the batches sent or queued, per channel, in the order
+	// they are sent, from the batch numbered offset on
+	sent   [][]*batchRecord
+	offset []uint64
+}
+
+// newCheckpointer returns a checkpointer of the load of the batches sent on
+// channels, carrying on from the checkpoint base
+func newCheckpointer(fileName string, base checkpoint, channels []*duplexChannel) *checkpointer {
+	for _, ch := range channels {
+		ch.mu.Lock()
+		ch.tracked = true
+		ch.mu.Unlock()
+	}
+	return &checkpointer{
+		fileName: fileName,
+		base:     base,
+		written:  time.Now(),
+		filling:  make([]*batchRecord, len(channels)),
+		sent:     make([][]*batchRecord, len(channels)),
+		offset:   make([]uint64, len(channels)),
+	}
+}
+
+// appended records the item-th item read being appended to the batch of
+// channel idx
+func (c *checkpointer) appended(idx int, item uint64) {
+	if c == nil {
+		return
+	}
+	r := c.filling[idx]
+	if r == nil {
+		r = &batchRecord{first: item}
+		c.filling[idx] = r
+		c.records = append(c.records, r)
+	}
+	r.last = item
+}
+
+// queued records the batch of channel idx being sent or queued for sending
+func (c *checkpointer) queued(idx int) {
+	if c == nil {
+		return
+	}
+	c.sent[idx] = append(c.sent[idx], c.filling[idx])
+	c.filling[idx] = nil
+}
+
+// acknowledged takes the acknowledgements of channel ch, numbered idx, and
+// writes the checkpoint if it moved on and was not written for a while
+func (c *checkpointer) acknowledged(idx int, ch *duplexChannel) error {
+	if c == nil {
+		return nil
+	}
+	for _, a := range ch.takeAcks() {
+		r := c.sent[idx][a.seq-c.offset[idx]]
+		r.acked, r.loaded = true, !a.failed
+		r.metrics, r.rows = a.metrics, a.rows
+	}
+	for len(c.sent[idx]) > 0 && c.sent[idx][0].acked {
+		c.sent[idx] = c.sent[idx][1:]
+		c.offset[idx]++
+	}
+	if c.settle() && time.Since(c.written) >= checkpointPeriod {
+		return c.write()
+	}
+	return nil
+}
+
+// settle moves the checkpoint past the batches loaded before any batch
+// still to be loaded, returning whether it moved
+func (c *checkpointer) settle() bool {
+	var last, metrics, rows uint64
+	settled := 0
+	for i, r := range c.records {
+		if !r.loaded {
+			break
+		}
+		if r.last > last {
+			last = r.last
+		}
+		metrics += r.metrics
+		rows += r.rows
+		if i+1 == len(c.records) || last < c.records[i+1].first {
+			// no batch left holds an item up to last
+			settled = i + 1
+			c.done.Items = last
+			c.done.Metrics += metrics
+			c.done.Rows += rows
+			metrics, rows = 0, 0
+		}
+	}
+	c.records = c.records[settled:]
+	return settled > 0
+}
+
+// current returns the checkpoint of the load so far
+func (c *checkpointer) current() checkpoint {
+	return checkpoint{
+		Items:   c.base.Items + c.done.Items,
+		Metrics: c.base.Metrics + c.done.Metrics,
+		Rows:    c.base.Rows + c.done.Rows,
+	}
+}
+
+// write writes the checkpoint file
+func (c *checkpointer) write() error {
+	if c == nil {
+		return nil
+	}
+	c.written = time.Now()
+	return writeCheckpoint(c.fileName, c.current())
+}
+
+// skipDataSource skips the first items of a DataSource, the ones loaded
+// before resuming
+type skipDataSource struct {
+	targets.DataSource
+	skip uint64
+}
+
+func (d *skipDataSource) NextItem() data.LoadedPoint {
+	for ; d.skip > 0; d.skip-- {
+		if item := d.DataSource.NextItem(); item.Data == nil {
+			return item
+		}
+	}
+	return d.DataSource.NextItem()
+}
@@ -0,0 +1,160 @@
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/data"
+)
+
+func TestCheckpointerSettle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "checkpoint.json")
+
+	channels := []*duplexChannel{newDuplexChannel(10), newDuplexChannel(10)}
+	cp := newCheckpointer(fileName, checkpoint{Items: 100, Metrics: 1000, Rows: 100}, channels)
+
+	// channel 0 gets the batches [1, 3] and [4], channel 1 [2, 5] and [6]
+	cp.appended(0, 1)
+	cp.appended(1, 2)
+	cp.appended(0, 3)
+	cp.queued(0)
+	cp.appended(0, 4)
+	cp.queued(0)
+	cp.appended(1, 5)
+	cp.queued(1)
+	cp.appended(1, 6)
+	cp.queued(1)
+
+	ackAll := func(idx int, acks ...ack) {
+		channels[idx].acks = append(channels[idx].acks, acks...)
+		if err := cp.acknowledged(idx, channels[idx]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	check := func(desc string, want checkpoint) {
+		if got := cp.current(); got != want {
+			t.Errorf("%s: got checkpoint %+v want %+v", desc, got, want)
+		}
+	}
+
+	// the second batch of channel 0 is loaded first
+	cp.written = cp.written.Add(-checkpointPeriod)
+	ackAll(0, ack{seq: 1, metrics: 10, rows: 1})
+	check("batch [4] loaded", checkpoint{Items: 100, Metrics: 1000, Rows: 100})
+	ackAll(0, ack{seq: 0, metrics: 20, rows: 2})
+	check("batches [1, 3], [4] loaded", checkpoint{Items: 100, Metrics: 1000, Rows: 100})
+	// item 2 was held back by batch [2, 5], which holds back [4] with 5
+	ackAll(1, ack{seq: 0, metrics: 20, rows: 2})
+	check("batches [1, 3], [2, 5], [4] loaded", checkpoint{Items: 105, Metrics: 1050, Rows: 105})
+	ackAll(1, ack{seq: 1, metrics: 10, rows: 1, failed: true})
+	check("batch [6] failed", checkpoint{Items: 105, Metrics: 1050, Rows: 105})
+	if len(cp.sent[0]) != 0 || len(cp.sent[1]) != 0 {
+		t.Errorf("acknowledged batches left: %d and %d", len(cp.sent[0]), len(cp.sent[1]))
+	}
+	// the checkpoint was written once it moved, a period after the last write
+	got, ok, err := readCheckpoint(fileName)
+	want := checkpoint{Items: 105, Metrics: 1050, Rows: 105}
+	if !ok || err != nil || got != want {
+		t.Errorf("got checkpoint file %+v, ok %v, error %v, want %+v", got, ok, err, want)
+	}
+}
+
+func TestCheckpointFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "checkpoint.json")
+
+	if _, ok, err := readCheckpoint(fileName); ok || err != nil {
+		t.Errorf("missing checkpoint: got ok %v, error %v", ok, err)
+	}
+	want := checkpoint{Items: 3, Metrics: 30, Rows: 3}
+	if err := writeCheckpoint(fileName, want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, ok, err := readCheckpoint(fileName)
+	if !ok || err != nil || got != want {
+		t.Errorf("got checkpoint %+v, ok %v, error %v, want %+v", got, ok, err, want)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("got %d files want only the checkpoint", len(files))
+	}
+}
+
+func _ackingWorker(c *duplexChannel) {
+	for {
+		b, seq, ok := c.receive()
+		if !ok {
+			return
+		}
+		c.acknowledge(ack{seq: seq, metrics: uint64(b.Len()) * 10, rows: uint64(b.Len())})
+	}
+}
+
+type testOddIndexer struct{}
+
+func (i *testOddIndexer) GetIndex(p data.LoadedPoint) uint {
+	return uint(p.Data.(byte) % 2)
+}
+
+func TestScanWithCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "checkpoint.json")
+
+	testData := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	channels := []*duplexChannel{newDuplexChannel(1), newDuplexChannel(1)}
+	for _, ch := range channels {
+		go _ackingWorker(ch)
+	}
+	testDataSource := &testDataSource{br: bufio.NewReader(bytes.NewReader(testData))}
+	cp := newCheckpointer(fileName, checkpoint{Items: 2, Metrics: 20, Rows: 2}, channels)
+	read := scanWithFlowControl(channels, 2, 0, testDataSource, &testFactory{}, &testOddIndexer{}, nil, cp)
+	for _, ch := range channels {
+		ch.close()
+	}
+	if read != 7 {
+		t.Errorf("got %d items read want 7", read)
+	}
+	got, ok, err := readCheckpoint(fileName)
+	want := checkpoint{Items: 9, Metrics: 90, Rows: 9}
+	if !ok || err != nil || got != want {
+		t.Errorf("got checkpoint %+v, ok %v, error %v, want %+v", got, ok, err, want)
+	}
+}
+
+func TestSkipDataSource(t *testing.T) {
+	testData := []byte{0x01, 0x02, 0x03}
+	ds := &skipDataSource{
+		DataSource: &testDataSource{br: bufio.NewReader(bytes.NewReader(testData))},
+		skip:       2,
+	}
+	if item := ds.NextItem(); item.Data != byte(0x03) {
+		t.Errorf("got item %v want 3", item.Data)
+	}
+	if item := ds.NextItem(); item.Data != nil {
+		t.Errorf("got item %v after the end", item.Data)
+	}
+
+	ds = &skipDataSource{
+		DataSource: &testDataSource{br: bufio.NewReader(bytes.NewReader(testData))},
+		skip:       5,
+	}
+	if item := ds.NextItem(); item.Data != nil {
+		t.Errorf("got item %v when skipping past the end", item.Data)
+	}
+}
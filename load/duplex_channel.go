@@ -1,6 +1,10 @@
 package load
 
-import "github.com/timescale/tsbs/pkg/targets"
+import (
+	"sync"
+
+	"github.com/timescale/tsbs/pkg/targets"
+)
 
 // duplexChannel acts as a two-way channel for communicating from a scan routine
 // to a worker goroutine. The toWorker channel sends data to the worker for it
@@ -9,6 +13,23 @@ import "github.com/timescale/tsbs/pkg/targets"
 type duplexChannel struct {
 	toWorker  chan targets.Batch
 	toScanner chan bool
+
+	// The acknowledgements are logged when tracked, telling the scanner
+	// which batch was processed: batches are numbered in the order the
+	// workers receive them, which is the order they were sent in.
+	recvMu   sync.Mutex
+	received uint64
+	mu       sync.Mutex
+	tracked  bool
+	acks     []ack
+}
+
+// ack is the acknowledgement of a processed batch
+type ack struct {
+	seq     uint64
+	metrics uint64
+	rows    uint64
+	failed  bool
 }
 
 // newDuplexChannel returns a duplexChannel with specified buffer sizes
@@ -24,6 +45,39 @@ func (dc *duplexChannel) sendToWorker(b targets.Batch) {
 	dc.toWorker <- b
 }
 
+// receive receives a batch from the scanner, along with its number in the
+// order the batches were sent. ok is false once the channel is closed.
+func (dc *duplexChannel) receive() (b targets.Batch, seq uint64, ok bool) {
+	dc.recvMu.Lock()
+	defer dc.recvMu.Unlock()
+	b, ok = <-dc.toWorker
+	if ok {
+		seq = dc.received
+		dc.received++
+	}
+	return b, seq, ok
+}
+
+// acknowledge logs the acknowledgement of the batch seq if tracked, then
+// passes it on to the scanner
+func (dc *duplexChannel) acknowledge(a ack) {
+	dc.mu.Lock()
+	if dc.tracked {
+		dc.acks = append(dc.acks, a)
+	}
+	dc.mu.Unlock()
+	dc.sendToScanner()
+}
+
+// takeAcks returns the acknowledgements logged since the last call
+func (dc *duplexChannel) takeAcks() []ack {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	acks := dc.acks
+	dc.acks = nil
+	return acks
+}
+
 // sendToScanner passes an acknowledge to the scanner from the worker
 func (dc *duplexChannel) sendToScanner() {
 	dc.toScanner <- true
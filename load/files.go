@@ -0,0 +1,61 @@
+package load
+
+import (
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// filesDataSource reads the items of several files in turns, one item of
+// each file after the other, skipping the files that ended, and remembers
+// the file of the last item so it can be indexed by file
+type filesDataSource struct {
+	sources []targets.DataSource
+	// files are the numbers of the files not ended yet
+	files []int
+	next  int
+	last  int
+}
+
+func newFilesDataSource(sources []targets.DataSource) *filesDataSource {
+	files := make([]int, len(sources))
+	for i := range files {
+		files[i] = i
+	}
+	return &filesDataSource{sources: sources, files: files}
+}
+
+func (d *filesDataSource) NextItem() data.LoadedPoint {
+	for len(d.files) > 0 {
+		if d.next >= len(d.files) {
+			d.next = 0
+		}
+		file := d.files[d.next]
+		item := d.sources[file].NextItem()
+		if item.Data == nil {
+			d.files = append(d.files[:d.next], d.files[d.next+1:]...)
+			continue
+		}
+		d.last = file
+		d.next++
+		return item
+	}
+	return data.LoadedPoint{}
+}
+
+// Headers returns the headers of the first file, the files of a data set
+// all have the same
+func (d *filesDataSource) Headers() *common.GeneratedDataHeaders {
+	return d.sources[0].Headers()
+}
+
+// fileIndexer assigns the items to the channels by the file they were read
+// from
+type fileIndexer struct {
+	ds         *filesDataSource
+	partitions uint
+}
+
+func (i *fileIndexer) GetIndex(_ data.LoadedPoint) uint {
+	return uint(i.ds.last) % i.partitions
+}
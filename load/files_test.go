@@ -0,0 +1,41 @@
+package load
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+func TestFilesDataSource(t *testing.T) {
+	var sources []targets.DataSource
+	for _, content := range []string{"abc", "", "d", "efgh"} {
+		sources = append(sources, &testDataSource{br: bufio.NewReader(strings.NewReader(content))})
+	}
+	ds := newFilesDataSource(sources)
+	indexer := &fileIndexer{ds: ds, partitions: 2}
+
+	var items []byte
+	var indexes []uint
+	for item := ds.NextItem(); item.Data != nil; item = ds.NextItem() {
+		items = append(items, item.Data.(byte))
+		indexes = append(indexes, indexer.GetIndex(item))
+	}
+	if got, want := string(items), "adebfcgh"; got != want {
+		t.Errorf("got items %q want %q", got, want)
+	}
+	// file 2 goes to channel 0, file 3 to channel 1
+	wantIndexes := []uint{0, 0, 1, 0, 1, 0, 1, 1}
+	if len(indexes) != len(wantIndexes) {
+		t.Fatalf("got %d indexes want %d", len(indexes), len(wantIndexes))
+	}
+	for i := range wantIndexes {
+		if indexes[i] != wantIndexes[i] {
+			t.Errorf("item %c: got index %d want %d", items[i], indexes[i], wantIndexes[i])
+		}
+	}
+	if item := ds.NextItem(); item.Data != nil {
+		t.Errorf("expected no item after the end, got %v", item.Data)
+	}
+}
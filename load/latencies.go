@@ -0,0 +1,92 @@
+package load
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	// batch latencies are recorded in microseconds, up to an hour
+	maxBatchLatency     = int64(time.Hour / time.Microsecond)
+	latencyScaleFactor  = 1e3
+	latencySignificants = 3
+)
+
+// batchLatencies is the HDR histogram of the time workers take to process
+// a batch. A nil batchLatencies records nothing.
+type batchLatencies struct {
+	mu   sync.Mutex
+	hist *hdrhistogram.Histogram
+}
+
+func newBatchLatencies() *batchLatencies {
+	return &batchLatencies{hist: hdrhistogram.New(1, maxBatchLatency, latencySignificants)}
+}
+
+// record records the time a batch took
+func (b *batchLatencies) record(took time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	// latencies over the maximum are dropped by the histogram
+	_ = b.hist.RecordValue(int64(took / time.Microsecond))
+	b.mu.Unlock()
+}
+
+// count returns the number of batches recorded
+func (b *batchLatencies) count() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.hist.TotalCount()
+}
+
+// quantiles returns the statistics of the latencies in milliseconds
+func (b *batchLatencies) quantiles() map[string]float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return map[string]float64{
+		"min":  float64(b.hist.Min()) / latencyScaleFactor,
+		"mean": b.hist.Mean() / latencyScaleFactor,
+		"p50":  float64(b.hist.ValueAtQuantile(50)) / latencyScaleFactor,
+		"p90":  float64(b.hist.ValueAtQuantile(90)) / latencyScaleFactor,
+		"p99":  float64(b.hist.ValueAtQuantile(99)) / latencyScaleFactor,
+		"max":  float64(b.hist.Max()) / latencyScaleFactor,
+	}
+}
+
+// string describes the latencies for the summary
+func (b *batchLatencies) string() string {
+	q := b.quantiles()
+	return fmt.Sprintf("batch latency: min: %0.2fms, mean: %0.2fms, p50: %0.2fms, p90: %0.2fms, p99: %0.2fms, max: %0.2fms, count: %d",
+		q["min"], q["mean"], q["p50"], q["p90"], q["p99"], q["max"], b.count())
+}
+
+// writeFile writes the percentiles of the histogram to a file, in the same
+// format as the query latencies of the query runners
+func (b *batchLatencies) writeFile(fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	b.mu.Lock()
+	_, err = b.hist.PercentilesPrint(bw, 10, latencyScaleFactor)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
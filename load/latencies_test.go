@@ -0,0 +1,59 @@
+package load
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchLatencies(t *testing.T) {
+	var none *batchLatencies
+	none.record(time.Millisecond)
+	if got := none.count(); got != 0 {
+		t.Errorf("nil latencies: got count %d want 0", got)
+	}
+
+	l := newBatchLatencies()
+	for _, took := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 1500 * time.Microsecond} {
+		l.record(took)
+	}
+	if got := l.count(); got != 3 {
+		t.Errorf("got count %d want 3", got)
+	}
+	want := map[string]float64{"min": 1, "mean": 1.5, "p50": 1.5, "p90": 2, "p99": 2, "max": 2}
+	got := l.quantiles()
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %v want %v", k, got[k], v)
+		}
+	}
+	wantStr := "batch latency: min: 1.00ms, mean: 1.50ms, p50: 1.50ms, p90: 2.00ms, p99: 2.00ms, max: 2.00ms, count: 3"
+	if got := l.string(); got != wantStr {
+		t.Errorf("got string\n%s\nwant\n%s", got, wantStr)
+	}
+}
+
+func TestBatchLatenciesWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "latencies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := newBatchLatencies()
+	l.record(time.Millisecond)
+	fileName := filepath.Join(dir, "hdr.txt")
+	if err := l.writeFile(fileName); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Value") || !strings.Contains(string(b), "1.000") {
+		t.Errorf("unexpected histogram file:\n%s", b)
+	}
+}
@@ -27,11 +27,12 @@ func (l *noFlowBenchmarkRunner) RunBenchmark(b targets.Benchmark) {
 		go l.work(b, wg, channels[i%numChannels], i)
 	}
 	// Start scan process - actual data read process
-	scanWithoutFlowControl(b.GetDataSource(), b.GetPointIndexer(numChannels), b.GetBatchFactory(), channels, l.BatchSize, l.Limit)
+	ds, indexer := l.dataSource(b, numChannels, *start)
+	scanWithoutFlowControl(ds, indexer, b.GetBatchFactory(), channels, l.BatchSize, l.limit(), l.schedule)
 	for _, c := range channels {
 		close(c)
 	}
-	l.postRun(wg, start)
+	l.postRun(b, wg, start)
 }
 
 // createChannels create channels from which workers would receive tasks
@@ -54,10 +55,17 @@ func (l *noFlowBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 	// Process batches coming from the incoming queue (c)
 	for batch := range c {
 		startedWorkAt := time.Now()
+		l.metrics.started(workerNum)
 		l.currPoc = &proc
+		items := uint64(batch.Len())
 		metricCnt, rowCnt := proc.ProcessBatch(batch, l.DoLoad)
+		took := time.Since(startedWorkAt)
+		l.latencies.record(took)
+		l.metrics.done(workerNum, took)
 		atomic.AddUint64(&l.metricCnt, metricCnt)
 		atomic.AddUint64(&l.rowCnt, rowCnt)
+		l.warmup.batchLoaded(items, took)
+		l.addFailures(proc)
 		l.timeToSleep(workerNum, startedWorkAt)
 	}
 
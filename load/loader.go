@@ -44,6 +44,25 @@ type BenchmarkRunnerConfig struct {
 	ChannelCapacity uint          `yaml:"channel-capacity" mapstructure:"channel-capacity" json:"channel-capacity"`
 	InsertIntervals string        `yaml:"insert-intervals" mapstructure:"insert-intervals" json:"insert-intervals"`
 	ResultsFile     string        `yaml:"results-file" mapstructure:"results-file" json:"results-file"`
+	// HDRLatenciesFile is the file the HDR histogram of the batch latencies
+	// is written to
+	HDRLatenciesFile string `yaml:"hdr-latencies" mapstructure:"hdr-latencies" json:"hdr-latencies"`
+	// Duration is the time to load data for, 0 for no time limit
+	Duration time.Duration `yaml:"duration" mapstructure:"duration" json:"duration"`
+	// TargetRate is the number of items per second the batches are released
+	// to the workers at, however fast they load them, 0 to release them as
+	// soon as they are full
+	TargetRate float64 `yaml:"target-rate" mapstructure:"target-rate" json:"target-rate"`
+	// Checkpoint is the file the number of items loaded is kept in, along
+	// with the metrics and rows they held, to resume the load from
+	Checkpoint string `yaml:"checkpoint" mapstructure:"checkpoint" json:"checkpoint"`
+	Resume     bool   `yaml:"resume" mapstructure:"resume" json:"resume"`
+	// MetricsListen is the address the metrics of the load are served on
+	// over HTTP, in the Prometheus format
+	MetricsListen string `yaml:"metrics-listen" mapstructure:"metrics-listen" json:"metrics-listen"`
+	// Warmup is the number of items or the duration at the start of the
+	// load left out of the post warm-up stats
+	Warmup string `yaml:"warmup" mapstructure:"warmup" json:"warmup"`
 	// deprecated, should not be used in other places other than tsbs_load_xx commands
 	FileName string `yaml:"file" mapstructure:"file" json:"file"`
 	Seed     int64  `yaml:"seed" mapstructure:"seed" json:"seed"`
@@ -59,11 +78,18 @@ func (c BenchmarkRunnerConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.Bool("do-create-db", true, "Whether to create the database. Disable on all but one client if running on a multi client setup.")
 	fs.Bool("do-abort-on-exist", false, "Whether to abort if a database with the given name already exists.")
 	fs.Duration("reporting-period", 1*time.Second, "Period to report write stats")
-	fs.String("file", "", "File name to read data from")
+	fs.String("file", "", "File name to read data from, or a comma separated list or glob of files, possibly gzip or zstd compressed")
 	fs.Int64("seed", 0, "PRNG seed (default: 0, which uses the current timestamp)")
 	fs.String("insert-intervals", "", "Time to wait between each insert, default '' => all workers insert ASAP. '1,2' = worker 1 waits 1s between inserts, worker 2 and others wait 2s")
 	fs.Bool("hash-workers", false, "Whether to consistently hash insert data to the same workers (i.e., the data for a particular host always goes to the same worker)")
 	fs.String("results-file", "", "Write the test results summary json to this file")
+	fs.String("hdr-latencies", "", "Write the High Dynamic Range (HDR) Histogram of the batch insert latencies to this file")
+	fs.Duration("duration", 0, "Time to load data for, stopping earlier at the limit or the end of the data (0 = no time limit)")
+	fs.String("checkpoint", "", "Keep the number of items loaded in this file as the load goes, to resume it from (needs flow control)")
+	fs.Bool("resume", false, "Resume the load from the checkpoint file, skipping the items it loaded and keeping the existing data, or start it if there is no checkpoint file yet")
+	fs.String("warmup", "", "Number of items (e.g. 100000) or duration (e.g. 30s) at the start of the load left out of the post warm-up throughput and latency stats")
+	fs.String("metrics-listen", "", "Serve the metrics of the load on /metrics at this address (e.g. ':9090'), in the Prometheus format")
+	fs.Float64("target-rate", 0, "Items (rows, or points for the formats with one point per item) per second to release to the workers on a fixed schedule, however fast they load them, reporting how far behind the schedule the load falls (0 = as fast as the workers load them)")
 }
 
 type BenchmarkRunner interface {
@@ -77,9 +103,17 @@ type CommonBenchmarkRunner struct {
 	BenchmarkRunnerConfig
 	metricCnt      uint64
 	rowCnt         uint64
+	failures       targets.WriteFailures
 	initialRand    *rand.Rand
 	currPoc        *targets.Processor
 	sleepRegulator insertstrategy.SleepRegulator
+	latencies      *batchLatencies
+	schedule       *schedule
+	resumed        checkpoint
+	metrics        *loadMetrics
+	warmupItems    uint64
+	warmupDuration time.Duration
+	warmup         *warmup
 }
 
 // GetBenchmarkRunnerWithBatchSize returns the singleton CommonBenchmarkRunner for use in a benchmark program
@@ -93,6 +127,26 @@ func GetBenchmarkRunner(c BenchmarkRunnerConfig) BenchmarkRunner {
 	}
 
 	loader.initialRand = rand.New(rand.NewSource(loader.Seed))
+	loader.latencies = newBatchLatencies()
+	if c.Duration < 0 {
+		panic(fmt.Sprintf("could not initialize BenchmarkRunner: negative duration %s", c.Duration))
+	}
+	if c.TargetRate < 0 {
+		panic(fmt.Sprintf("could not initialize BenchmarkRunner: negative target rate %f", c.TargetRate))
+	}
+	if c.Warmup != "" {
+		var err error
+		loader.warmupItems, loader.warmupDuration, err = parseWarmup(c.Warmup)
+		if err != nil {
+			panic(fmt.Sprintf("could not initialize BenchmarkRunner: %v", err))
+		}
+	}
+	if c.Checkpoint != "" && c.NoFlowControl {
+		panic("could not initialize BenchmarkRunner: the checkpoint is kept from the acknowledgements of the flow control")
+	}
+	if c.Resume && c.Checkpoint == "" {
+		panic("could not initialize BenchmarkRunner: there is no checkpoint file to resume from")
+	}
 
 	var err error
 	if c.InsertIntervals == "" {
@@ -124,32 +178,106 @@ func (l *CommonBenchmarkRunner) DatabaseName() string {
 }
 
 func (l *CommonBenchmarkRunner) preRun(b targets.Benchmark) (*sync.WaitGroup, *time.Time) {
+	if l.Resume {
+		cp, ok, err := readCheckpoint(l.Checkpoint)
+		if err != nil {
+			fatal("cannot read the checkpoint: %v", err)
+		}
+		if ok {
+			printFn("resuming after the first %d items, with %d metrics and %d rows loaded\n", cp.Items, cp.Metrics, cp.Rows)
+			l.resumed = cp
+		}
+	}
+
 	// Create required DB
 	if b.GetDBCreator() != nil {
 		cleanupFn := l.useDBCreator(b.GetDBCreator())
 		defer cleanupFn()
 	}
 
+	if l.MetricsListen != "" {
+		l.serveMetrics()
+	}
+	start := time.Now()
+	if l.TargetRate > 0 {
+		l.schedule = newSchedule(l.TargetRate, start)
+	}
+	if l.warmupItems > 0 || l.warmupDuration > 0 {
+		l.warmup = newWarmup(l.warmupItems, l.warmupDuration, start, func() (uint64, uint64) {
+			return atomic.LoadUint64(&l.metricCnt), atomic.LoadUint64(&l.rowCnt)
+		})
+	}
 	if l.ReportingPeriod.Nanoseconds() > 0 {
 		go l.report(l.ReportingPeriod)
 	}
 	wg := &sync.WaitGroup{}
 	wg.Add(int(l.Workers))
-	start := time.Now()
 	return wg, &start
 }
 
-func (l *CommonBenchmarkRunner) postRun(wg *sync.WaitGroup, start *time.Time) {
+// dataSource returns the DataSource of b, limited by limitDataSource, and the
+// PointIndexer of its items to numChannels channels. With hashed workers the
+// data of several files is indexed by file.
+func (l *CommonBenchmarkRunner) dataSource(b targets.Benchmark, numChannels uint, start time.Time) (targets.DataSource, targets.PointIndexer) {
+	var ds targets.DataSource
+	var indexer targets.PointIndexer
+	if fs, ok := b.(targets.FileSplitter); ok && l.HashWorkers && numChannels > 1 {
+		if sources := fs.FileDataSources(); len(sources) > 1 {
+			files := newFilesDataSource(sources)
+			ds, indexer = files, &fileIndexer{ds: files, partitions: numChannels}
+		}
+	}
+	if ds == nil {
+		ds, indexer = b.GetDataSource(), b.GetPointIndexer(numChannels)
+	}
+	return l.limitDataSource(ds, start), indexer
+}
+
+// limitDataSource returns ds without the items loaded before resuming,
+// ending at the duration of the load since start if there is one
+func (l *CommonBenchmarkRunner) limitDataSource(ds targets.DataSource, start time.Time) targets.DataSource {
+	if l.resumed.Items > 0 {
+		ds = &skipDataSource{DataSource: ds, skip: l.resumed.Items}
+	}
+	if l.Limit > 0 && l.resumed.Items >= l.Limit {
+		// the limit was loaded before resuming
+		return &deadlineDataSource{DataSource: ds, deadline: start}
+	}
+	if l.Duration > 0 {
+		ds = &deadlineDataSource{DataSource: ds, deadline: start.Add(l.Duration)}
+	}
+	return ds
+}
+
+func (l *CommonBenchmarkRunner) postRun(b targets.Benchmark, wg *sync.WaitGroup, start *time.Time) {
 	// Wait for all workers to finish
 	wg.Wait()
 	end := time.Now()
 	took := end.Sub(*start)
+	l.warmup.stop()
 	l.summary(took)
+	if l.warmup != nil {
+		l.warmupSummary(end)
+	}
+	if bs, ok := b.(targets.BenchmarkSummarizer); ok {
+		if summary := bs.Summary(); summary != "" {
+			printFn("%s\n", summary)
+		}
+	}
+	if l.HDRLatenciesFile != "" && l.latencies.count() > 0 {
+		printFn("Saving High Dynamic Range (HDR) Histogram of batch latencies to %s\n", l.HDRLatenciesFile)
+		if err := l.latencies.writeFile(l.HDRLatenciesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 	if l.BenchmarkRunnerConfig.ResultsFile != "" {
 		metricRate := float64(l.metricCnt) / took.Seconds()
 		rowRate := float64(l.rowCnt) / took.Seconds()
 		l.saveTestResult(took, *start, end, metricRate, rowRate)
 	}
+	if l.failures.Batches > 0 {
+		fatal("failed to load %d batches", l.failures.Batches)
+	}
 }
 
 func (l *CommonBenchmarkRunner) saveTestResult(took time.Duration, start time.Time, end time.Time, metricRate, rowRate float64) {
@@ -158,6 +286,29 @@ func (l *CommonBenchmarkRunner) saveTestResult(took time.Duration, start time.Ti
 	if l.rowCnt > 0 {
 		totals["rowRate"] = rowRate
 	}
+	totals["failedBatches"] = l.failures.Batches
+	totals["failedMetrics"] = l.failures.Metrics
+	totals["failedRows"] = l.failures.Rows
+	totals["retries"] = l.failures.Retries
+	if count := l.latencies.count(); count > 0 {
+		// batch latencies in milliseconds
+		latencies := l.latencies.quantiles()
+		latencies["count"] = float64(count)
+		totals["batchLatencies"] = latencies
+	}
+	if l.schedule != nil {
+		totals["schedule"] = l.schedule.totals()
+	}
+	if l.warmup != nil {
+		totals["afterWarmup"] = l.warmupTotals(end)
+	}
+	if l.resumed.Items > 0 {
+		totals["resumedItems"] = l.resumed.Items
+		totals["resumedMetrics"] = l.resumed.Metrics
+		totals["resumedRows"] = l.resumed.Rows
+		totals["totalMetrics"] = l.resumed.Metrics + l.metricCnt
+		totals["totalRows"] = l.resumed.Rows + l.rowCnt
+	}
 
 	testResult := LoaderTestResult{
 		ResultFormatVersion: LoaderTestResultVersion,
@@ -199,8 +350,14 @@ func (l *CommonBenchmarkRunner) RunBenchmark(b targets.Benchmark) {
 		go l.work(b, wg, channels[i%numChannels], i)
 	}
 
+	var cp *checkpointer
+	if l.Checkpoint != "" {
+		cp = newCheckpointer(l.Checkpoint, l.resumed, channels)
+	}
+
 	// Start scan process - actual data read process
-	scanWithFlowControl(channels, l.BatchSize, l.Limit, b.GetDataSource(), b.GetBatchFactory(), b.GetPointIndexer(uint(len(channels))))
+	ds, indexer := l.dataSource(b, uint(len(channels)), *start)
+	scanWithFlowControl(channels, l.BatchSize, l.limit(), ds, b.GetBatchFactory(), indexer, l.schedule, cp)
 	// After scan process completed (no more data to come) - begin shutdown process
 
 	// Close all communication channels to/from workers
@@ -208,7 +365,15 @@ func (l *CommonBenchmarkRunner) RunBenchmark(b targets.Benchmark) {
 		c.close()
 	}
 
-	l.postRun(wg, start)
+	l.postRun(b, wg, start)
+}
+
+// limit returns the number of items left to load up to the limit, if any
+func (l *CommonBenchmarkRunner) limit() uint64 {
+	if l.Limit == 0 || l.resumed.Items >= l.Limit {
+		return 0
+	}
+	return l.Limit - l.resumed.Items
 }
 
 // useDBCreator handles a DBCreator by running it according to flags set by the
@@ -234,8 +399,8 @@ func (l *CommonBenchmarkRunner) useDBCreator(dbc targets.DBCreator) func() {
 		}
 
 		// Create required DB if need be
-		// In case DB already exists - delete it
-		if l.DoCreateDB {
+		// In case DB already exists - delete it, unless resuming the load
+		if l.DoCreateDB && !(exists && l.resumed.Items > 0) {
 			if exists {
 				err := dbc.RemoveOldDB(l.DBName)
 				if err != nil {
@@ -281,12 +446,23 @@ func (l *CommonBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 
 	// Process batches coming from duplexChannel.toWorker queue
 	// and send ACKs into duplexChannel.toScanner queue
-	for batch := range c.toWorker {
+	for {
+		batch, seq, ok := c.receive()
+		if !ok {
+			break
+		}
 		startedWorkAt := time.Now()
+		l.metrics.started(workerNum)
+		items := uint64(batch.Len())
 		metricCnt, rowCnt := proc.ProcessBatch(batch, l.DoLoad)
+		took := time.Since(startedWorkAt)
+		l.latencies.record(took)
+		l.metrics.done(workerNum, took)
 		atomic.AddUint64(&l.metricCnt, metricCnt)
 		atomic.AddUint64(&l.rowCnt, rowCnt)
-		c.sendToScanner()
+		l.warmup.batchLoaded(items, took)
+		failed := l.addFailures(proc)
+		c.acknowledge(ack{seq: seq, metrics: metricCnt, rows: rowCnt, failed: failed})
 		l.timeToSleep(workerNum, startedWorkAt)
 	}
 
@@ -299,6 +475,21 @@ func (l *CommonBenchmarkRunner) work(b targets.Benchmark, wg *sync.WaitGroup, c
 	wg.Done()
 }
 
+// addFailures adds the write failures of proc since its last batch, if it
+// counts them, returning whether the batch failed
+func (l *CommonBenchmarkRunner) addFailures(proc targets.Processor) bool {
+	fc, ok := proc.(targets.ProcessorFailureCounter)
+	if !ok {
+		return false
+	}
+	f := fc.Failures()
+	atomic.AddUint64(&l.failures.Batches, f.Batches)
+	atomic.AddUint64(&l.failures.Metrics, f.Metrics)
+	atomic.AddUint64(&l.failures.Rows, f.Rows)
+	atomic.AddUint64(&l.failures.Retries, f.Retries)
+	return f.Batches > 0
+}
+
 func (l *CommonBenchmarkRunner) timeToSleep(workerNum uint, startedWorkAt time.Time) {
 	if l.sleepRegulator != nil {
 		l.sleepRegulator.Sleep(int(workerNum), startedWorkAt)
@@ -314,6 +505,20 @@ func (l *CommonBenchmarkRunner) summary(took time.Duration) {
 		rowRate := float64(l.rowCnt) / float64(took.Seconds())
 		printFn("loaded %d rows in %0.3fsec with %d workers (mean rate %0.2f rows/sec)\n", l.rowCnt, took.Seconds(), l.Workers, rowRate)
 	}
+	if l.latencies.count() > 0 {
+		printFn("%s\n", l.latencies.string())
+	}
+	if l.schedule != nil {
+		printFn("%s\n", l.schedule.string())
+	}
+	if l.resumed.Items > 0 {
+		printFn("resumed after %d items, loaded %d metrics and %d rows in total\n",
+			l.resumed.Items, l.resumed.Metrics+l.metricCnt, l.resumed.Rows+l.rowCnt)
+	}
+	if l.failures.Batches > 0 || l.failures.Retries > 0 {
+		printFn("failed to load %d metrics (%d rows) in %d batches, with %d retries\n",
+			l.failures.Metrics, l.failures.Rows, l.failures.Batches, l.failures.Retries)
+	}
 }
 
 // report handles periodic reporting of loading stats
@@ -322,11 +527,21 @@ func (l *CommonBenchmarkRunner) report(period time.Duration) {
 	prevTime := start
 	prevColCount := uint64(0)
 	prevRowCount := uint64(0)
+	prevFailures := targets.WriteFailures{}
 
-	printFn("time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s\n")
+	// with a target rate, the lag behind the schedule is a last column
+	header := "time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s"
+	if l.schedule != nil {
+		header += ",lag sec"
+	}
+	printFn("%s\n", header)
 	for now := range time.NewTicker(period).C {
 		cCount := atomic.LoadUint64(&l.metricCnt)
 		rCount := atomic.LoadUint64(&l.rowCnt)
+		lag := ""
+		if l.schedule != nil {
+			lag = fmt.Sprintf(",%0.3f", l.schedule.current().Seconds())
+		}
 
 		sinceStart := now.Sub(start)
 		took := now.Sub(prevTime)
@@ -335,13 +550,26 @@ func (l *CommonBenchmarkRunner) report(period time.Duration) {
 		if rCount > 0 {
 			rowrate := float64(rCount-prevRowCount) / float64(took.Seconds())
 			overallRowRate := float64(rCount) / float64(sinceStart.Seconds())
-			printFn("%d,%0.2f,%E,%0.2f,%0.2f,%E,%0.2f\n", now.Unix(), colrate, float64(cCount), overallColRate, rowrate, float64(rCount), overallRowRate)
+			printFn("%d,%0.2f,%E,%0.2f,%0.2f,%E,%0.2f%s\n", now.Unix(), colrate, float64(cCount), overallColRate, rowrate, float64(rCount), overallRowRate, lag)
 		} else {
-			printFn("%d,%0.2f,%E,%0.2f,-,-,-\n", now.Unix(), colrate, float64(cCount), overallColRate)
+			printFn("%d,%0.2f,%E,%0.2f,-,-,-%s\n", now.Unix(), colrate, float64(cCount), overallColRate, lag)
+		}
+
+		// failed batches are logged apart from the CSV, in the periods they
+		// happen, the retries are only counted in the summary
+		failures := targets.WriteFailures{
+			Batches: atomic.LoadUint64(&l.failures.Batches),
+			Metrics: atomic.LoadUint64(&l.failures.Metrics),
+			Rows:    atomic.LoadUint64(&l.failures.Rows),
+		}
+		if failures.Batches != prevFailures.Batches {
+			log.Printf("failed to load %d metrics (%d rows) in %d batches so far\n",
+				failures.Metrics, failures.Rows, failures.Batches)
 		}
 
 		prevColCount = cCount
 		prevRowCount = rCount
+		prevFailures = failures
 		prevTime = now
 	}
 }
@@ -3,7 +3,10 @@ package load
 import (
 	"bytes"
 	"fmt"
+	"github.com/timescale/tsbs/internal/metrics"
 	"github.com/timescale/tsbs/pkg/targets"
+	"log"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -28,6 +31,26 @@ func (p *testProcessor) Close(_ bool) {
 	p.closed = true
 }
 
+// testFailingProcessor fails to write every batch after one retry
+type testFailingProcessor struct {
+	testProcessor
+	failures targets.WriteFailures
+}
+
+func (p *testFailingProcessor) ProcessBatch(targets.Batch, bool) (metricCount, rowCount uint64) {
+	p.failures.Batches++
+	p.failures.Metrics += 2
+	p.failures.Rows++
+	p.failures.Retries++
+	return 0, 0
+}
+
+func (p *testFailingProcessor) Failures() targets.WriteFailures {
+	f := p.failures
+	p.failures = targets.WriteFailures{}
+	return f
+}
+
 type testCreator struct {
 	exists    bool
 	errRemove bool
@@ -306,7 +329,7 @@ func TestCreateChannelsAndPartitions(t *testing.T) {
 }
 
 func TestWork(t *testing.T) {
-	br := &CommonBenchmarkRunner{}
+	br := &CommonBenchmarkRunner{latencies: newBatchLatencies()}
 	b := &testBenchmark{}
 	for i := 0; i < 2; i++ {
 		b.processors = append(b.processors, &testProcessor{})
@@ -336,6 +359,10 @@ func TestWork(t *testing.T) {
 		t.Errorf("TestWork: invalid metric count: got %d want %d", got, 2)
 	}
 
+	if got := br.latencies.count(); got != 2 {
+		t.Errorf("TestWork: invalid batch latency count: got %d want %d", got, 2)
+	}
+
 	if !b.processors[0].closed {
 		t.Errorf("TestWork: processor 0 not closed")
 	}
@@ -345,6 +372,67 @@ func TestWork(t *testing.T) {
 	}
 }
 
+type testFailingBenchmark struct {
+	testBenchmark
+	processor *testFailingProcessor
+}
+
+func (b *testFailingBenchmark) GetProcessor() targets.Processor {
+	return b.processor
+}
+
+func TestWorkMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	br := &CommonBenchmarkRunner{metrics: &loadMetrics{
+		latency:  r.NewHistogramVec("latency", "", "worker", metrics.LatencyBuckets),
+		inFlight: r.NewGaugeVec("in_flight", "", "worker"),
+	}}
+	b := &testBenchmark{processors: []*testProcessor{{}}}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	c := newDuplexChannel(2)
+	c.sendToWorker(&testBatch{})
+	c.sendToWorker(&testBatch{})
+	go br.work(b, &wg, c, 0)
+	<-c.toScanner
+	<-c.toScanner
+	c.close()
+	wg.Wait()
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{`latency_count{worker="0"} 2`, `in_flight{worker="0"} 0`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%s missing from the metrics:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWorkCountsFailures(t *testing.T) {
+	br := &CommonBenchmarkRunner{}
+	b := &testFailingBenchmark{processor: &testFailingProcessor{}}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	c := newDuplexChannel(2)
+	c.sendToWorker(&testBatch{})
+	c.sendToWorker(&testBatch{})
+	go br.work(b, &wg, c, 0)
+	<-c.toScanner
+	<-c.toScanner
+	c.close()
+	wg.Wait()
+
+	want := targets.WriteFailures{Batches: 2, Metrics: 4, Rows: 2, Retries: 2}
+	if got := br.failures; got != want {
+		t.Errorf("incorrect failures: got %+v want %+v", got, want)
+	}
+	if got := br.metricCnt; got != 0 {
+		t.Errorf("invalid metric count: got %d want %d", got, 0)
+	}
+}
+
 func TestWorkWithSleep(t *testing.T) {
 	br := &CommonBenchmarkRunner{
 		sleepRegulator: &testSleepRegulator{lock: sync.Mutex{}},
@@ -380,11 +468,12 @@ func TestWorkWithSleep(t *testing.T) {
 
 func TestSummary(t *testing.T) {
 	cases := []struct {
-		desc    string
-		metrics uint64
-		rows    uint64
-		took    time.Duration
-		want    string
+		desc     string
+		metrics  uint64
+		rows     uint64
+		failures targets.WriteFailures
+		took     time.Duration
+		want     string
 	}{
 		{
 			desc:    "10 metrics, 0 rows, 1 second",
@@ -414,12 +503,21 @@ func TestSummary(t *testing.T) {
 			took:    time.Second,
 			want:    "\nSummary:\nloaded 10 metrics in 1.000sec with 0 workers (mean rate 10.00 metrics/sec)\nloaded 1 rows in 1.000sec with 0 workers (mean rate 1.00 rows/sec)\n",
 		},
+		{
+			desc:     "include failures: 10 metrics, 1 rows, 1 second",
+			metrics:  10,
+			rows:     1,
+			failures: targets.WriteFailures{Batches: 1, Metrics: 20, Rows: 2, Retries: 3},
+			took:     time.Second,
+			want:     "\nSummary:\nloaded 10 metrics in 1.000sec with 0 workers (mean rate 10.00 metrics/sec)\nloaded 1 rows in 1.000sec with 0 workers (mean rate 1.00 rows/sec)\nfailed to load 20 metrics (2 rows) in 1 batches, with 3 retries\n",
+		},
 	}
 
 	for _, c := range cases {
 		br := &CommonBenchmarkRunner{}
 		br.metricCnt = c.metrics
 		br.rowCnt = c.rows
+		br.failures = c.failures
 		var b bytes.Buffer
 		printFn = func(s string, args ...interface{}) (n int, err error) {
 			return fmt.Fprintf(&b, s, args...)
@@ -478,4 +576,53 @@ func TestReport(t *testing.T) {
 	if end[len(end)-1:len(end)] == "-" {
 		t.Errorf("TestReport: row report ends in -")
 	}
+
+	// failed batches are logged apart from the CSV lines
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	atomic.StoreUint64(&br.failures.Batches, 1)
+	atomic.StoreUint64(&br.failures.Retries, 2)
+	time.Sleep(duration)
+	if got := atomic.LoadInt64(&counter); got != 5 {
+		t.Errorf("TestReport: counter check incorrect with failures: got %d want %d", got, 5)
+	}
+	m.Lock()
+	if strings.Contains(b.String(), "failed") {
+		t.Errorf("TestReport: failures reported in the CSV lines")
+	}
+	m.Unlock()
+	if !strings.Contains(logged.String(), "in 1 batches") {
+		t.Errorf("TestReport: failed batches not logged: %q", logged.String())
+	}
+}
+
+func TestReportLag(t *testing.T) {
+	var b bytes.Buffer
+	var m sync.Mutex
+	printFn = func(s string, args ...interface{}) (n int, err error) {
+		m.Lock()
+		defer m.Unlock()
+		return fmt.Fprintf(&b, s, args...)
+	}
+	br := &CommonBenchmarkRunner{schedule: newSchedule(10, time.Now())}
+	br.schedule.lag = 1500 * time.Millisecond
+	atomic.StoreUint64(&br.rowCnt, 1)
+	duration := 50 * time.Millisecond
+	go br.report(duration)
+
+	time.Sleep(duration + 25*time.Millisecond)
+	m.Lock()
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	m.Unlock()
+	if len(lines) != 2 {
+		t.Fatalf("TestReportLag: got %d lines want 2: %q", len(lines), lines)
+	}
+	// the lag is a last column of the CSV lines
+	if !strings.HasSuffix(lines[0], ",lag sec") {
+		t.Errorf("TestReportLag: no lag column in the header: %s", lines[0])
+	}
+	if got := strings.Split(lines[1], ","); len(got) != 8 || got[7] != "1.500" {
+		t.Errorf("TestReportLag: incorrect row: %s", lines[1])
+	}
 }
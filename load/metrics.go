@@ -0,0 +1,55 @@
+package load
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/timescale/tsbs/internal/metrics"
+)
+
+// loadMetrics are the metrics of the workers served on metrics-listen, the
+// counters are read from the runner. A nil loadMetrics records nothing.
+type loadMetrics struct {
+	latency  *metrics.HistogramVec
+	inFlight *metrics.GaugeVec
+}
+
+// serveMetrics serves the metrics of the load on metrics-listen
+func (l *CommonBenchmarkRunner) serveMetrics() {
+	r := metrics.NewRegistry()
+	r.NewCounterFunc("tsbs_load_metrics_total", "Number of metrics loaded.", func() float64 {
+		return float64(atomic.LoadUint64(&l.metricCnt))
+	})
+	r.NewCounterFunc("tsbs_load_rows_total", "Number of rows loaded.", func() float64 {
+		return float64(atomic.LoadUint64(&l.rowCnt))
+	})
+	r.NewCounterFunc("tsbs_load_failed_batches_total", "Number of batches that failed to load.", func() float64 {
+		return float64(atomic.LoadUint64(&l.failures.Batches))
+	})
+	l.metrics = &loadMetrics{
+		latency:  r.NewHistogramVec("tsbs_load_batch_duration_seconds", "Time taken by a worker to load a batch.", "worker", metrics.LatencyBuckets),
+		inFlight: r.NewGaugeVec("tsbs_load_worker_in_flight_batches", "Number of batches the worker is loading.", "worker"),
+	}
+	if err := metrics.Serve(l.MetricsListen, r); err != nil {
+		fatal("cannot serve the metrics on %s: %v", l.MetricsListen, err)
+	}
+}
+
+// started records a worker starting to load a batch
+func (m *loadMetrics) started(workerNum uint) {
+	if m == nil {
+		return
+	}
+	m.inFlight.Set(strconv.Itoa(int(workerNum)), 1)
+}
+
+// done records a worker done loading a batch that took the given time
+func (m *loadMetrics) done(workerNum uint, took time.Duration) {
+	if m == nil {
+		return
+	}
+	worker := strconv.Itoa(int(workerNum))
+	m.inFlight.Set(worker, 0)
+	m.latency.Observe(worker, took.Seconds())
+}
@@ -10,9 +10,10 @@ import (
 // readDs does no flow control, if the capacity of a channel is reached, scanning stops for all
 // workers. (should only happen if channel-capacity is low and one worker is unreasonable slower than the rest)
 // in that case just set hash-workers to false and use 1 channel for all workers.
+// Full batches are released when they are due on the schedule sched, if any.
 func scanWithoutFlowControl(
 	ds targets.DataSource, indexer targets.PointIndexer, factory targets.BatchFactory, channels []chan targets.Batch,
-	batchSize uint, limit uint64,
+	batchSize uint, limit uint64, sched *schedule,
 ) uint64 {
 	if batchSize == 0 {
 		panic("batch size can't be 0")
@@ -40,6 +41,7 @@ func scanWithoutFlowControl(
 		batches[idx].Append(item)
 
 		if batches[idx].Len() >= batchSize {
+			sched.release(itemsRead)
 			channels[idx] <- batches[idx]
 			batches[idx] = factory.New()
 			//fmt.Printf("itemsRead = %d \n", itemsRead)
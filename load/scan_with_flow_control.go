@@ -2,6 +2,7 @@ package load
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/timescale/tsbs/pkg/targets"
 )
@@ -37,14 +38,47 @@ func sendOrQueueBatch(ch *duplexChannel, count *int, batch targets.Batch, unsent
 	return unsent
 }
 
+// acknowledge handles an acknowledgement from the worker of channel idx,
+// sending it the next batch waiting for it and keeping the checkpoint cp
+// up to date
+func acknowledge(channels []*duplexChannel, idx int, count *int, unsent [][]targets.Batch, cp *checkpointer) {
+	unsent[idx] = ackAndMaybeSend(channels[idx], count, unsent[idx])
+	if err := cp.acknowledged(idx, channels[idx]); err != nil {
+		fatal("cannot write the checkpoint: %v", err)
+	}
+}
+
+// ackUntil receives the acknowledgements of the workers for a duration d,
+// sending them the batches waiting for them
+func ackUntil(channels []*duplexChannel, ackCases []reflect.SelectCase, count *int, unsent [][]targets.Batch, cp *checkpointer, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	cases := append(ackCases[:len(ackCases):len(ackCases)], reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(timer.C),
+	})
+	for {
+		chosen, _, ok := reflect.Select(cases)
+		if chosen == len(ackCases) {
+			return
+		}
+		if ok {
+			acknowledge(channels, chosen, count, unsent, cp)
+		}
+	}
+}
+
 // scanWithFlowControl reads data from the DataSource ds until a limit is reached (if -1, all items are read).
 // Data is then placed into appropriate batches, using the supplied PointIndexer,
 // which are then dispatched to workers (duplexChannel chosen by PointIndexer).
 // Scan does flow control to make sure workers are not left idle for too long
 // and also that the scanning process does not starve them of CPU.
+// Full batches are released when they are due on the schedule sched, if any,
+// and the checkpoint cp, if any, is kept from the acknowledgements.
 func scanWithFlowControl(
 	channels []*duplexChannel, batchSize uint, limit uint64,
-	ds targets.DataSource, factory targets.BatchFactory, indexer targets.PointIndexer,
+	ds targets.DataSource, factory targets.BatchFactory, indexer targets.PointIndexer, sched *schedule,
+	cp *checkpointer,
 ) uint64 {
 	var itemsRead uint64
 	numChannels := len(channels)
@@ -109,7 +143,7 @@ func scanWithFlowControl(
 		// Only receive an 'ok' when it's from a channel, default does not return 'ok'
 		chosen, _, ok := reflect.Select(cases[:caseLimit])
 		if ok {
-			unsentBatches[chosen] = ackAndMaybeSend(channels[chosen], &ocnt, unsentBatches[chosen])
+			acknowledge(channels, chosen, &ocnt, unsentBatches, cp)
 		}
 
 		// Prepare new batch - decode new item and append it to batch
@@ -124,10 +158,18 @@ func scanWithFlowControl(
 		// Append new item to batch
 		idx := indexer.GetIndex(item)
 		fillingBatches[idx].Append(item)
+		cp.appended(int(idx), itemsRead)
 
 		if fillingBatches[idx].Len() >= batchSize {
 			// Batch is full (contains at least batchSize items) - ready to be sent to worker,
 			// or moved to outstanding, in case no workers available atm.
+			// Acknowledge the workers while waiting for the batch to be due,
+			// so they keep processing the batches sent to them
+			for wait := sched.untilDue(itemsRead); wait > 0; wait = sched.untilDue(itemsRead) {
+				ackUntil(channels, cases[:numChannels], &ocnt, unsentBatches, cp, wait)
+			}
+			sched.release(itemsRead)
+			cp.queued(int(idx))
 			unsentBatches[idx] = sendOrQueueBatch(channels[idx], &ocnt, fillingBatches[idx], unsentBatches[idx])
 			// Place new empty batch
 			fillingBatches[idx] = factory.New()
@@ -139,6 +181,7 @@ func scanWithFlowControl(
 	for idx, b := range fillingBatches {
 		// Do not enqueue empty batches (with 0 items)
 		if b.Len() > 0 {
+			cp.queued(idx)
 			unsentBatches[idx] = sendOrQueueBatch(channels[idx], &ocnt, fillingBatches[idx], unsentBatches[idx])
 		}
 	}
@@ -154,9 +197,12 @@ func scanWithFlowControl(
 		// Try to send batches to workers
 		chosen, _, ok := reflect.Select(cases[:len(cases)-1])
 		if ok {
-			unsentBatches[chosen] = ackAndMaybeSend(channels[chosen], &ocnt, unsentBatches[chosen])
+			acknowledge(channels, chosen, &ocnt, unsentBatches, cp)
 		}
 	}
+	if err := cp.write(); err != nil {
+		fatal("cannot write the checkpoint: %v", err)
+	}
 
 	return itemsRead
 }
@@ -0,0 +1,121 @@
+package load
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// schedule releases the batches of an open-loop load at a target rate of
+// items per second from its start, however fast the workers load them, and
+// keeps track of how far behind the schedule the scanner fell. A nil
+// schedule releases the batches as soon as they are full.
+type schedule struct {
+	rate  float64
+	start time.Time
+	sleep func(time.Duration)
+
+	mu       sync.Mutex
+	batches  uint64
+	late     uint64
+	totalLag time.Duration
+	maxLag   time.Duration
+	lag      time.Duration
+}
+
+func newSchedule(rate float64, start time.Time) *schedule {
+	return &schedule{rate: rate, start: start, sleep: time.Sleep}
+}
+
+// due returns when the batch ending with the items-th item is due
+func (s *schedule) due(items uint64) time.Time {
+	return s.start.Add(time.Duration(float64(items) / s.rate * float64(time.Second)))
+}
+
+// untilDue returns the time left until the batch ending with the items-th
+// item read is due
+func (s *schedule) untilDue(items uint64) time.Duration {
+	if s == nil {
+		return 0
+	}
+	return time.Until(s.due(items))
+}
+
+// release waits until the batch ending with the items-th item read is due,
+// or records how late it is
+func (s *schedule) release(items uint64) {
+	if s == nil {
+		return
+	}
+	lag := time.Since(s.due(items))
+	if lag < 0 {
+		s.sleep(-lag)
+		lag = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches++
+	s.lag = lag
+	if lag > 0 {
+		s.late++
+		s.totalLag += lag
+		if lag > s.maxLag {
+			s.maxLag = lag
+		}
+	}
+}
+
+// current returns how far behind the schedule the last batch was released
+func (s *schedule) current() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lag
+}
+
+// string describes how far behind the schedule the load fell for the
+// summary
+func (s *schedule) string() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meanLag := time.Duration(0)
+	if s.late > 0 {
+		meanLag = s.totalLag / time.Duration(s.late)
+	}
+	return fmt.Sprintf("target rate %0.2f items/sec: %d of %d batches released behind schedule, mean lag %0.3fsec, max lag %0.3fsec, last lag %0.3fsec",
+		s.rate, s.late, s.batches, meanLag.Seconds(), s.maxLag.Seconds(), s.lag.Seconds())
+}
+
+// totals returns how far behind the schedule the load fell for the results
+// file, the lags in milliseconds
+func (s *schedule) totals() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meanLag := time.Duration(0)
+	if s.late > 0 {
+		meanLag = s.totalLag / time.Duration(s.late)
+	}
+	return map[string]interface{}{
+		"targetRate":  s.rate,
+		"batches":     s.batches,
+		"lateBatches": s.late,
+		"meanLag":     float64(meanLag) / float64(time.Millisecond),
+		"maxLag":      float64(s.maxLag) / float64(time.Millisecond),
+		"lastLag":     float64(s.lag) / float64(time.Millisecond),
+	}
+}
+
+// deadlineDataSource ends the data of a DataSource at a deadline
+type deadlineDataSource struct {
+	targets.DataSource
+	deadline time.Time
+}
+
+func (d *deadlineDataSource) NextItem() data.LoadedPoint {
+	if !time.Now().Before(d.deadline) {
+		return data.LoadedPoint{}
+	}
+	return d.DataSource.NextItem()
+}
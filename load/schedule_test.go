@@ -0,0 +1,85 @@
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+func TestScheduleRelease(t *testing.T) {
+	var none *schedule
+	none.release(10)
+	if got := none.untilDue(10); got != 0 {
+		t.Errorf("nil schedule: got wait %v want 0", got)
+	}
+
+	// 10 items per second, started 2 seconds ago: the 10th item was due
+	// a second ago, the 40th is due in 2 seconds
+	s := newSchedule(10, time.Now().Add(-2*time.Second))
+	var slept time.Duration
+	s.sleep = func(d time.Duration) { slept += d }
+
+	s.release(10)
+	if lag := s.current(); lag < time.Second || lag > 2*time.Second {
+		t.Errorf("late batch: got lag %v want about 1s", lag)
+	}
+	if slept != 0 {
+		t.Errorf("late batch: slept %v", slept)
+	}
+
+	s.release(40)
+	if slept < time.Second || slept > 2*time.Second {
+		t.Errorf("early batch: slept %v want about 2s", slept)
+	}
+	if lag := s.current(); lag != 0 {
+		t.Errorf("early batch: got lag %v want 0", lag)
+	}
+
+	totals := s.totals()
+	if totals["batches"] != uint64(2) || totals["lateBatches"] != uint64(1) {
+		t.Errorf("got %d batches, %d late, want 2 and 1", totals["batches"], totals["lateBatches"])
+	}
+	if maxLag := totals["maxLag"].(float64); maxLag < 1000 || maxLag > 2000 {
+		t.Errorf("got max lag %vms want about 1000ms", maxLag)
+	}
+}
+
+func TestScanWithSchedule(t *testing.T) {
+	testData := make([]byte, 20)
+	br := bufio.NewReader(bytes.NewReader(testData))
+	channels := []*duplexChannel{newDuplexChannel(1)}
+	testDataSource := &testDataSource{called: 0, br: br}
+	go _boringWorker(channels[0])
+
+	// 4 batches of 5 items at 400 items per second
+	start := time.Now()
+	s := newSchedule(400, start)
+	read := scanWithFlowControl(channels, 5, 0, testDataSource, &testFactory{}, &targets.ConstantIndexer{}, s, nil)
+	if read != 20 {
+		t.Errorf("got %d items read want 20", read)
+	}
+	if took := time.Since(start); took < 50*time.Millisecond {
+		t.Errorf("scan took %v, before the last batch was due", took)
+	}
+	if got := s.totals()["batches"]; got != uint64(4) {
+		t.Errorf("got %d batches released on schedule want 4", got)
+	}
+}
+
+func TestDeadlineDataSource(t *testing.T) {
+	testData := []byte{0x01, 0x02}
+	ds := &deadlineDataSource{
+		DataSource: &testDataSource{br: bufio.NewReader(bytes.NewReader(testData))},
+		deadline:   time.Now().Add(time.Hour),
+	}
+	if item := ds.NextItem(); item.Data == nil {
+		t.Errorf("no item read before the deadline")
+	}
+	ds.deadline = time.Now()
+	if item := ds.NextItem(); item.Data != nil {
+		t.Errorf("item read after the deadline: %v", item.Data)
+	}
+}
@@ -0,0 +1,140 @@
+package load
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// warmup is the start of a load, a number of items or a duration, left out
+// of the post warm-up throughput and latency stats. A nil warmup never ends.
+type warmup struct {
+	items    uint64
+	duration time.Duration
+
+	loaded uint64 // items loaded, accessed atomically
+	ended  uint32 // accessed atomically
+	once   sync.Once
+	// when the warm-up ended and what was loaded by then
+	at            time.Time
+	metrics, rows uint64
+	latencies     *batchLatencies
+	timer         *time.Timer
+	counters      func() (metrics, rows uint64)
+}
+
+// parseWarmup parses a warm-up of a number of items, e.g. "100000", or of a
+// duration, e.g. "30s"
+func parseWarmup(s string) (items uint64, duration time.Duration, err error) {
+	if items, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return items, 0, nil
+	}
+	duration, err = time.ParseDuration(s)
+	if err != nil || duration <= 0 {
+		return 0, 0, fmt.Errorf("warmup %q is neither a number of items nor a positive duration", s)
+	}
+	return 0, duration, nil
+}
+
+// newWarmup starts the warm-up of a load started at start, counters reads
+// what was loaded when it ends
+func newWarmup(items uint64, duration time.Duration, start time.Time, counters func() (metrics, rows uint64)) *warmup {
+	w := &warmup{items: items, duration: duration, latencies: newBatchLatencies(), counters: counters}
+	if duration > 0 {
+		w.timer = time.AfterFunc(time.Until(start.Add(duration)), w.end)
+	}
+	return w
+}
+
+// end ends the warm-up
+func (w *warmup) end() {
+	w.once.Do(func() {
+		w.at = time.Now()
+		w.metrics, w.rows = w.counters()
+		atomic.StoreUint32(&w.ended, 1)
+	})
+}
+
+// done tells whether the warm-up ended
+func (w *warmup) done() bool {
+	return w != nil && atomic.LoadUint32(&w.ended) != 0
+}
+
+// batchLoaded records a batch of the given number of items loaded in took,
+// after its counts were added to the counters. The batch that completes
+// the items of the warm-up is the last one of it.
+func (w *warmup) batchLoaded(items uint64, took time.Duration) {
+	if w == nil {
+		return
+	}
+	if w.done() {
+		w.latencies.record(took)
+		return
+	}
+	if w.items > 0 && atomic.AddUint64(&w.loaded, items) >= w.items {
+		w.end()
+	}
+}
+
+// stop stops the warm-up timer at the end of the load
+func (w *warmup) stop() {
+	if w != nil && w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// string describes the warm-up for the summary
+func (w *warmup) string() string {
+	if w.items > 0 {
+		return fmt.Sprintf("%d items", w.items)
+	}
+	return w.duration.String()
+}
+
+// warmupSummary prints the statistics of the load after the warm-up, which
+// ended at end
+func (l *CommonBenchmarkRunner) warmupSummary(end time.Time) {
+	w := l.warmup
+	if !w.done() {
+		printFn("the load ended during the warm-up of %s\n", w.string())
+		return
+	}
+	took := end.Sub(w.at)
+	metrics, rows := l.metricCnt-w.metrics, l.rowCnt-w.rows
+	printFn("after the warm-up of %s:\n", w.string())
+	printFn("loaded %d metrics in %0.3fsec with %d workers (mean rate %0.2f metrics/sec)\n", metrics, took.Seconds(), l.Workers, float64(metrics)/took.Seconds())
+	if rows > 0 {
+		printFn("loaded %d rows in %0.3fsec with %d workers (mean rate %0.2f rows/sec)\n", rows, took.Seconds(), l.Workers, float64(rows)/took.Seconds())
+	}
+	if w.latencies.count() > 0 {
+		printFn("%s\n", w.latencies.string())
+	}
+}
+
+// warmupTotals returns the totals of the load after the warm-up, which
+// ended at end, for the results file
+func (l *CommonBenchmarkRunner) warmupTotals(end time.Time) map[string]interface{} {
+	w := l.warmup
+	totals := map[string]interface{}{"warmup": w.string()}
+	if !w.done() {
+		return totals
+	}
+	took := end.Sub(w.at)
+	metrics, rows := l.metricCnt-w.metrics, l.rowCnt-w.rows
+	totals["durationMillis"] = took.Milliseconds()
+	totals["metrics"] = metrics
+	totals["metricRate"] = float64(metrics) / took.Seconds()
+	if rows > 0 {
+		totals["rows"] = rows
+		totals["rowRate"] = float64(rows) / took.Seconds()
+	}
+	if count := w.latencies.count(); count > 0 {
+		// batch latencies in milliseconds
+		latencies := w.latencies.quantiles()
+		latencies["count"] = float64(count)
+		totals["batchLatencies"] = latencies
+	}
+	return totals
+}
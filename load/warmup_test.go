@@ -0,0 +1,117 @@
+package load
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseWarmup(t *testing.T) {
+	cases := []struct {
+		in       string
+		items    uint64
+		duration time.Duration
+		wantErr  bool
+	}{
+		{in: "100000", items: 100000},
+		{in: "30s", duration: 30 * time.Second},
+		{in: "1m30s", duration: 90 * time.Second},
+		{in: "-1s", wantErr: true},
+		{in: "ten", wantErr: true},
+	}
+	for _, c := range cases {
+		items, duration, err := parseWarmup(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", c.in)
+			}
+			continue
+		}
+		if err != nil || items != c.items || duration != c.duration {
+			t.Errorf("%s: got %d items, %v, error %v want %d items, %v", c.in, items, duration, err, c.items, c.duration)
+		}
+	}
+}
+
+func TestWarmupItems(t *testing.T) {
+	metrics, rows := uint64(0), uint64(0)
+	w := newWarmup(10, 0, time.Now(), func() (uint64, uint64) { return metrics, rows })
+
+	metrics, rows = 60, 6
+	w.batchLoaded(6, time.Millisecond)
+	if w.done() {
+		t.Errorf("warm-up ended after 6 of 10 items")
+	}
+	metrics, rows = 100, 10
+	w.batchLoaded(4, time.Millisecond)
+	if !w.done() {
+		t.Fatalf("warm-up not ended after 10 items")
+	}
+	if w.metrics != 100 || w.rows != 10 {
+		t.Errorf("got %d metrics and %d rows at the end of the warm-up want 100 and 10", w.metrics, w.rows)
+	}
+	if got := w.latencies.count(); got != 0 {
+		t.Errorf("got %d batch latencies of the warm-up want 0", got)
+	}
+	w.batchLoaded(5, 2*time.Millisecond)
+	if got := w.latencies.count(); got != 1 {
+		t.Errorf("got %d batch latencies after the warm-up want 1", got)
+	}
+
+	var nilWarmup *warmup
+	nilWarmup.batchLoaded(1, time.Millisecond)
+	nilWarmup.stop()
+	if nilWarmup.done() {
+		t.Errorf("nil warm-up ended")
+	}
+}
+
+func TestWarmupDuration(t *testing.T) {
+	w := newWarmup(0, 10*time.Millisecond, time.Now(), func() (uint64, uint64) { return 5, 0 })
+	defer w.stop()
+	w.batchLoaded(1000, time.Millisecond)
+	if w.done() {
+		t.Errorf("warm-up ended on items")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !w.done() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !w.done() || w.metrics != 5 {
+		t.Errorf("warm-up not ended after its duration, done %v, metrics %d", w.done(), w.metrics)
+	}
+}
+
+func TestWarmupSummary(t *testing.T) {
+	var b bytes.Buffer
+	printFn = func(s string, args ...interface{}) (n int, err error) {
+		return fmt.Fprintf(&b, s, args...)
+	}
+	br := &CommonBenchmarkRunner{}
+	br.Workers = 2
+	br.metricCnt, br.rowCnt = 30, 3
+	br.warmup = newWarmup(10, 0, time.Now(), func() (uint64, uint64) { return 10, 1 })
+
+	br.warmupSummary(time.Now())
+	if want := "the load ended during the warm-up of 10 items\n"; b.String() != want {
+		t.Errorf("got summary\n%s\nwant\n%s", b.String(), want)
+	}
+	if totals := br.warmupTotals(time.Now()); len(totals) != 1 || totals["warmup"] != "10 items" {
+		t.Errorf("got totals %v of a load ended during the warm-up", totals)
+	}
+
+	b.Reset()
+	br.warmup.batchLoaded(10, time.Millisecond)
+	br.warmupSummary(br.warmup.at.Add(2 * time.Second))
+	want := "after the warm-up of 10 items:\n" +
+		"loaded 20 metrics in 2.000sec with 2 workers (mean rate 10.00 metrics/sec)\n" +
+		"loaded 2 rows in 2.000sec with 2 workers (mean rate 1.00 rows/sec)\n"
+	if b.String() != want {
+		t.Errorf("got summary\n%s\nwant\n%s", b.String(), want)
+	}
+	totals := br.warmupTotals(br.warmup.at.Add(2 * time.Second))
+	if totals["metrics"] != uint64(20) || totals["metricRate"] != 10.0 || totals["rowRate"] != 1.0 {
+		t.Errorf("got totals %v", totals)
+	}
+}
@@ -1,5 +1,8 @@
 package source
 
+// FileDataSourceConfig is the location of the data of a FILE data source: a
+// file, or a comma separated list or glob of files read in order, possibly
+// gzip or zstd compressed. Empty means STDIN.
 type FileDataSourceConfig struct {
 	Location string `yaml:"location"`
 }
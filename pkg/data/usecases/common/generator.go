@@ -130,6 +130,11 @@ func (c *BaseConfig) AddToFlagSet(fs *pflag.FlagSet) {
 }
 
 func (c *BaseConfig) Validate() error {
+	return c.ValidateUseCases(UseCaseChoices)
+}
+
+// ValidateUseCases is Validate for a generator with the given use cases
+func (c *BaseConfig) ValidateUseCases(useCases []string) error {
 	if c.Scale == 0 {
 		return fmt.Errorf(ErrScaleIsZero)
 	}
@@ -142,7 +147,7 @@ func (c *BaseConfig) Validate() error {
 		return fmt.Errorf(errBadFormatFmt, c.Format)
 	}
 
-	if !utils.IsIn(c.Use, UseCaseChoices) {
+	if !utils.IsIn(c.Use, useCases) {
 		return fmt.Errorf(errBadUseFmt, c.Use)
 	}
 
@@ -2,6 +2,7 @@ package query
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,8 +10,10 @@ import (
 	"os"
 	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 	"golang.org/x/time/rate"
 )
@@ -20,24 +23,31 @@ const (
 	labelColdQueries = "cold queries"
 	labelWarmQueries = "warm queries"
 
+	labelTimedOutQueries = "timed out queries"
+	labelTimedOutSuffix  = " (timed out)"
+
 	defaultReadSize = 4 << 20 // 4 MB
 )
 
 // BenchmarkRunnerConfig is the configuration of the benchmark runner.
 type BenchmarkRunnerConfig struct {
-	DBName           string `mapstructure:"db-name"`
-	Limit            uint64 `mapstructure:"max-queries"`
-	LimitRPS         uint64 `mapstructure:"max-rps"`
-	MemProfile       string `mapstructure:"memprofile"`
-	HDRLatenciesFile string `mapstructure:"hdr-latencies"`
-	Workers          uint   `mapstructure:"workers"`
-	PrintResponses   bool   `mapstructure:"print-responses"`
-	Debug            int    `mapstructure:"debug"`
-	FileName         string `mapstructure:"file"`
-	BurnIn           uint64 `mapstructure:"burn-in"`
-	PrintInterval    uint64 `mapstructure:"print-interval"`
-	PrewarmQueries   bool   `mapstructure:"prewarm-queries"`
-	ResultsFile      string `mapstructure:"results-file"`
+	DBName               string        `mapstructure:"db-name"`
+	Limit                uint64        `mapstructure:"max-queries"`
+	LimitRPS             uint64        `mapstructure:"max-rps"`
+	MemProfile           string        `mapstructure:"memprofile"`
+	HDRLatenciesFile     string        `mapstructure:"hdr-latencies"`
+	Workers              uint          `mapstructure:"workers"`
+	PrintResponses       bool          `mapstructure:"print-responses"`
+	Debug                int           `mapstructure:"debug"`
+	FileName             string        `mapstructure:"file"`
+	BurnIn               uint64        `mapstructure:"burn-in"`
+	PrintInterval        uint64        `mapstructure:"print-interval"`
+	PrewarmQueries       bool          `mapstructure:"prewarm-queries"`
+	ResultsFile          string        `mapstructure:"results-file"`
+	ContinueOnError      bool          `mapstructure:"continue-on-error"`
+	MaxConsecutiveErrors uint64        `mapstructure:"max-consecutive-errors"`
+	QueryTimeout         time.Duration `mapstructure:"query-timeout"`
+	MetricsListen        string        `mapstructure:"metrics-listen"`
 }
 
 // AddToFlagSet adds command line flags needed by the BenchmarkRunnerConfig to the flag set.
@@ -55,6 +65,10 @@ func (c BenchmarkRunnerConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	fs.Int("debug", 0, "Whether to print debug messages.")
 	fs.String("file", "/home/humanfy/tmp_query", "File name to read queries from")
 	fs.String("results-file", "", "Write the test results summary json to this file")
+	fs.Bool("continue-on-error", false, "Count the failed queries by label and error class instead of stopping at the first one")
+	fs.Uint64("max-consecutive-errors", 0, "With continue-on-error, abort the run after this many queries failed in a row, 0 = no limit")
+	fs.Duration("query-timeout", 0, "Cancel the queries running for longer than this and count them as timed out, 0 = no timeout. A database that can not interrupt a statement, e.g. IGinX, keeps executing it")
+	fs.String("metrics-listen", "", "Serve the metrics of the queries on /metrics at this address (e.g. ':9090'), in the Prometheus format")
 }
 
 // BenchmarkRunner contains the common components for running a query benchmarking
@@ -65,6 +79,13 @@ type BenchmarkRunner struct {
 	sp      statProcessor
 	scanner *scanner
 	ch      chan Query
+
+	// consecutiveErrors is the number of queries that failed in a row
+	consecutiveErrors uint64
+	// aborted is set once max-consecutive-errors is reached, the remaining
+	// queries are skipped
+	aborted uint32
+	metrics *queryMetrics
 }
 
 // NewBenchmarkRunner creates a new instance of BenchmarkRunner which is
@@ -104,6 +125,20 @@ func (b *BenchmarkRunner) DatabaseName() string {
 	return b.DBName
 }
 
+// ErrorClassifier is implemented by the processors that can tell the class
+// of the errors of their queries, such as network or execution errors. The
+// errors of other processors are classed by the type of their cause.
+type ErrorClassifier interface {
+	ErrorClass(err error) string
+}
+
+// ContextProcessor is implemented by the processors that can cancel a query
+// once its context is done, which query-timeout needs. A query cancelled by
+// the timeout is counted as timed out whatever error it returns.
+type ContextProcessor interface {
+	ProcessQueryContext(ctx context.Context, q Query, isWarm bool) ([]*Stat, error)
+}
+
 // ProcessorCreate is a function that creates a new Processor (called in Run)
 type ProcessorCreate func() Processor
 
@@ -147,6 +182,9 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 		panic("burn-in is larger than limit")
 	}
 	b.ch = make(chan Query, b.Workers)
+	if b.MetricsListen != "" {
+		b.serveMetrics()
+	}
 
 	// Launch the stats processor:
 	go b.sp.process(b.Workers)
@@ -192,6 +230,10 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 	if len(b.BenchmarkRunnerConfig.ResultsFile) > 0 {
 		b.saveTestResult(wallTook, wallStart, wallEnd)
 	}
+
+	if atomic.LoadUint32(&b.aborted) != 0 {
+		log.Fatalf("run aborted after %d consecutive failed queries", b.MaxConsecutiveErrors)
+	}
 }
 
 func (b *BenchmarkRunner) saveTestResult(took time.Duration, start time.Time, end time.Time) {
@@ -218,33 +260,101 @@ func (b *BenchmarkRunner) saveTestResult(took time.Duration, start time.Time, en
 
 func (b *BenchmarkRunner) processorHandler(wg *sync.WaitGroup, rateLimiter *rate.Limiter, queryPool *sync.Pool, processor Processor, workerNum int) {
 	processor.Init(workerNum)
+	if _, ok := processor.(ContextProcessor); b.QueryTimeout > 0 && !ok && workerNum == 0 {
+		log.Printf("warning: the queries of this database can not be cancelled, query-timeout is ignored\n")
+	}
 	for query := range b.ch {
+		// once aborted, the remaining queries are only drained
+		if atomic.LoadUint32(&b.aborted) != 0 {
+			queryPool.Put(query)
+			continue
+		}
 		r := rateLimiter.Reserve()
 		time.Sleep(r.Delay())
 
-		stats, err := processor.ProcessQuery(query, false)
-		if err != nil {
-			panic(err)
-		}
-		b.sp.send(stats)
-
 		// If PrewarmQueries is set, we run the query as 'cold' first (see above),
 		// then we immediately run it a second time and report that as the 'warm' stat.
 		// This guarantees that the warm stat will reflect optimal cache performance.
 		spArgs := b.sp.getArgs()
-		if spArgs.prewarmQueries {
+		if b.runQuery(processor, query, false, workerNum) && spArgs.prewarmQueries {
 			// Warm run
-			stats, err = processor.ProcessQuery(query, true)
-			if err != nil {
-				panic(err)
-			}
-			b.sp.sendWarm(stats)
+			b.runQuery(processor, query, true, workerNum)
 		}
 		queryPool.Put(query)
 	}
 	wg.Done()
 }
 
+// runQuery runs a query on the processor and sends its stats, returning
+// whether it succeeded. With query-timeout, a query cancelled by the timeout
+// is sent as timed out, with the time it ran for.
+func (b *BenchmarkRunner) runQuery(processor Processor, query Query, isWarm bool, workerNum int) bool {
+	var stats []*Stat
+	var err error
+	b.metrics.started(workerNum)
+	if cp, ok := processor.(ContextProcessor); ok && b.QueryTimeout > 0 {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), b.QueryTimeout)
+		stats, err = cp.ProcessQueryContext(ctx, query, isWarm)
+		timedOut := err != nil && ctx.Err() == context.DeadlineExceeded
+		cancel()
+		if timedOut {
+			b.metrics.done(workerNum, string(query.HumanLabelName()), nil, err, true)
+			took := float64(time.Since(start).Nanoseconds()) / 1e6 // milliseconds
+			b.sendStats([]*Stat{GetTimeoutStat(query.HumanLabelName(), took)}, isWarm)
+			return false
+		}
+	} else {
+		stats, err = processor.ProcessQuery(query, isWarm)
+	}
+	b.metrics.done(workerNum, string(query.HumanLabelName()), stats, err, false)
+	if err != nil {
+		b.handleError(processor, query, err, isWarm)
+		return false
+	}
+	b.querySucceeded()
+	b.sendStats(stats, isWarm)
+	return true
+}
+
+// sendStats sends the stats of a cold or warm query to the stat processor
+func (b *BenchmarkRunner) sendStats(stats []*Stat, isWarm bool) {
+	if isWarm {
+		b.sp.sendWarm(stats)
+	} else {
+		b.sp.send(stats)
+	}
+}
+
+// querySucceeded resets the count of queries that failed in a row
+func (b *BenchmarkRunner) querySucceeded() {
+	if atomic.LoadUint64(&b.consecutiveErrors) != 0 {
+		atomic.StoreUint64(&b.consecutiveErrors, 0)
+	}
+}
+
+// handleError counts the failed query by label and error class with
+// continue-on-error, and aborts the run once max-consecutive-errors queries
+// failed in a row. Without continue-on-error the first error panics.
+func (b *BenchmarkRunner) handleError(processor Processor, query Query, err error, isWarm bool) {
+	if !b.ContinueOnError {
+		panic(err)
+	}
+	b.sendStats([]*Stat{GetErrorStat(query.HumanLabelName(), errorClass(processor, err))}, isWarm)
+	n := atomic.AddUint64(&b.consecutiveErrors, 1)
+	if b.MaxConsecutiveErrors > 0 && n >= b.MaxConsecutiveErrors && atomic.CompareAndSwapUint32(&b.aborted, 0, 1) {
+		log.Printf("aborting the run after %d consecutive failed queries, last error: %v\n", n, err)
+	}
+}
+
+// errorClass returns the class of an error of the processor
+func errorClass(processor Processor, err error) string {
+	if c, ok := processor.(ErrorClassifier); ok {
+		return c.ErrorClass(err)
+	}
+	return fmt.Sprintf("%T", errors.Cause(err))
+}
+
 func getRateLimiter(limitRPS uint64, workers uint) *rate.Limiter {
 	var requestRate = rate.Inf
 	var requestBurst = 0
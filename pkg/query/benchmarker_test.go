@@ -1,6 +1,8 @@
 package query
 
 import (
+	"context"
+	"errors"
 	"golang.org/x/time/rate"
 	"io/ioutil"
 	"math"
@@ -9,6 +11,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 type testProcessor struct {
@@ -104,6 +107,139 @@ func TestProcessorHandlerPreWarm(t *testing.T) {
 		t.Errorf("total queries wrong: want %d got %d", 2*qLimit, p1.count+p2.count)
 	}
 }
+
+// failingProcessor fails the queries for which fail returns true
+type failingProcessor struct {
+	fail  func(n int) bool
+	count int
+}
+
+func (p *failingProcessor) Init(_ int) {}
+
+func (p *failingProcessor) ProcessQuery(q Query, _ bool) ([]*Stat, error) {
+	p.count++
+	if p.fail(p.count) {
+		return nil, errors.New("query failed")
+	}
+	return []*Stat{GetStat().Init(q.HumanLabelName(), 1)}, nil
+}
+
+// runFailingProcessor runs qLimit queries on a failing processor, returning
+// the stats sent to the stat processor
+func runFailingProcessor(b *BenchmarkRunner, p Processor, qLimit int) []*Stat {
+	var sent []*Stat
+	b.sp = &mockStatProcessor{
+		args:   &statProcessorArgs{limit: &b.Limit},
+		onSend: func(stats []*Stat) { sent = append(sent, stats...) },
+	}
+	b.ch = make(chan Query, qLimit)
+	for i := 0; i < qLimit; i++ {
+		q := testQueryPool.Get().(*testQuery)
+		q.HumanLabel = []byte("label")
+		b.ch <- q
+	}
+	close(b.ch)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.processorHandler(&wg, rate.NewLimiter(rate.Inf, 0), &testQueryPool, p, 0)
+	return sent
+}
+
+func TestProcessorHandlerContinueOnError(t *testing.T) {
+	b := NewBenchmarkRunner(BenchmarkRunnerConfig{ContinueOnError: true, MaxConsecutiveErrors: 3})
+	// every third query fails
+	p := &failingProcessor{fail: func(n int) bool { return n%3 == 0 }}
+	sent := runFailingProcessor(b, p, 10)
+	if p.count != 10 {
+		t.Errorf("incorrect number of queries run: got %d want 10", p.count)
+	}
+	errs := 0
+	for _, s := range sent {
+		if s.errClass == "" {
+			continue
+		}
+		errs++
+		if string(s.label) != "label" || s.errClass != "*errors.errorString" {
+			t.Errorf("incorrect error stat: label %s class %s", s.label, s.errClass)
+		}
+	}
+	if errs != 3 || len(sent) != 10 {
+		t.Errorf("incorrect stats: got %d errors in %d stats, want 3 in 10", errs, len(sent))
+	}
+	if b.aborted != 0 {
+		t.Errorf("run aborted without consecutive errors")
+	}
+}
+
+func TestProcessorHandlerAbortsAfterConsecutiveErrors(t *testing.T) {
+	b := NewBenchmarkRunner(BenchmarkRunnerConfig{ContinueOnError: true, MaxConsecutiveErrors: 3})
+	// the queries fail from the third one
+	p := &failingProcessor{fail: func(n int) bool { return n >= 3 }}
+	sent := runFailingProcessor(b, p, 10)
+	if p.count != 5 {
+		t.Errorf("incorrect number of queries run: got %d want 5", p.count)
+	}
+	if len(sent) != 5 {
+		t.Errorf("incorrect number of stats: got %d want 5", len(sent))
+	}
+	if b.aborted == 0 {
+		t.Errorf("run not aborted")
+	}
+}
+
+func TestProcessorHandlerPanicsOnError(t *testing.T) {
+	b := NewBenchmarkRunner(BenchmarkRunnerConfig{})
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("the code did not panic")
+		}
+	}()
+	runFailingProcessor(b, &failingProcessor{fail: func(int) bool { return true }}, 1)
+}
+
+// slowProcessor runs the queries for which slow returns true until they are
+// cancelled
+type slowProcessor struct {
+	failingProcessor
+	slow func(n int) bool
+}
+
+func (p *slowProcessor) ProcessQueryContext(ctx context.Context, q Query, isWarm bool) ([]*Stat, error) {
+	if p.slow(p.count + 1) {
+		p.count++
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return p.ProcessQuery(q, isWarm)
+}
+
+func TestProcessorHandlerQueryTimeout(t *testing.T) {
+	timeout := 10 * time.Millisecond
+	b := NewBenchmarkRunner(BenchmarkRunnerConfig{QueryTimeout: timeout})
+	// every other query runs until the timeout, the timeouts are not errors
+	p := &slowProcessor{
+		failingProcessor: failingProcessor{fail: func(int) bool { return false }},
+		slow:             func(n int) bool { return n%2 == 0 },
+	}
+	sent := runFailingProcessor(b, p, 6)
+	if p.count != 6 || len(sent) != 6 {
+		t.Fatalf("incorrect queries: got %d queries and %d stats, want 6", p.count, len(sent))
+	}
+	timeouts := 0
+	for _, s := range sent {
+		if !s.isTimeout {
+			continue
+		}
+		timeouts++
+		if string(s.label) != "label" || s.value < float64(timeout.Milliseconds()) {
+			t.Errorf("incorrect timeout stat: label %s value %f", s.label, s.value)
+		}
+	}
+	if timeouts != 3 {
+		t.Errorf("incorrect number of timed out queries: got %d want 3", timeouts)
+	}
+}
+
 func TestBenchmarkRunnerGetBufferedReaderPanicOnMissingFile(t *testing.T) {
 	dumbFileName := "some-random-file-that-should-not-exist"
 	_, err := os.Stat(dumbFileName)
@@ -5,10 +5,18 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"strings"
 )
 
 const ErrEmptyQueryType = "query type cannot be empty"
 
+// UseCaseIginx is a use case of IGinX-native queries only, which run on the
+// devops data, so it is not a use case of the data generator
+const UseCaseIginx = "iginx"
+
+// UseCaseChoices are the use cases of the query generator
+var UseCaseChoices = append(append([]string(nil), common.UseCaseChoices...), UseCaseIginx)
+
 // QueryGeneratorConfig is the GeneratorConfig that should be used with a
 // QueryGenerator. It includes all the fields from a BaseConfig, as well as
 // options that are specific to generating the queries to test against a
@@ -27,13 +35,16 @@ type QueryGeneratorConfig struct {
 
 	ClickhouseUseTags bool `mapstructure:"clickhouse-use-tags"`
 
+	IginxPathTemplate string `mapstructure:"iginx-path-template"`
+	IginxTagged       bool   `mapstructure:"iginx-tagged"`
+
 	MongoUseNaive bool   `mapstructure:"mongo-use-native"`
 	DbName        string `mapstructure:"db-name"`
 }
 
 // Validate checks that the values of the QueryGeneratorConfig are reasonable.
 func (c *QueryGeneratorConfig) Validate() error {
-	err := c.BaseConfig.Validate()
+	err := c.BaseConfig.ValidateUseCases(UseCaseChoices)
 	if err != nil {
 		return err
 	}
@@ -48,6 +59,8 @@ func (c *QueryGeneratorConfig) Validate() error {
 
 func (c *QueryGeneratorConfig) AddToFlagSet(fs *pflag.FlagSet) {
 	c.BaseConfig.AddToFlagSet(fs)
+	fs.Lookup("use-case").Usage = fmt.Sprintf("Use case to generate the queries of. Choices: %s (%s: IGinX only, on the devops data)",
+		strings.Join(UseCaseChoices, ", "), UseCaseIginx)
 	fs.Uint64("queries", 1000, "Number of queries to generate.")
 	fs.String("query-type", "", "Query type. (Choices are in the use case matrix.)")
 
@@ -57,6 +70,8 @@ func (c *QueryGeneratorConfig) AddToFlagSet(fs *pflag.FlagSet) {
 		"The number of round-robin serialization groups. Use this to scale up data generation to multiple processes.")
 
 	fs.Bool("clickhouse-use-tags", true, "ClickHouse only: Use separate tags table when querying")
+	fs.String("iginx-path-template", "", "IGinX only: Template of the series paths the data was loaded with, empty for the default of the use case")
+	fs.Bool("iginx-tagged", false, "IGinX only: Whether the data was loaded as tagged series, filtering on the tags with WITH clauses")
 	fs.Bool("mongo-use-naive", true, "MongoDB only: Generate queries for the 'naive' data storage format for Mongo")
 	fs.Bool("timescale-use-json", false, "TimescaleDB only: Use separate JSON tags table when querying")
 	fs.Bool("timescale-use-tags", true, "TimescaleDB only: Use separate tags table when querying")
@@ -11,8 +11,11 @@ type Iginx struct {
 	HumanLabel       []byte
 	HumanDescription []byte
 
-	SqlQuery   []byte
-	id         uint64
+	SqlQuery []byte
+	// UDFs are the names of the Python UDFs the query calls, which the
+	// query runner registers if IGinX does not have them
+	UDFs []string
+	id   uint64
 }
 
 // IginxPool is a sync.Pool of Iginx Query types
@@ -62,6 +65,7 @@ func (q *Iginx) Release() {
 	q.HumanDescription = q.HumanDescription[:0]
 	q.id = 0
 	q.SqlQuery = q.SqlQuery[:0]
+	q.UDFs = q.UDFs[:0]
 
 	IginxPool.Put(q)
 }
@@ -0,0 +1,62 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/timescale/tsbs/internal/metrics"
+)
+
+// queryMetrics are the metrics of the queries served on metrics-listen. A
+// nil queryMetrics records nothing.
+type queryMetrics struct {
+	queries  *metrics.CounterVec
+	errors   *metrics.CounterVec
+	timeouts *metrics.CounterVec
+	latency  *metrics.HistogramVec
+	inFlight *metrics.GaugeVec
+}
+
+// serveMetrics serves the metrics of the queries on metrics-listen
+func (b *BenchmarkRunner) serveMetrics() {
+	r := metrics.NewRegistry()
+	b.metrics = &queryMetrics{
+		queries:  r.NewCounterVec("tsbs_queries_total", "Number of queries run, by label.", "label"),
+		errors:   r.NewCounterVec("tsbs_query_errors_total", "Number of queries that failed, by label.", "label"),
+		timeouts: r.NewCounterVec("tsbs_query_timeouts_total", "Number of queries cancelled by the query timeout, by label.", "label"),
+		latency:  r.NewHistogramVec("tsbs_query_duration_seconds", "Latency of the queries, and of their parts, by label.", "label", metrics.LatencyBuckets),
+		inFlight: r.NewGaugeVec("tsbs_query_worker_in_flight_queries", "Number of queries the worker is running.", "worker"),
+	}
+	if err := metrics.Serve(b.MetricsListen, r); err != nil {
+		panic(fmt.Sprintf("cannot serve the metrics on %s: %v", b.MetricsListen, err))
+	}
+}
+
+// started records a worker starting to run a query
+func (m *queryMetrics) started(workerNum int) {
+	if m == nil {
+		return
+	}
+	m.inFlight.Set(strconv.Itoa(workerNum), 1)
+}
+
+// done records a worker done running a query of the given label, with the
+// latency stats of the query if it succeeded
+func (m *queryMetrics) done(workerNum int, label string, stats []*Stat, err error, timedOut bool) {
+	if m == nil {
+		return
+	}
+	m.inFlight.Set(strconv.Itoa(workerNum), 0)
+	m.queries.Inc(label)
+	switch {
+	case timedOut:
+		m.timeouts.Inc(label)
+	case err != nil:
+		m.errors.Inc(label)
+	}
+	for _, s := range stats {
+		if s.unit == "" {
+			m.latency.Observe(string(s.label), s.value/1e3)
+		}
+	}
+}
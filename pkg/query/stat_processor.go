@@ -5,10 +5,12 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/HdrHistogram/hdrhistogram-go"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"regexp"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -42,6 +44,11 @@ type defaultStatProcessor struct {
 	startTime   time.Time
 	endTime     time.Time
 	statMapping map[string]*statGroup
+	// countMapping collects the partial stats that are counts of a unit,
+	// e.g. the rows of the responses, by label, apart from the latencies
+	countMapping map[string]*statGroup
+	// errors counts the failed queries by label and error class
+	errors map[string]errorCounts
 }
 
 func newStatProcessor(args *statProcessorArgs) statProcessor {
@@ -91,6 +98,9 @@ func (sp *defaultStatProcessor) process(workers uint) {
 		sp.statMapping[labelWarmQueries] = newStatGroup(*sp.args.limit)
 	}
 
+	sp.countMapping = map[string]*statGroup{}
+	sp.errors = map[string]errorCounts{}
+
 	i := uint64(0)
 	sp.startTime = time.Now()
 	prevTime := sp.startTime
@@ -98,6 +108,19 @@ func (sp *defaultStatProcessor) process(workers uint) {
 
 	for stat := range sp.c {
 		atomic.AddUint64(&sp.opsCount, 1)
+		// failed queries have no latency, they are only counted
+		if stat.errClass != "" {
+			sp.countError(stat)
+			statPool.Put(stat)
+			continue
+		}
+		// timed out queries have their own latencies, apart from the ones
+		// of the queries that completed
+		if stat.isTimeout {
+			sp.pushTimeout(stat)
+			statPool.Put(stat)
+			continue
+		}
 		if i < sp.args.burnIn {
 			i++
 			statPool.Put(stat)
@@ -108,6 +131,11 @@ func (sp *defaultStatProcessor) process(workers uint) {
 				log.Fatal(err)
 			}
 		}
+		if stat.unit != "" {
+			sp.pushCount(stat)
+			statPool.Put(stat)
+			continue
+		}
 		if _, ok := sp.statMapping[string(stat.label)]; !ok {
 			sp.statMapping[string(stat.label)] = newStatGroup(*sp.args.limit)
 		}
@@ -152,10 +180,7 @@ func (sp *defaultStatProcessor) process(workers uint) {
 			if err != nil {
 				log.Fatal(err)
 			}
-			err = writeStatGroupMap(os.Stderr, sp.statMapping)
-			if err != nil {
-				log.Fatal(err)
-			}
+			sp.writeStats(os.Stderr)
 			_, err = fmt.Fprintf(os.Stderr, "\n")
 			if err != nil {
 				log.Fatal(err)
@@ -171,10 +196,7 @@ func (sp *defaultStatProcessor) process(workers uint) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = writeStatGroupMap(os.Stdout, sp.statMapping)
-	if err != nil {
-		log.Fatal(err)
-	}
+	sp.writeStats(os.Stdout)
 
 	if len(sp.args.hdrLatenciesFile) > 0 {
 		_, _ = fmt.Printf("Saving High Dynamic Range (HDR) Histogram of Response Latencies to %s\n", sp.args.hdrLatenciesFile)
@@ -194,6 +216,60 @@ func (sp *defaultStatProcessor) process(workers uint) {
 	sp.wg.Done()
 }
 
+// writeStats writes the latencies, the counts and the errors of the queries
+func (sp *defaultStatProcessor) writeStats(w io.Writer) {
+	err := writeStatGroupMap(w, sp.statMapping)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(sp.countMapping) > 0 {
+		if _, err = fmt.Fprintln(w, "Counts:"); err != nil {
+			log.Fatal(err)
+		}
+		err = writeStatGroupMap(w, sp.countMapping)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(sp.errors) > 0 {
+		err = writeErrorCountsMap(w, sp.errors)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// pushCount pushes a partial stat that is a count of a unit to the counts
+// of its label
+func (sp *defaultStatProcessor) pushCount(stat *Stat) {
+	if _, ok := sp.countMapping[string(stat.label)]; !ok {
+		sp.countMapping[string(stat.label)] = newStatGroup(*sp.args.limit)
+		sp.countMapping[string(stat.label)].unit = stat.unit
+	}
+	sp.countMapping[string(stat.label)].push(stat.value)
+}
+
+// countError counts a failed query under its label and all queries
+func (sp *defaultStatProcessor) countError(stat *Stat) {
+	for _, label := range []string{string(stat.label), labelAllQueries} {
+		if _, ok := sp.errors[label]; !ok {
+			sp.errors[label] = errorCounts{}
+		}
+		sp.errors[label][stat.errClass]++
+	}
+}
+
+// pushTimeout pushes the latency of a timed out query to the timed out
+// groups of its label and of all queries
+func (sp *defaultStatProcessor) pushTimeout(stat *Stat) {
+	for _, label := range []string{string(stat.label) + labelTimedOutSuffix, labelTimedOutQueries} {
+		if _, ok := sp.statMapping[label]; !ok {
+			sp.statMapping[label] = newStatGroup(*sp.args.limit)
+		}
+		sp.statMapping[label].push(stat.value)
+	}
+}
+
 func generateQuantileMap(hist *hdrhistogram.Histogram) (int64, map[string]float64) {
 	ops := hist.TotalCount()
 	q0 := 0.0
@@ -238,6 +314,32 @@ func (sp *defaultStatProcessor) GetTotalsMap() map[string]interface{} {
 		quantiles[stripRegex(label)] = all
 	}
 	totals["overallQuantiles"] = quantiles
+	// counts of a unit by label, e.g. the rows of the responses
+	counts := make(map[string]interface{})
+	for label, statGroup := range sp.countMapping {
+		_, all := generateQuantileMap(statGroup.latencyHDRHistogram)
+		counts[stripRegex(label)] = map[string]interface{}{
+			"unit":      statGroup.unit,
+			"quantiles": all,
+			"sum":       statGroup.sum,
+			"count":     statGroup.count,
+		}
+	}
+	totals["overallCounts"] = counts
+	// failed queries by label and error class
+	errors := make(map[string]interface{})
+	for label, counts := range sp.errors {
+		errors[stripRegex(label)] = counts
+	}
+	totals["errors"] = errors
+	// timed out queries by label
+	timeouts := make(map[string]interface{})
+	for label, statGroup := range sp.statMapping {
+		if strings.HasSuffix(label, labelTimedOutSuffix) {
+			timeouts[stripRegex(strings.TrimSuffix(label, labelTimedOutSuffix))] = statGroup.count
+		}
+	}
+	totals["timeouts"] = timeouts
 	return totals
 }
 
@@ -1,6 +1,9 @@
 package query
 
 import (
+	"bytes"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -77,3 +80,89 @@ func TestStatProcessorSendWarm(t *testing.T) {
 		t.Errorf("empty stat array changed channel length: got %d want %d", got, wantLen)
 	}
 }
+
+func TestStatProcessorCountsErrors(t *testing.T) {
+	limit := uint64(0)
+	sp := newStatProcessor(&statProcessorArgs{limit: &limit}).(*defaultStatProcessor)
+	sp.statMapping = map[string]*statGroup{labelAllQueries: newStatGroup(limit)}
+	sp.errors = map[string]errorCounts{}
+	for _, s := range []*Stat{
+		GetErrorStat([]byte("label 1"), "network"),
+		GetErrorStat([]byte("label 2"), "network"),
+		GetErrorStat([]byte("label 2"), "execution"),
+	} {
+		sp.countError(s)
+	}
+
+	want := map[string]interface{}{
+		"label_1":     errorCounts{"network": 1},
+		"label_2":     errorCounts{"network": 1, "execution": 1},
+		"all_queries": errorCounts{"network": 2, "execution": 1},
+	}
+	if got := sp.GetTotalsMap()["errors"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect errors: got %v want %v", got, want)
+	}
+	if got, want := sp.errors[labelAllQueries].string(), "3 (execution: 1, network: 2)"; got != want {
+		t.Errorf("incorrect description: got %s want %s", got, want)
+	}
+}
+
+func TestStatProcessorPushesTimeouts(t *testing.T) {
+	limit := uint64(0)
+	sp := newStatProcessor(&statProcessorArgs{limit: &limit}).(*defaultStatProcessor)
+	sp.statMapping = map[string]*statGroup{labelAllQueries: newStatGroup(limit)}
+	sp.errors = map[string]errorCounts{}
+	for _, s := range []*Stat{
+		GetTimeoutStat([]byte("label 1"), 1000),
+		GetTimeoutStat([]byte("label 2"), 1000),
+		GetTimeoutStat([]byte("label 2"), 2000),
+	} {
+		sp.pushTimeout(s)
+	}
+
+	if got := sp.statMapping[labelAllQueries].count; got != 0 {
+		t.Errorf("timed out queries counted in all queries: got %d", got)
+	}
+	if got := sp.statMapping[labelTimedOutQueries].count; got != 3 {
+		t.Errorf("incorrect timed out queries: got %d want 3", got)
+	}
+	if got := sp.statMapping["label 2"+labelTimedOutSuffix].sum; got != 3000 {
+		t.Errorf("incorrect sum of label 2 timeouts: got %f want 3000", got)
+	}
+	want := map[string]interface{}{"label_1": int64(1), "label_2": int64(2)}
+	if got := sp.GetTotalsMap()["timeouts"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect timeouts: got %v want %v", got, want)
+	}
+}
+
+func TestStatProcessorPushesCounts(t *testing.T) {
+	limit := uint64(0)
+	sp := newStatProcessor(&statProcessorArgs{limit: &limit}).(*defaultStatProcessor)
+	sp.statMapping = map[string]*statGroup{labelAllQueries: newStatGroup(limit)}
+	sp.countMapping = map[string]*statGroup{}
+	sp.errors = map[string]errorCounts{}
+	for _, s := range []*Stat{
+		GetPartialCountStat("rows").Init([]byte("label-rows"), 10),
+		GetPartialCountStat("rows").Init([]byte("label-rows"), 30),
+	} {
+		sp.pushCount(s)
+	}
+
+	totals := sp.GetTotalsMap()
+	if _, ok := totals["overallQuantiles"].(map[string]interface{})["label_rows"]; ok {
+		t.Errorf("counts reported with the latency quantiles")
+	}
+	counts, ok := totals["overallCounts"].(map[string]interface{})["label_rows"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("counts missing from the totals: %v", totals["overallCounts"])
+	}
+	if counts["unit"] != "rows" || counts["sum"] != 40.0 || counts["count"] != int64(2) {
+		t.Errorf("incorrect counts: got %v", counts)
+	}
+
+	var b bytes.Buffer
+	sp.writeStats(&b)
+	if got := b.String(); !strings.Contains(got, "Counts:\nlabel-rows:\nmin:    10.00 rows") {
+		t.Errorf("counts not written in their own section with their unit:\n%s", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/HdrHistogram/hdrhistogram-go"
@@ -20,6 +21,15 @@ type Stat struct {
 	value     float64
 	isWarm    bool
 	isPartial bool
+	// errClass is the class of the error of a failed query, which has no
+	// latency, empty if the query succeeded
+	errClass string
+	// isTimeout is set for a query cancelled by the query timeout, its value
+	// is the time it ran for
+	isTimeout bool
+	// unit is the unit of the value of a partial stat which is not a latency,
+	// such as rows, empty for a latency in milliseconds
+	unit string
 }
 
 var statPool = &sync.Pool{
@@ -43,6 +53,30 @@ func GetPartialStat() *Stat {
 	return s
 }
 
+// GetPartialCountStat returns a partial Stat for use from a pool whose value
+// is a count of the given unit, e.g. rows, rather than a latency
+func GetPartialCountStat(unit string) *Stat {
+	s := GetPartialStat()
+	s.unit = unit
+	return s
+}
+
+// GetErrorStat returns a Stat for use from a pool recording a failed query
+// of the given label, with the class of its error
+func GetErrorStat(label []byte, errClass string) *Stat {
+	s := GetStat().Init(label, 0)
+	s.errClass = errClass
+	return s
+}
+
+// GetTimeoutStat returns a Stat for use from a pool recording a query of the
+// given label cancelled by the query timeout after running for value ms
+func GetTimeoutStat(label []byte, value float64) *Stat {
+	s := GetStat().Init(label, value)
+	s.isTimeout = true
+	return s
+}
+
 // Init safely initializes a Stat while minimizing heap allocations.
 func (s *Stat) Init(label []byte, value float64) *Stat {
 	s.label = s.label[:0] // clear
@@ -57,6 +91,9 @@ func (s *Stat) reset() *Stat {
 	s.value = 0.0
 	s.isWarm = false
 	s.isPartial = false
+	s.errClass = ""
+	s.isTimeout = false
+	s.unit = ""
 	return s
 }
 
@@ -65,6 +102,8 @@ type statGroup struct {
 	latencyHDRHistogram *hdrhistogram.Histogram
 	sum                 float64
 	count               int64
+	// unit is the unit of the counts the group collects, empty for latencies
+	unit string
 }
 
 // newStatGroup returns a new StatGroup with an initial size
@@ -92,6 +131,16 @@ func (s *statGroup) push(n float64) {
 
 // string makes a simple description of a statGroup.
 func (s *statGroup) string() string {
+	if s.unit != "" {
+		return fmt.Sprintf("min: %8.2f %s, med: %8.2f %s, mean: %8.2f %s, max: %8.2f %s, stddev: %8.2f %s, sum: %.1f %s, count: %d",
+			s.Min(), s.unit,
+			s.Median(), s.unit,
+			s.Mean(), s.unit,
+			s.Max(), s.unit,
+			s.StdDev(), s.unit,
+			s.sum, s.unit,
+			s.count)
+	}
 	return fmt.Sprintf("min: %8.2fms, med: %8.2fms, mean: %8.2fms, max: %7.2fms, stddev: %8.2fms, sum: %5.1fsec, count: %d",
 		s.Min(),
 		s.Median(),
@@ -163,3 +212,56 @@ func writeStatGroupMap(w io.Writer, statGroups map[string]*statGroup) error {
 	}
 	return nil
 }
+
+// errorCounts counts the failed queries of a label by error class
+type errorCounts map[string]uint64
+
+func (e errorCounts) total() uint64 {
+	total := uint64(0)
+	for _, n := range e {
+		total += n
+	}
+	return total
+}
+
+// string describes the counts, e.g. "3 (execution: 1, network: 2)"
+func (e errorCounts) string() string {
+	classes := make([]string, 0, len(e))
+	for class := range e {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for i, class := range classes {
+		classes[i] = fmt.Sprintf("%s: %d", class, e[class])
+	}
+	return fmt.Sprintf("%d (%s)", e.total(), strings.Join(classes, ", "))
+}
+
+// writeErrorCountsMap writes the error counts of each label ordered by
+// label, like writeStatGroupMap
+func writeErrorCountsMap(w io.Writer, errors map[string]errorCounts) error {
+	maxKeyLength := 0
+	keys := make([]string, 0, len(errors))
+	for k := range errors {
+		if len(k) > maxKeyLength {
+			maxKeyLength = len(k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if _, err := fmt.Fprintln(w, "Errors:"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		paddedKey := k
+		for len(paddedKey) < maxKeyLength {
+			paddedKey += " "
+		}
+
+		_, err := fmt.Fprintf(w, "%s: %s\n", paddedKey, errors[k].string())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -25,6 +25,16 @@ func TestGetPartialStat(t *testing.T) {
 	}
 }
 
+func TestGetPartialCountStat(t *testing.T) {
+	s := GetPartialCountStat("rows").Init([]byte("foo"), 42)
+	if !s.isPartial || s.unit != "rows" {
+		t.Errorf("GetPartialCountStat() failed - isPartial = %v, unit = %s", s.isPartial, s.unit)
+	}
+	if s.reset(); s.unit != "" {
+		t.Errorf("reset() failed - unit is not empty")
+	}
+}
+
 func TestStatInit(t *testing.T) {
 	s := GetStat()
 	s.Init([]byte("foo"), 11.0)
@@ -281,6 +291,17 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestStatGroupStringUnit(t *testing.T) {
+	sg := newStatGroup(0)
+	sg.unit = "rows"
+	sg.push(10)
+	sg.push(30)
+	want := "min:    10.00 rows, med:    10.00 rows, mean:    20.00 rows, max:    30.00 rows, stddev:    10.00 rows, sum: 40.0 rows, count: 2"
+	if got := sg.string(); got != want {
+		t.Errorf("incorrect description:\ngot  %s\nwant %s", got, want)
+	}
+}
+
 func TestWriteStatGroupMap(t *testing.T) {
 	cases := []struct {
 		desc           string
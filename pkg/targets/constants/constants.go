@@ -15,6 +15,7 @@ const (
 	FormatTimestream      = "timestream"
 	FormatQuestDB         = "questdb"
 	FormatIginx           = "iginx"
+	FormatIginxBinary     = "iginx-binary"
 )
 
 func SupportedFormats() []string {
@@ -32,5 +33,6 @@ func SupportedFormats() []string {
 		FormatTimestream,
 		FormatQuestDB,
 		FormatIginx,
+		FormatIginxBinary,
 	}
 }
@@ -0,0 +1,91 @@
+package iginx
+
+import (
+	"bytes"
+	"log"
+	"sync"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+const errNotThreeTuplesFmt = "parse error: line does not have 3 tuples, has %d"
+
+var (
+	spaceSep = []byte(" ")
+	commaSep = []byte(",")
+	newLine  = []byte("\n")
+)
+
+// allows for testing
+var fatal = log.Fatalf
+
+type batch struct {
+	buf     *bytes.Buffer
+	rows    uint
+	metrics uint64
+}
+
+func (b *batch) Len() uint {
+	return b.rows
+}
+
+func (b *batch) Append(item data.LoadedPoint) {
+	that := item.Data.([]byte)
+	b.rows++
+
+	// Each line is format "csv-tags csv-fields timestamp", so we check there
+	// are exactly two spaces and then count the commas of the middle element
+	// to find out the number of fields added
+	if bytes.IndexByte(that, '"') >= 0 {
+		// string values may contain separators, take the slow path
+		args := splitUnquoted(string(that), ' ')
+		if len(args) != 3 {
+			fatal(errNotThreeTuplesFmt, len(args))
+			return
+		}
+		b.metrics += uint64(len(splitUnquoted(args[1], ',')))
+	} else {
+		if args := bytes.Count(that, spaceSep); args != 2 {
+			fatal(errNotThreeTuplesFmt, args+1)
+			return
+		}
+		fieldsPos := bytes.Index(that, spaceSep)
+		timestampPos := bytes.Index(that[fieldsPos+1:], spaceSep) + fieldsPos + 1
+		fields := that[fieldsPos+1 : timestampPos]
+		b.metrics += uint64(bytes.Count(fields, commaSep) + 1)
+	}
+
+	b.buf.Write(that)
+	b.buf.Write(newLine)
+}
+
+type factory struct {
+	bufPool *sync.Pool
+}
+
+func (f *factory) New() targets.Batch {
+	return &batch{buf: f.bufPool.Get().(*bytes.Buffer)}
+}
+
+// columns parses the buffered lines into a columnBatch, with the series
+// built by devices
+func (b *batch) columns(devices *deviceCache) *columnBatch {
+	columns := newColumnBatch()
+	lines := bytes.Split(b.buf.Bytes(), newLine)
+	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		r, err := parseLine(string(line), devices)
+		if err != nil {
+			fatal("cannot parse line %s: %v", line, err)
+			return columns
+		}
+		columns.appendRecord(r)
+	}
+	// keep the counts of the buffered lines, the same as Append computed
+	columns.rows = b.rows
+	columns.metrics = b.metrics
+	return columns
+}
@@ -0,0 +1,222 @@
+package iginx
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blagojts/viper"
+	"github.com/timescale/tsbs/internal/inputs"
+	"github.com/timescale/tsbs/load"
+	"github.com/timescale/tsbs/pkg/data/source"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/iginx/endpoints"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+// SpecificConfig holds the IGinX specific loading options
+type SpecificConfig struct {
+	ConnStr      string `yaml:"connStr" mapstructure:"connStr"`
+	PathTemplate string `yaml:"path-template" mapstructure:"path-template"`
+	Tagged       bool   `yaml:"tagged" mapstructure:"tagged"`
+	// MaxRetries is the number of times a write failing with a retriable
+	// error is retried, waiting an exponential backoff from RetryBackoff up
+	// to RetryMaxBackoff between attempts
+	MaxRetries      int           `yaml:"max-retries" mapstructure:"max-retries"`
+	RetryBackoff    time.Duration `yaml:"retry-backoff" mapstructure:"retry-backoff"`
+	RetryMaxBackoff time.Duration `yaml:"retry-max-backoff" mapstructure:"retry-max-backoff"`
+	// ClearData removes all the data of IGinX before loading instead of
+	// only the series of the benchmark
+	ClearData bool `yaml:"clear-data" mapstructure:"clear-data"`
+	// SetupConfig is the file listing the storage engines and UDFs to
+	// register before loading, see SetupConfig
+	SetupConfig string `yaml:"setup-config" mapstructure:"setup-config"`
+}
+
+func parseSpecificConfig(v *viper.Viper) (*SpecificConfig, error) {
+	var conf SpecificConfig
+	if err := v.Unmarshal(&conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// ConnectionSocketList splits the configured connection string into
+// its ip:port endpoints
+func (c *SpecificConfig) ConnectionSocketList() []string {
+	var sockets []string
+	for _, s := range strings.Split(c.ConnStr, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			sockets = append(sockets, s)
+		}
+	}
+	return sockets
+}
+
+// pathTemplate parses the configured path template and validates it against
+// the headers of the data. If no template is configured, the default one is
+// derived from the headers, or keeps all the tags as IGinX tags in tagged
+// mode.
+func (c *SpecificConfig) pathTemplate(headers *common.GeneratedDataHeaders) (*paths.Template, error) {
+	raw := c.PathTemplate
+	parse := paths.Parse
+	if c.Tagged {
+		parse = paths.ParseTagged
+	}
+	if raw == "" && c.Tagged {
+		raw = paths.TaggedDefault
+	} else if raw == "" {
+		if headers == nil {
+			return nil, errors.New("the data has no headers to derive the path template from, " +
+				"regenerate it or set 'path-template' explicitly")
+		}
+		raw = paths.Default(headers.TagKeys, headers.TagTypes)
+	}
+	tmpl, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if headers != nil {
+		if err := tmpl.Validate(headers); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// loader.Benchmark interface implementation
+type benchmark struct {
+	conf *SpecificConfig
+	// fileName is the data file, or list or glob of them, if the data is
+	// read from files
+	fileName     string
+	dataSource   targets.DataSource
+	pathTemplate *paths.Template
+	bufPool      *sync.Pool
+	// pool is shared by the sessions of all the workers
+	pool *endpoints.Pool
+}
+
+// NewBenchmark creates a new IGinX benchmark reading from either a pre-generated
+// file or a simulator, depending on the data source config
+func NewBenchmark(conf *SpecificConfig, dataSourceConfig *source.DataSourceConfig) (targets.Benchmark, error) {
+	if len(conf.ConnectionSocketList()) == 0 {
+		return nil, errors.New("missing 'connStr' for IGinX")
+	}
+	if conf.MaxRetries < 0 {
+		return nil, errors.New("'max-retries' can not be negative")
+	}
+
+	var ds targets.DataSource
+	var tmpl *paths.Template
+	var fileName string
+	var err error
+	if dataSourceConfig.Type == source.FileDataSourceType {
+		fileName = dataSourceConfig.File.Location
+		ds = newFileDataSource(fileName)
+		if tmpl, err = conf.pathTemplate(ds.Headers()); err != nil {
+			return nil, err
+		}
+		setDecoder(ds, tmpl)
+	} else {
+		dataGenerator := &inputs.DataGenerator{}
+		simulator, err := dataGenerator.CreateSimulator(dataSourceConfig.Simulator)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl, err = conf.pathTemplate(simulator.Headers()); err != nil {
+			return nil, err
+		}
+		ds = newSimulationDataSource(simulator, tmpl)
+	}
+
+	return &benchmark{
+		conf:         conf,
+		fileName:     fileName,
+		dataSource:   ds,
+		pathTemplate: tmpl,
+		pool:         endpoints.NewPool(conf.ConnectionSocketList(), endpoints.DefaultDownTime),
+		bufPool: &sync.Pool{
+			New: func() interface{} {
+				return bytes.NewBuffer(make([]byte, 0, 4*1024*1024))
+			},
+		},
+	}, nil
+}
+
+// setDecoder sets the decoder of a data source of the binary format, and
+// the template of the decoders of the next files of a chain of them
+func setDecoder(ds targets.DataSource, tmpl *paths.Template) {
+	switch ds := ds.(type) {
+	case *binaryFileDataSource:
+		ds.decoder = newBinaryDecoder(tmpl)
+	case *chainedDataSource:
+		ds.tmpl = tmpl
+		setDecoder(ds.current, tmpl)
+	}
+}
+
+func (b *benchmark) GetDataSource() targets.DataSource {
+	return b.dataSource
+}
+
+// FileDataSources returns a data source per data file, for hashed workers
+// to load each file with a single worker
+func (b *benchmark) FileDataSources() []targets.DataSource {
+	if b.fileName == "" {
+		return nil
+	}
+	fileNames, err := load.FileNames(b.fileName)
+	if err != nil || len(fileNames) < 2 {
+		return nil
+	}
+	sources := make([]targets.DataSource, len(fileNames))
+	for i, fileName := range fileNames {
+		sources[i] = newSingleFileDataSource(fileName)
+		setDecoder(sources[i], b.pathTemplate)
+	}
+	return sources
+}
+
+func (b *benchmark) GetBatchFactory() targets.BatchFactory {
+	// only the line format is parsed by the processor, every other data
+	// source returns records that are appended to the columns directly
+	if _, ok := b.dataSource.(*fileDataSource); ok {
+		return &factory{bufPool: b.bufPool}
+	}
+	return &columnFactory{}
+}
+
+func (b *benchmark) GetPointIndexer(maxPartitions uint) targets.PointIndexer {
+	if maxPartitions > 1 {
+		return newDeviceIndexer(maxPartitions, b.pathTemplate)
+	}
+	return &targets.ConstantIndexer{}
+}
+
+func (b *benchmark) GetProcessor() targets.Processor {
+	return &processor{
+		pool:            b.pool,
+		pathTemplate:    b.pathTemplate,
+		bufPool:         b.bufPool,
+		maxRetries:      b.conf.MaxRetries,
+		retryBackoff:    b.conf.RetryBackoff,
+		retryMaxBackoff: b.conf.RetryMaxBackoff,
+	}
+}
+
+func (b *benchmark) GetDBCreator() targets.DBCreator {
+	return &dbCreator{
+		conf:         b.conf,
+		pathTemplate: b.pathTemplate,
+		headers:      b.dataSource.Headers(),
+	}
+}
+
+// Summary returns the requests and errors of every IGinX endpoint
+func (b *benchmark) Summary() string {
+	return b.pool.Summary()
+}
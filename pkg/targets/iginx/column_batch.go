@@ -0,0 +1,135 @@
+package iginx
+
+import (
+	"github.com/iznauy/IGinX-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// columnBatch accumulates records directly in the column layout expected by
+// InsertNonAlignedColumnRecords, with a group of columns for each distinct
+// set of IGinX tags of the series
+type columnBatch struct {
+	rows    uint
+	metrics uint64
+
+	groups       []*columnGroup
+	groupIndices map[string]int
+}
+
+// columnGroup holds the columns of the series sharing the same IGinX tags:
+// one column per series path, with a value (or nil) for each distinct
+// timestamp of the group.
+//
+// The client reorders the tags of an insert by iterating over a map and
+// keys the columns by path alone, so series with different tags can not be
+// inserted together and every group is inserted on its own.
+type columnGroup struct {
+	// tags of all the series of the group, nil if they are not tagged
+	tags    map[string]string
+	rows    uint
+	metrics uint64
+
+	paths            []string
+	types            []rpc.DataType
+	pathIndices      map[string]int
+	timestamps       []int64
+	timestampIndices map[int64]int
+	// columns may be shorter than timestamps, missing values are nil
+	columns [][]interface{}
+}
+
+func newColumnBatch() *columnBatch {
+	return &columnBatch{groupIndices: make(map[string]int)}
+}
+
+func (b *columnBatch) Len() uint {
+	return b.rows
+}
+
+func (b *columnBatch) Append(item data.LoadedPoint) {
+	b.appendRecord(item.Data.(*record))
+}
+
+func (b *columnBatch) appendRecord(r *record) {
+	b.rows++
+	b.metrics += uint64(len(r.paths))
+
+	key := seriesTagsKey(r.tags)
+	i, ok := b.groupIndices[key]
+	if !ok {
+		i = len(b.groups)
+		b.groupIndices[key] = i
+		g := &columnGroup{
+			pathIndices:      make(map[string]int),
+			timestampIndices: make(map[int64]int),
+		}
+		if r.tags != nil {
+			g.tags = r.tags.tags
+		}
+		b.groups = append(b.groups, g)
+	}
+	b.groups[i].appendRecord(r)
+}
+
+func (g *columnGroup) appendRecord(r *record) {
+	g.rows++
+	g.metrics += uint64(len(r.paths))
+
+	row, ok := g.timestampIndices[r.timestamp]
+	if !ok {
+		row = len(g.timestamps)
+		g.timestampIndices[r.timestamp] = row
+		g.timestamps = append(g.timestamps, r.timestamp)
+	}
+
+	for i, path := range r.paths {
+		col, ok := g.pathIndices[path]
+		if !ok {
+			col = len(g.paths)
+			g.pathIndices[path] = col
+			g.paths = append(g.paths, path)
+			g.types = append(g.types, r.types[i])
+			g.columns = append(g.columns, nil)
+		} else if r.types[i] != g.types[col] {
+			fatal("series %s has values of both %s and %s type", path, g.types[col], r.types[i])
+			return
+		}
+
+		column := g.columns[col]
+		for len(column) <= row {
+			column = append(column, nil)
+		}
+		column[row] = r.values[i]
+		g.columns[col] = column
+	}
+}
+
+// valueList returns the columns padded to the number of timestamps
+func (g *columnGroup) valueList() [][]interface{} {
+	for i, column := range g.columns {
+		for len(column) < len(g.timestamps) {
+			column = append(column, nil)
+		}
+		g.columns[i] = column
+	}
+	return g.columns
+}
+
+// tagsList returns the tags of every column, nil if the series are not tagged
+func (g *columnGroup) tagsList() []map[string]string {
+	if g.tags == nil {
+		return nil
+	}
+	tagsList := make([]map[string]string, len(g.paths))
+	for i := range tagsList {
+		tagsList[i] = g.tags
+	}
+	return tagsList
+}
+
+type columnFactory struct{}
+
+func (f *columnFactory) New() targets.Batch {
+	return newColumnBatch()
+}
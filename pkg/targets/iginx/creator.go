@@ -0,0 +1,271 @@
+package iginx
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	"github.com/pkg/errors"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+	"github.com/timescale/tsbs/pkg/targets/iginx/udfs"
+	"gopkg.in/yaml.v2"
+)
+
+// statementRunner runs the IGinX SQL statements preparing the cluster
+type statementRunner interface {
+	// Run runs a statement and reports whether it returned any row
+	Run(statement string) (bool, error)
+	Close() error
+}
+
+// sessionRunner runs the statements on a client_v2.Session
+type sessionRunner struct {
+	session *client_v2.Session
+}
+
+func (r *sessionRunner) Run(statement string) (bool, error) {
+	stream, err := r.session.ExecuteQuery(statement, 1)
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+	return stream.HasMore()
+}
+
+func (r *sessionRunner) Close() error {
+	return r.session.Close()
+}
+
+// SetupConfig lists the storage engines and Python UDFs registered in IGinX
+// before loading, read from the file set with 'setup-config'
+type SetupConfig struct {
+	StorageEngines []StorageEngine `yaml:"storage-engines"`
+	UDFs           []UDF           `yaml:"udfs"`
+}
+
+// StorageEngine is a storage engine added to IGinX, extra holds the engine
+// specific parameters such as the credentials
+type StorageEngine struct {
+	IP    string            `yaml:"ip"`
+	Port  int               `yaml:"port"`
+	Type  string            `yaml:"type"`
+	Extra map[string]string `yaml:"extra"`
+}
+
+// UDF is a Python UDF registered in IGinX. The file is read by the IGinX
+// node, a relative path is relative to the setup config file.
+type UDF = udfs.UDF
+
+// readSetupConfig reads the setup config file, resolving the UDF files
+// relative to it
+func readSetupConfig(file string) (*SetupConfig, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var conf SetupConfig
+	if err := yaml.UnmarshalStrict(raw, &conf); err != nil {
+		return nil, fmt.Errorf("cannot parse setup config %s: %v", file, err)
+	}
+	for i := range conf.UDFs {
+		udf := &conf.UDFs[i]
+		if udf.Type == "" || udf.Name == "" || udf.Class == "" || udf.File == "" {
+			return nil, fmt.Errorf("udf %d of %s needs a type, name, class and file", i, file)
+		}
+		if !filepath.IsAbs(udf.File) {
+			udf.File = filepath.Join(filepath.Dir(file), udf.File)
+		}
+		if udf.File, err = filepath.Abs(udf.File); err != nil {
+			return nil, err
+		}
+	}
+	for i, engine := range conf.StorageEngines {
+		if engine.IP == "" || engine.Port == 0 || engine.Type == "" {
+			return nil, fmt.Errorf("storage engine %d of %s needs an ip, port and type", i, file)
+		}
+	}
+	return &conf, nil
+}
+
+// statements returns the statements adding the storage engines and
+// registering the UDFs. Every UDF is dropped first, so a changed file
+// replaces the one registered by a previous run.
+func (c *SetupConfig) statements() (drops, adds []string) {
+	for _, engine := range c.StorageEngines {
+		keys := make([]string, 0, len(engine.Extra))
+		for key := range engine.Extra {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		extra := make([]string, len(keys))
+		for i, key := range keys {
+			extra[i] = key + ":" + engine.Extra[key]
+		}
+		adds = append(adds, fmt.Sprintf(`ADD STORAGEENGINE ("%s", %d, "%s", "%s")`,
+			engine.IP, engine.Port, engine.Type, strings.Join(extra, ", ")))
+	}
+	for _, udf := range c.UDFs {
+		drops = append(drops, udf.DropStatement())
+		adds = append(adds, udf.RegisterStatement())
+	}
+	return drops, adds
+}
+
+// dbCreator prepares the IGinX cluster for loading. IGinX has no databases,
+// so the name of the database is not used: the series of the benchmark are
+// the ones under the device paths of the measurements in the data headers.
+type dbCreator struct {
+	conf         *SpecificConfig
+	pathTemplate *paths.Template
+	headers      *common.GeneratedDataHeaders
+	setup        *SetupConfig
+
+	// connect opens the runner, set to newSessionRunner except in tests
+	connect func(sockets []string) (statementRunner, error)
+	runner  statementRunner
+}
+
+func newSessionRunner(sockets []string) (statementRunner, error) {
+	settings, err := client_v2.NewSessionSettings(strings.Join(sockets, ","))
+	if err != nil {
+		return nil, err
+	}
+	s := client_v2.NewSession(settings)
+	if err := s.Open(); err != nil {
+		return nil, err
+	}
+	return &sessionRunner{session: s}, nil
+}
+
+// Init reads the setup config, the connection is only opened when the data
+// is loaded
+func (d *dbCreator) Init() {
+	if d.connect == nil {
+		d.connect = newSessionRunner
+	}
+	if d.conf.SetupConfig == "" {
+		return
+	}
+	setup, err := readSetupConfig(d.conf.SetupConfig)
+	if err != nil {
+		fatal("cannot read setup config: %v", err)
+		return
+	}
+	d.setup = setup
+}
+
+// run runs a statement, connecting first if needed
+func (d *dbCreator) run(statement string) (bool, error) {
+	if d.runner == nil {
+		runner, err := d.connect(d.conf.ConnectionSocketList())
+		if err != nil {
+			return false, err
+		}
+		d.runner = runner
+	}
+	hasRows, err := d.runner.Run(statement)
+	if err != nil {
+		return false, errors.Wrap(err, statement)
+	}
+	return hasRows, nil
+}
+
+// seriesPatterns returns the path patterns of all the series of the
+// benchmark, a pattern per measurement
+func (d *dbCreator) seriesPatterns() []string {
+	if d.headers == nil {
+		return nil
+	}
+	measurements := make([]string, 0, len(d.headers.FieldKeys))
+	for measurement := range d.headers.FieldKeys {
+		measurements = append(measurements, measurement)
+	}
+	sort.Strings(measurements)
+	patterns := make([]string, len(measurements))
+	for i, measurement := range measurements {
+		patterns[i] = d.pathTemplate.DevicePattern(measurement, nil) + "." + paths.Wildcard
+	}
+	return patterns
+}
+
+// DBExists reports whether IGinX has any series of the benchmark, or any
+// series at all with 'clear-data'
+func (d *dbCreator) DBExists(_ string) bool {
+	statement := "SHOW TIME SERIES"
+	if !d.conf.ClearData {
+		patterns := d.seriesPatterns()
+		if len(patterns) == 0 {
+			log.Println("the data has no headers, can not check for the series of a previous run")
+			return false
+		}
+		statement += " " + strings.Join(patterns, ", ")
+	}
+	exists, err := d.run(statement)
+	if err != nil {
+		fatal("cannot check for existing series: %v", err)
+		return false
+	}
+	return exists
+}
+
+// RemoveOldDB deletes the series of the benchmark, or all the data of IGinX
+// with 'clear-data'
+func (d *dbCreator) RemoveOldDB(_ string) error {
+	statement := "CLEAR DATA"
+	if !d.conf.ClearData {
+		statement = "DELETE TIME SERIES " + strings.Join(d.seriesPatterns(), ", ")
+	}
+	log.Printf("removing the series of the previous run: %s\n", statement)
+	_, err := d.run(statement)
+	return err
+}
+
+// CreateDB does nothing, IGinX creates the series on their first write
+func (d *dbCreator) CreateDB(_ string) error {
+	return nil
+}
+
+// PostCreateDB adds the storage engines and registers the UDFs of the setup
+// config. Adding a storage engine IGinX already has fails, so those errors
+// are only logged.
+func (d *dbCreator) PostCreateDB(_ string) error {
+	if d.setup == nil {
+		return nil
+	}
+	drops, adds := d.setup.statements()
+	for _, statement := range drops {
+		// the UDF may not be registered yet
+		if _, err := d.run(statement); err != nil {
+			log.Printf("ignoring error: %v\n", err)
+		}
+	}
+	for _, statement := range adds {
+		_, err := d.run(statement)
+		if err != nil && strings.HasPrefix(statement, "ADD STORAGEENGINE") &&
+			errors.Cause(err) == client_v2.ErrExecution {
+			log.Printf("ignoring error, the storage engine may already be added: %v\n", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("executed: %s\n", statement)
+	}
+	return nil
+}
+
+// Close closes the connection, if it was opened
+func (d *dbCreator) Close() {
+	if d.runner == nil {
+		return
+	}
+	if err := d.runner.Close(); err != nil {
+		log.Printf("cannot close the connection: %v\n", err)
+	}
+	d.runner = nil
+}
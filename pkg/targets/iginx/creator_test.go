@@ -0,0 +1,176 @@
+package iginx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+// testRunner records the statements it runs, failing those in errs
+type testRunner struct {
+	hasRows    bool
+	errs       map[string]error
+	statements []string
+	closed     bool
+}
+
+func (r *testRunner) Run(statement string) (bool, error) {
+	r.statements = append(r.statements, statement)
+	if err := r.errs[statement]; err != nil {
+		return false, err
+	}
+	return r.hasRows, nil
+}
+
+func (r *testRunner) Close() error {
+	r.closed = true
+	return nil
+}
+
+func testDBCreator(t *testing.T, conf *SpecificConfig, r *testRunner) *dbCreator {
+	tmpl, err := paths.Parse("{measurement}.{hostname}.{field}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &dbCreator{
+		conf:         conf,
+		pathTemplate: tmpl,
+		headers: &common.GeneratedDataHeaders{
+			FieldKeys: map[string][]string{"mem": {"used"}, "cpu": {"usage_user"}},
+		},
+		connect: func([]string) (statementRunner, error) { return r, nil },
+	}
+	d.Init()
+	return d
+}
+
+func TestDBCreatorRemovesBenchmarkSeries(t *testing.T) {
+	cases := []struct {
+		desc       string
+		clearData  bool
+		hasRows    bool
+		wantExists bool
+		want       []string
+	}{
+		{
+			desc: "no series",
+			want: []string{"SHOW TIME SERIES cpu.*.*, mem.*.*"},
+		},
+		{
+			desc:       "existing series",
+			hasRows:    true,
+			wantExists: true,
+			want:       []string{"SHOW TIME SERIES cpu.*.*, mem.*.*", "DELETE TIME SERIES cpu.*.*, mem.*.*"},
+		},
+		{
+			desc:       "clear data",
+			clearData:  true,
+			hasRows:    true,
+			wantExists: true,
+			want:       []string{"SHOW TIME SERIES", "CLEAR DATA"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			r := &testRunner{hasRows: c.hasRows}
+			d := testDBCreator(t, &SpecificConfig{ConnStr: "127.0.0.1:6888", ClearData: c.clearData}, r)
+			exists := d.DBExists("benchmark")
+			if exists != c.wantExists {
+				t.Errorf("got exists %v, want %v", exists, c.wantExists)
+			}
+			if exists {
+				if err := d.RemoveOldDB("benchmark"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := d.CreateDB("benchmark"); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.statements, c.want) {
+				t.Errorf("got statements\n%q\nwant\n%q", r.statements, c.want)
+			}
+			d.Close()
+			if !r.closed {
+				t.Errorf("runner not closed")
+			}
+		})
+	}
+}
+
+func TestDBCreatorSetup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iginx-setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "setup.yaml")
+	setup := `storage-engines:
+  - ip: 127.0.0.1
+    port: 6667
+    type: iotdb12
+    extra:
+      username: root
+      password: root
+udfs:
+  - type: udsf
+    name: transposition
+    class: UDFTranspositionByTruck
+    file: udfs/udsf_transposition_by_truck.py
+`
+	if err := ioutil.WriteFile(file, []byte(setup), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &testRunner{errs: map[string]error{
+		`DROP PYTHON TASK "transposition"`:                                                 pkgerrors.Wrap(client_v2.ErrExecution, "not registered"),
+		`ADD STORAGEENGINE ("127.0.0.1", 6667, "iotdb12", "password:root, username:root")`: pkgerrors.Wrap(client_v2.ErrExecution, "already added"),
+	}}
+	d := testDBCreator(t, &SpecificConfig{ConnStr: "127.0.0.1:6888", SetupConfig: file}, r)
+	if err := d.PostCreateDB("benchmark"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`DROP PYTHON TASK "transposition"`,
+		`ADD STORAGEENGINE ("127.0.0.1", 6667, "iotdb12", "password:root, username:root")`,
+		`REGISTER UDSF PYTHON TASK "UDFTranspositionByTruck" IN "` +
+			filepath.Join(dir, "udfs", "udsf_transposition_by_truck.py") + `" AS "transposition"`,
+	}
+	if !reflect.DeepEqual(r.statements, want) {
+		t.Errorf("got statements\n%q\nwant\n%q", r.statements, want)
+	}
+
+	// failing to register a UDF fails the setup
+	r.errs[want[2]] = pkgerrors.Wrap(client_v2.ErrExecution, "no such file")
+	if err := d.PostCreateDB("benchmark"); err == nil {
+		t.Errorf("expected an error registering the UDF")
+	}
+}
+
+func TestReadSetupConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iginx-setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cases := map[string]string{
+		"unknown key":   "udf:\n  - name: x\n",
+		"missing class": "udfs:\n  - type: udsf\n    name: x\n    file: x.py\n",
+		"missing port":  "storage-engines:\n  - ip: 127.0.0.1\n    type: iotdb12\n",
+	}
+	for desc, setup := range cases {
+		file := filepath.Join(dir, "setup.yaml")
+		if err := ioutil.WriteFile(file, []byte(setup), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readSetupConfig(file); err == nil {
+			t.Errorf("%s: expected an error", desc)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package endpoints spreads the sessions of the workers over the endpoints
+// of an IGinX cluster, moving a session to another endpoint when its own
+// fails, and counts the requests and errors of every endpoint
+package endpoints
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	"github.com/pkg/errors"
+)
+
+// DefaultDownTime is how long an endpoint that failed is skipped when
+// opening sessions, unless all the endpoints are down
+const DefaultDownTime = 5 * time.Second
+
+// Endpoint is an IGinX node of the pool along with its health and counters
+type Endpoint struct {
+	addr string
+	// downUntil is the UnixNano time until which the endpoint is skipped
+	downUntil int64
+
+	sessions  uint64
+	requests  uint64
+	errors    uint64
+	failovers uint64
+}
+
+func (e *Endpoint) isDown(now time.Time) bool {
+	return now.UnixNano() < atomic.LoadInt64(&e.downUntil)
+}
+
+func (e *Endpoint) markDown(now time.Time, downTime time.Duration) {
+	atomic.StoreInt64(&e.downUntil, now.Add(downTime).UnixNano())
+}
+
+// Stats are the counters of an endpoint
+type Stats struct {
+	Addr string
+	// Sessions is the number of sessions opened on the endpoint
+	Sessions uint64
+	// Requests is the number of requests sent to the endpoint, Errors
+	// the number of them that failed
+	Requests uint64
+	Errors   uint64
+	// Failovers is the number of times a session left the endpoint after
+	// a network error
+	Failovers uint64
+}
+
+// Pool holds the endpoints of a cluster, shared by the sessions of all the
+// workers
+type Pool struct {
+	endpoints []*Endpoint
+	next      uint64
+	downTime  time.Duration
+	// open opens a session on an endpoint, replaced in tests
+	open func(addr string) (*client_v2.Session, error)
+}
+
+// NewPool returns the pool of the given ip:port endpoints. The sessions are
+// opened on the endpoints in turn, starting at a random one.
+func NewPool(addrs []string, downTime time.Duration) *Pool {
+	p := &Pool{
+		endpoints: make([]*Endpoint, len(addrs)),
+		next:      uint64(rand.Intn(len(addrs))),
+		downTime:  downTime,
+		open:      openSession,
+	}
+	for i, addr := range addrs {
+		p.endpoints[i] = &Endpoint{addr: addr}
+	}
+	return p
+}
+
+// openSession opens a session on a single endpoint. The client does not
+// reconnect or switch endpoints on its own, the Session does.
+func openSession(addr string) (*client_v2.Session, error) {
+	settings, err := client_v2.NewSessionSettings(addr)
+	if err != nil {
+		return nil, err
+	}
+	settings.EnableHighAvailable = false
+	settings.MaxRetryTimes = 0
+	s := client_v2.NewSession(settings)
+	if err := s.Open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// candidates returns the endpoints to open a session on, in turn from the
+// next endpoint with the healthy ones first
+func (p *Pool) candidates(now time.Time) []*Endpoint {
+	start := int(atomic.AddUint64(&p.next, 1) % uint64(len(p.endpoints)))
+	healthy := make([]*Endpoint, 0, len(p.endpoints))
+	var down []*Endpoint
+	for i := range p.endpoints {
+		e := p.endpoints[(start+i)%len(p.endpoints)]
+		if e.isDown(now) {
+			down = append(down, e)
+		} else {
+			healthy = append(healthy, e)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// Stats returns the counters of every endpoint, in the order they were given
+func (p *Pool) Stats() []Stats {
+	stats := make([]Stats, len(p.endpoints))
+	for i, e := range p.endpoints {
+		stats[i] = Stats{
+			Addr:      e.addr,
+			Sessions:  atomic.LoadUint64(&e.sessions),
+			Requests:  atomic.LoadUint64(&e.requests),
+			Errors:    atomic.LoadUint64(&e.errors),
+			Failovers: atomic.LoadUint64(&e.failovers),
+		}
+	}
+	return stats
+}
+
+// Summary returns the counters of every endpoint, a line each, or the empty
+// string if no session was opened
+func (p *Pool) Summary() string {
+	lines := make([]string, 0, len(p.endpoints))
+	used := false
+	for _, s := range p.Stats() {
+		used = used || s.Sessions > 0 || s.Errors > 0
+		lines = append(lines, fmt.Sprintf("endpoint %s: %d requests, %d errors, %d failovers, %d sessions",
+			s.Addr, s.Requests, s.Errors, s.Failovers, s.Sessions))
+	}
+	if !used {
+		return ""
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NewSession returns a session of the pool, which is opened on its first use
+func (p *Pool) NewSession() *Session {
+	return &Session{pool: p}
+}
+
+// Session is an IGinX session on an endpoint of the pool. A Session is not
+// safe for concurrent use, each worker has its own.
+type Session struct {
+	pool     *Pool
+	endpoint *Endpoint
+	conn     *client_v2.Session
+}
+
+// IsNetworkError reports whether err is a network error of the client, after
+// which the request may succeed on another endpoint
+func IsNetworkError(err error) bool {
+	return err != nil && errors.Cause(err) == client_v2.ErrNetwork
+}
+
+// Open opens the session on the first endpoint that accepts it, if it is
+// not open yet
+func (s *Session) Open() error {
+	if s.conn != nil {
+		return nil
+	}
+	var err error
+	now := time.Now()
+	for _, e := range s.pool.candidates(now) {
+		conn, openErr := s.pool.open(e.addr)
+		if openErr != nil {
+			atomic.AddUint64(&e.errors, 1)
+			e.markDown(now, s.pool.downTime)
+			log.Printf("cannot open a session on %s: %v\n", e.addr, openErr)
+			err = openErr
+			continue
+		}
+		atomic.AddUint64(&e.sessions, 1)
+		s.endpoint, s.conn = e, conn
+		return nil
+	}
+	return errors.Wrap(err, "no IGinX endpoint available")
+}
+
+// Do runs a request with fn on the session, opening it first if needed.
+// When fn fails with a network error, the endpoint is skipped for a while
+// and fn runs again on another endpoint, at most once per endpoint. So fn
+// must be safe to run several times.
+func (s *Session) Do(fn func(conn *client_v2.Session) error) error {
+	var err error
+	for attempt := 0; attempt < len(s.pool.endpoints); attempt++ {
+		if err = s.Open(); err != nil {
+			return err
+		}
+		e := s.endpoint
+		atomic.AddUint64(&e.requests, 1)
+		err = fn(s.conn)
+		if err == nil {
+			return nil
+		}
+		atomic.AddUint64(&e.errors, 1)
+		if !IsNetworkError(err) {
+			return err
+		}
+		atomic.AddUint64(&e.failovers, 1)
+		e.markDown(time.Now(), s.pool.downTime)
+		log.Printf("request to %s failed, switching endpoint: %v\n", e.addr, err)
+		s.drop()
+	}
+	return err
+}
+
+// drop closes the connection to a failed endpoint
+func (s *Session) drop() {
+	// the endpoint is most likely gone, so the error is expected
+	_ = s.conn.Close()
+	s.endpoint, s.conn = nil, nil
+}
+
+// Detach returns the connection of the session, if it is open, and leaves
+// the session closed, so the next request opens another one. The caller
+// closes the connection, e.g. once a request abandoned on it has returned.
+func (s *Session) Detach() *client_v2.Session {
+	conn := s.conn
+	s.endpoint, s.conn = nil, nil
+	return conn
+}
+
+// Close closes the session, if it is open
+func (s *Session) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.endpoint, s.conn = nil, nil
+	return err
+}
@@ -0,0 +1,132 @@
+package endpoints
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	"github.com/pkg/errors"
+)
+
+// testPool returns a pool whose sessions are never opened, opening them on
+// the endpoints in down fails
+func testPool(t *testing.T, addrs []string, down map[string]bool) *Pool {
+	p := NewPool(addrs, time.Minute)
+	p.next = uint64(len(addrs) - 1)
+	p.open = func(addr string) (*client_v2.Session, error) {
+		if down[addr] {
+			return nil, errors.Wrap(client_v2.ErrNetwork, "connection refused")
+		}
+		settings, err := client_v2.NewSessionSettings(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client_v2.NewSession(settings), nil
+	}
+	return p
+}
+
+func TestSessionsSpreadOverEndpoints(t *testing.T) {
+	p := testPool(t, []string{"a:1", "b:1", "c:1"}, nil)
+	var addrs []string
+	for i := 0; i < 4; i++ {
+		s := p.NewSession()
+		if err := s.Open(); err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, s.endpoint.addr)
+	}
+	if want := []string{"a:1", "b:1", "c:1", "a:1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("got endpoints %v, want %v", addrs, want)
+	}
+}
+
+func TestSessionFailover(t *testing.T) {
+	down := map[string]bool{}
+	p := testPool(t, []string{"a:1", "b:1", "c:1"}, down)
+	s := p.NewSession()
+
+	var calls []string
+	networkErr := errors.Wrap(client_v2.ErrNetwork, "broken pipe")
+	// a fails while the request runs and can not be reopened
+	err := s.Do(func(*client_v2.Session) error {
+		calls = append(calls, s.endpoint.addr)
+		if s.endpoint.addr == "a:1" {
+			down["a:1"] = true
+			return networkErr
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"a:1", "b:1"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls on %v, want %v", calls, want)
+	}
+
+	// a is skipped by the new sessions while it is down
+	other := p.NewSession()
+	if err := other.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if other.endpoint.addr == "a:1" {
+		t.Errorf("session opened on a down endpoint")
+	}
+
+	// errors returned by IGinX do not switch endpoints
+	executionErr := errors.Wrap(client_v2.ErrExecution, "syntax error")
+	err = s.Do(func(*client_v2.Session) error { return executionErr })
+	if err != executionErr {
+		t.Errorf("got error %v, want %v", err, executionErr)
+	}
+	if s.endpoint.addr != "b:1" {
+		t.Errorf("session moved to %s after an execution error", s.endpoint.addr)
+	}
+
+	want := []Stats{
+		{Addr: "a:1", Requests: 1, Errors: 1, Failovers: 1, Sessions: 1},
+		{Addr: "b:1", Requests: 2, Errors: 1, Sessions: 1},
+		{Addr: "c:1", Sessions: 1},
+	}
+	if got := p.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got stats\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSessionAllEndpointsDown(t *testing.T) {
+	p := testPool(t, []string{"a:1", "b:1"}, map[string]bool{"a:1": true, "b:1": true})
+	s := p.NewSession()
+	err := s.Do(func(*client_v2.Session) error {
+		t.Fatal("request sent without a session")
+		return nil
+	})
+	if !IsNetworkError(err) {
+		t.Errorf("expected a network error, got %v", err)
+	}
+	// the down endpoints are still tried when there is no other
+	if err := s.Open(); !IsNetworkError(err) {
+		t.Errorf("expected a network error, got %v", err)
+	}
+	for _, stats := range p.Stats() {
+		if stats.Errors != 2 {
+			t.Errorf("expected 2 errors opening sessions on %s, got %d", stats.Addr, stats.Errors)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	p := testPool(t, []string{"a:1", "b:1"}, nil)
+	if got := p.Summary(); got != "" {
+		t.Errorf("got summary of an unused pool %q", got)
+	}
+	s := p.NewSession()
+	if err := s.Do(func(*client_v2.Session) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	want := "endpoint a:1: 1 requests, 0 errors, 0 failovers, 1 sessions\n" +
+		"endpoint b:1: 0 requests, 0 errors, 0 failovers, 0 sessions"
+	if got := p.Summary(); got != want {
+		t.Errorf("got summary\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package iginx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/timescale/tsbs/load"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+const tagsKey = "tags"
+
+// newFileDataSource returns a data source for fileName, reading the data
+// headers if the file has them and then either the line format or the
+// binary format depending on how the data starts. A list or glob of files
+// is read one file after the other, each with its own data source, as the
+// series of the binary format are numbered per file.
+func newFileDataSource(fileName string) targets.DataSource {
+	fileNames, err := load.FileNames(fileName)
+	if err != nil {
+		fatal("%v", err)
+		return nil
+	}
+	if len(fileNames) == 1 {
+		return newSingleFileDataSource(fileNames[0])
+	}
+	return newChainedDataSource(fileNames)
+}
+
+// newSingleFileDataSource returns a data source for a single data file
+func newSingleFileDataSource(fileName string) targets.DataSource {
+	br := load.GetBufferedReader(fileName)
+	headers, err := readDataHeaders(br)
+	if err != nil {
+		fatal("cannot read data headers: %v", err)
+		return nil
+	}
+
+	if magic, _ := br.Peek(len(binaryMagic)); isBinaryFormat(magic) {
+		if _, err := br.Discard(len(binaryMagic)); err != nil {
+			fatal("cannot read binary format header: %v", err)
+			return nil
+		}
+		return &binaryFileDataSource{reader: br, headers: headers}
+	}
+	return &fileDataSource{scanner: bufio.NewScanner(br), headers: headers}
+}
+
+// readDataHeaders reads the headers written by the data generator, if br
+// starts with them. The first line contains the tags and their types, the
+// next lines the fields of each measurement and a blank line ends them.
+func readDataHeaders(br *bufio.Reader) (*common.GeneratedDataHeaders, error) {
+	if prefix, _ := br.Peek(len(tagsKey) + 1); !bytes.Equal(prefix, []byte(tagsKey+",")) {
+		return nil, nil
+	}
+
+	headers := &common.GeneratedDataHeaders{FieldKeys: make(map[string][]string)}
+	for i := 0; ; i++ {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("headers ended too soon: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			break
+		}
+		parts := strings.Split(line, ",")
+		if i > 0 {
+			headers.FieldKeys[parts[0]] = parts[1:]
+			continue
+		}
+		for _, tagWithType := range parts[1:] {
+			tagAndType := strings.Split(tagWithType, " ")
+			if len(tagAndType) != 2 {
+				return nil, fmt.Errorf("tag header has invalid format: %s", tagWithType)
+			}
+			headers.TagKeys = append(headers.TagKeys, tagAndType[0])
+			headers.TagTypes = append(headers.TagTypes, tagAndType[1])
+		}
+	}
+	return headers, nil
+}
+
+type fileDataSource struct {
+	scanner *bufio.Scanner
+	headers *common.GeneratedDataHeaders
+}
+
+func (d *fileDataSource) NextItem() data.LoadedPoint {
+	ok := d.scanner.Scan()
+	if !ok && d.scanner.Err() == nil { // nothing scanned & no error = EOF
+		return data.LoadedPoint{}
+	} else if !ok {
+		fatal("scan error: %v", d.scanner.Err())
+		return data.LoadedPoint{}
+	}
+	return data.NewLoadedPoint(d.scanner.Bytes())
+}
+
+func (d *fileDataSource) Headers() *common.GeneratedDataHeaders { return d.headers }
+
+// binaryFileDataSource reads records written by BinarySerializer
+type binaryFileDataSource struct {
+	reader  *bufio.Reader
+	headers *common.GeneratedDataHeaders
+	decoder *binaryDecoder
+}
+
+func (d *binaryFileDataSource) NextItem() data.LoadedPoint {
+	size, err := binary.ReadUvarint(d.reader)
+	if err == io.EOF {
+		return data.LoadedPoint{}
+	} else if err != nil {
+		fatal("cannot read record length: %v", err)
+		return data.LoadedPoint{}
+	}
+
+	body := make([]byte, size)
+	if _, err = io.ReadFull(d.reader, body); err != nil {
+		fatal("cannot read record: %v", err)
+		return data.LoadedPoint{}
+	}
+	r, err := d.decoder.decodeRecord(body)
+	if err != nil {
+		fatal("cannot decode record: %v", err)
+		return data.LoadedPoint{}
+	}
+	return data.NewLoadedPoint(r)
+}
+
+func (d *binaryFileDataSource) Headers() *common.GeneratedDataHeaders { return d.headers }
+
+// chainedDataSource reads the data files one after the other, each with its
+// own data source. The headers are those of the first file, the next files
+// must have the same or none.
+type chainedDataSource struct {
+	// fileNames are the files not opened yet
+	fileNames []string
+	current   targets.DataSource
+	headers   *common.GeneratedDataHeaders
+	// tmpl builds the paths of the binary format, set by setDecoder
+	tmpl *paths.Template
+}
+
+func newChainedDataSource(fileNames []string) *chainedDataSource {
+	first := newSingleFileDataSource(fileNames[0])
+	return &chainedDataSource{fileNames: fileNames[1:], current: first, headers: first.Headers()}
+}
+
+func (d *chainedDataSource) NextItem() data.LoadedPoint {
+	for {
+		item := d.current.NextItem()
+		if item.Data != nil || len(d.fileNames) == 0 {
+			return item
+		}
+		fileName := d.fileNames[0]
+		d.fileNames = d.fileNames[1:]
+		d.current = newSingleFileDataSource(fileName)
+		if headers := d.current.Headers(); headers != nil && !reflect.DeepEqual(headers, d.headers) {
+			fatal("file %s has other data headers than the first file", fileName)
+			return data.LoadedPoint{}
+		}
+		setDecoder(d.current, d.tmpl)
+	}
+}
+
+func (d *chainedDataSource) Headers() *common.GeneratedDataHeaders { return d.headers }
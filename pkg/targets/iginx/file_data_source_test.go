@@ -0,0 +1,261 @@
+package iginx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+func TestBatch(t *testing.T) {
+	f := &factory{bufPool: &sync.Pool{
+		New: func() interface{} {
+			return bytes.NewBuffer(make([]byte, 0, 1024))
+		},
+	}}
+	b := f.New().(*batch)
+	if b.Len() != 0 {
+		t.Errorf("batch not initialized with count 0")
+	}
+	p := data.LoadedPoint{
+		Data: []byte("cpu,tag1=tag1val,tag2=tag2val col1=0.0,col2=0.0 140"),
+	}
+	b.Append(p)
+	if b.Len() != 1 {
+		t.Errorf("batch count is not 1 after first append")
+	}
+	if b.metrics != 2 {
+		t.Errorf("batch metric count is not 2 after first append")
+	}
+
+	p = data.LoadedPoint{
+		Data: []byte("cpu,tag1=tag1val,tag2=tag2val col1=1.0,col2=1.0,col3=1.0 190"),
+	}
+	b.Append(p)
+	if b.Len() != 2 {
+		t.Errorf("batch count is not 2 after second append")
+	}
+	if b.metrics != 5 {
+		t.Errorf("batch metric count is not 5 after second append")
+	}
+
+	fatalCalled := false
+	oldFatal := fatal
+	defer func() { fatal = oldFatal }()
+	fatal = func(format string, args ...interface{}) {
+		fatalCalled = true
+	}
+	p = data.LoadedPoint{
+		Data: []byte("bad_point"),
+	}
+	b.Append(p)
+	if !fatalCalled {
+		t.Errorf("fatal was not called for bad input")
+	}
+}
+
+func TestFileDataSourceNextItem(t *testing.T) {
+	cases := []struct {
+		desc   string
+		input  string
+		result []byte
+	}{
+		{
+			desc:   "correct input",
+			input:  "cpu,tag1=tag1text,tag2=tag2text col1=0.0,col2=0.0 140\n",
+			result: []byte("cpu,tag1=tag1text,tag2=tag2text col1=0.0,col2=0.0 140"),
+		},
+		{
+			desc:   "correct input with extra",
+			input:  "cpu,tag1=tag1text,tag2=tag2text col1=0.0,col2=0.0 140\nextra_is_ignored",
+			result: []byte("cpu,tag1=tag1text,tag2=tag2text col1=0.0,col2=0.0 140"),
+		},
+	}
+
+	for _, c := range cases {
+		br := bufio.NewReader(bytes.NewReader([]byte(c.input)))
+		ds := &fileDataSource{scanner: bufio.NewScanner(br)}
+		p := ds.NextItem()
+		dataBytes := p.Data.([]byte)
+		if !bytes.Equal(dataBytes, c.result) {
+			t.Errorf("%s: incorrect result: got\n%v\nwant\n%v", c.desc, dataBytes, c.result)
+		}
+	}
+}
+
+func TestFileDataSourceNextItemEOF(t *testing.T) {
+	input := []byte("cpu,tag1=tag1text,tag2=tag2text col1=0.0,col2=0.0 140")
+	br := bufio.NewReader(bytes.NewReader(input))
+	ds := &fileDataSource{scanner: bufio.NewScanner(br)}
+	_ = ds.NextItem()
+	// nothing left, should be EOF
+	p := ds.NextItem()
+	if p.Data != nil {
+		t.Errorf("expected p.Data to be nil, got %v", p.Data)
+	}
+}
+
+func TestBatchColumns(t *testing.T) {
+	f := &factory{bufPool: &sync.Pool{
+		New: func() interface{} {
+			return bytes.NewBuffer(make([]byte, 0, 1024))
+		},
+	}}
+	b := f.New().(*batch)
+	b.Append(data.LoadedPoint{Data: []byte("cpu,hostname=host_0 usage_user=1.5,mem=3i 140")})
+	b.Append(data.LoadedPoint{Data: []byte("cpu,hostname=host_0 usage_user=2.5 190")})
+
+	tmpl, err := paths.Parse("{measurement}.{hostname}.{field}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := b.columns(newDeviceCache(tmpl))
+	if c.Len() != 2 || c.metrics != 3 {
+		t.Errorf("incorrect counts: got %d rows %d metrics", c.Len(), c.metrics)
+	}
+	if len(c.groups) != 1 {
+		t.Fatalf("incorrect number of groups: got %d", len(c.groups))
+	}
+	g := c.groups[0]
+	if want := []string{"cpu.host_0.usage_user", "cpu.host_0.mem"}; !reflect.DeepEqual(g.paths, want) {
+		t.Fatalf("incorrect paths: got %v want %v", g.paths, want)
+	}
+	if len(g.timestamps) != 2 {
+		t.Fatalf("incorrect number of timestamps: got %d", len(g.timestamps))
+	}
+	values := g.valueList()
+	if values[0][1] != 2.5 || values[1][0] != int64(3) || values[1][1] != nil {
+		t.Errorf("incorrect values: %v", values)
+	}
+}
+
+func TestReadDataHeaders(t *testing.T) {
+	input := "tags,hostname string,load_capacity float32\ncpu,usage_user,usage_system\nmem,used\n\n" +
+		"cpu,hostname=host_0 usage_user=1.5 140\n"
+	br := bufio.NewReader(bytes.NewReader([]byte(input)))
+	headers, err := readDataHeaders(br)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"hostname", "load_capacity"}; !reflect.DeepEqual(headers.TagKeys, want) {
+		t.Errorf("incorrect tag keys: got %v want %v", headers.TagKeys, want)
+	}
+	if want := []string{"string", "float32"}; !reflect.DeepEqual(headers.TagTypes, want) {
+		t.Errorf("incorrect tag types: got %v want %v", headers.TagTypes, want)
+	}
+	if want := []string{"usage_user", "usage_system"}; !reflect.DeepEqual(headers.FieldKeys["cpu"], want) {
+		t.Errorf("incorrect cpu fields: got %v want %v", headers.FieldKeys["cpu"], want)
+	}
+	ds := &fileDataSource{scanner: bufio.NewScanner(br)}
+	if got := string(ds.NextItem().Data.([]byte)); got != "cpu,hostname=host_0 usage_user=1.5 140" {
+		t.Errorf("incorrect first item after headers: %s", got)
+	}
+
+	// data without headers is left untouched
+	br = bufio.NewReader(bytes.NewReader([]byte("cpu,hostname=host_0 usage_user=1.5 140\n")))
+	if headers, err = readDataHeaders(br); err != nil || headers != nil {
+		t.Errorf("expected no headers and no error, got %v %v", headers, err)
+	}
+	ds = &fileDataSource{scanner: bufio.NewScanner(br)}
+	if got := string(ds.NextItem().Data.([]byte)); got != "cpu,hostname=host_0 usage_user=1.5 140" {
+		t.Errorf("incorrect first item without headers: %s", got)
+	}
+
+	br = bufio.NewReader(bytes.NewReader([]byte("tags,hostname string\ncpu,usage_user\n")))
+	if _, err = readDataHeaders(br); err == nil {
+		t.Errorf("expected error for headers without the blank line")
+	}
+}
+
+// binaryFile returns the binary format of a point of a truck per timestamp,
+// written by a serializer of its own as a data file is
+func binaryFile(t *testing.T, truck string, timestamps ...int64) []byte {
+	s := &BinarySerializer{}
+	buf := new(bytes.Buffer)
+	buf.WriteString("tags,name string,fleet string\ndiagnostics,status\n\n")
+	for _, ts := range timestamps {
+		p := data.NewPoint()
+		p.SetMeasurementName([]byte("diagnostics"))
+		tm := time.Unix(0, ts)
+		p.SetTimestamp(&tm)
+		p.AppendTag([]byte("name"), truck)
+		p.AppendTag([]byte("fleet"), "West")
+		p.AppendField([]byte("status"), int64(ts))
+		if err := s.Serialize(p, buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestBinaryFileDataSourceSeveralFiles(t *testing.T) {
+	gzipped := func(b []byte) []byte {
+		buf := new(bytes.Buffer)
+		w := gzip.NewWriter(buf)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	cases := []struct {
+		desc     string
+		compress func([]byte) []byte
+	}{
+		{desc: "plain", compress: func(b []byte) []byte { return b }},
+		{desc: "gzip", compress: gzipped},
+	}
+	tmpl := mustParsePathTemplate(t, testPathTemplate)
+	for _, c := range cases {
+		dir, err := ioutil.TempDir("", "iginx-binary")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		// both files number their series from 0
+		files := map[string][]byte{
+			"data-1": binaryFile(t, "truck_1", 10, 20),
+			"data-2": binaryFile(t, "truck_2", 30, 40),
+		}
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), c.compress(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ds := newFileDataSource(filepath.Join(dir, "data-*"))
+		setDecoder(ds, tmpl)
+		if h := ds.Headers(); h == nil || !reflect.DeepEqual(h.TagKeys, []string{"name", "fleet"}) {
+			t.Errorf("%s: incorrect headers: %+v", c.desc, h)
+		}
+		want := []struct {
+			path      string
+			timestamp int64
+		}{
+			{"diagnostics.truck_1.West.unknown.status", 10},
+			{"diagnostics.truck_1.West.unknown.status", 20},
+			{"diagnostics.truck_2.West.unknown.status", 30},
+			{"diagnostics.truck_2.West.unknown.status", 40},
+		}
+		for i, w := range want {
+			item := ds.NextItem()
+			r, ok := item.Data.(*record)
+			if !ok {
+				t.Fatalf("%s: item %d is not a record: %v", c.desc, i, item.Data)
+			}
+			if r.timestamp != w.timestamp || !reflect.DeepEqual(r.paths, []string{w.path}) {
+				t.Errorf("%s: incorrect item %d: got %d %v want %d %s", c.desc, i, r.timestamp, r.paths, w.timestamp, w.path)
+			}
+		}
+		if item := ds.NextItem(); item.Data != nil {
+			t.Errorf("%s: expected the end of the files, got %v", c.desc, item.Data)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package iginx
 
 import (
+	"time"
+
 	"github.com/blagojts/viper"
 	"github.com/spf13/pflag"
 	"github.com/timescale/tsbs/pkg/data/serialize"
@@ -10,24 +12,55 @@ import (
 )
 
 func NewTarget() targets.ImplementedTarget {
-	return &influxTarget{}
+	return &iginxTarget{}
+}
+
+// NewBinaryTarget returns the IGinX target generating data in the binary
+// format, loading is the same as for NewTarget
+func NewBinaryTarget() targets.ImplementedTarget {
+	return &iginxTarget{binary: true}
 }
 
-type influxTarget struct {
+type iginxTarget struct {
+	binary bool
 }
 
-func (t *influxTarget) TargetSpecificFlags(flagPrefix string, flagSet *pflag.FlagSet) {
+func (t *iginxTarget) TargetSpecificFlags(flagPrefix string, flagSet *pflag.FlagSet) {
 	flagSet.String(flagPrefix+"connStr", "127.0.0.1:6888", "Iginx addresses (ip:port,ip:port,...)")
+	flagSet.String(flagPrefix+"path-template", "",
+		"Template of the series paths, e.g. {measurement}.{hostname}.{region}.{field}. "+
+			"Defaults to a level for every string tag of the data")
+	flagSet.Bool(flagPrefix+"tagged", false,
+		"Whether to write the tags that are not levels of the path template as IGinX tags of the series, "+
+			"the path template defaults to {measurement}.{field}")
+	flagSet.Int(flagPrefix+"max-retries", 3, "Number of times a write failing with a network error is retried")
+	flagSet.Duration(flagPrefix+"retry-backoff", 100*time.Millisecond,
+		"Wait before the first retry of a write, doubled on every retry with some random jitter")
+	flagSet.Duration(flagPrefix+"retry-max-backoff", 10*time.Second, "Maximum wait between the retries of a write")
+	flagSet.Bool(flagPrefix+"clear-data", false,
+		"Whether to clear all the data of IGinX before loading, instead of only the series of the benchmark")
+	flagSet.String(flagPrefix+"setup-config", "",
+		"YAML file listing the storage engines to add and the Python UDFs to register before loading")
 }
 
-func (t *influxTarget) TargetName() string {
+func (t *iginxTarget) TargetName() string {
+	if t.binary {
+		return constants.FormatIginxBinary
+	}
 	return constants.FormatIginx
 }
 
-func (t *influxTarget) Serializer() serialize.PointSerializer {
+func (t *iginxTarget) Serializer() serialize.PointSerializer {
+	if t.binary {
+		return &BinarySerializer{}
+	}
 	return &Serializer{}
 }
 
-func (t *influxTarget) Benchmark(string, *source.DataSourceConfig, *viper.Viper) (targets.Benchmark, error) {
-	panic("not implemented")
+func (t *iginxTarget) Benchmark(_ string, dataSourceConfig *source.DataSourceConfig, v *viper.Viper) (targets.Benchmark, error) {
+	specificConfig, err := parseSpecificConfig(v)
+	if err != nil {
+		return nil, err
+	}
+	return NewBenchmark(specificConfig, dataSourceConfig)
 }
@@ -0,0 +1,95 @@
+package iginx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iznauy/IGinX-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/data/serialize"
+)
+
+// Field values in the IGinX line format carry their type the same way the
+// InfluxDB line protocol does, with an extra suffix for 32-bit integers:
+//
+//	38.5     DOUBLE
+//	38i      LONG
+//	38i32    INTEGER
+//	true     BOOLEAN
+//	"text"   BINARY
+const (
+	longSuffix    = "i"
+	integerSuffix = "i32"
+)
+
+// appendFieldValue appends v to buf in the typed IGinX line format
+func appendFieldValue(buf []byte, v interface{}) []byte {
+	switch x := v.(type) {
+	case int, int64:
+		buf = serialize.FastFormatAppend(x, buf)
+		return append(buf, longSuffix...)
+	case int32:
+		buf = strconv.AppendInt(buf, int64(x), 10)
+		return append(buf, integerSuffix...)
+	case float32:
+		// widen before formatting, the value is stored as a DOUBLE anyway,
+		// with the digits of the binary format
+		return strconv.AppendFloat(buf, float64(x), 'f', -1, 64)
+	case string:
+		return strconv.AppendQuote(buf, x)
+	case []byte:
+		return strconv.AppendQuote(buf, string(x))
+	default:
+		return serialize.FastFormatAppend(x, buf)
+	}
+}
+
+// parseFieldValue parses a single typed field value produced by appendFieldValue
+func parseFieldValue(raw string) (interface{}, rpc.DataType, error) {
+	if len(raw) == 0 {
+		return nil, rpc.DataType_DOUBLE, fmt.Errorf("empty field value")
+	}
+	switch {
+	case raw[0] == '"':
+		v, err := strconv.Unquote(raw)
+		return v, rpc.DataType_BINARY, err
+	case strings.HasSuffix(raw, integerSuffix):
+		v, err := strconv.ParseInt(raw[:len(raw)-len(integerSuffix)], 10, 32)
+		return int32(v), rpc.DataType_INTEGER, err
+	case strings.HasSuffix(raw, longSuffix):
+		v, err := strconv.ParseInt(raw[:len(raw)-len(longSuffix)], 10, 64)
+		return v, rpc.DataType_LONG, err
+	case raw == "true" || raw == "false":
+		return raw == "true", rpc.DataType_BOOLEAN, nil
+	default:
+		v, err := strconv.ParseFloat(raw, 64)
+		return v, rpc.DataType_DOUBLE, err
+	}
+}
+
+// splitUnquoted splits s around each instance of sep that is not
+// inside a double quoted string value.
+func splitUnquoted(s string, sep byte) []string {
+	if strings.IndexByte(s, '"') < 0 {
+		return strings.Split(s, string(sep))
+	}
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
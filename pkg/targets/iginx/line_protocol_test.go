@@ -0,0 +1,70 @@
+package iginx
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iznauy/IGinX-client-go/rpc"
+)
+
+func TestFieldValueRoundTrip(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    interface{}
+		raw      string
+		want     interface{}
+		wantType rpc.DataType
+	}{
+		{desc: "float64", input: 38.24311829, raw: "38.24311829", want: 38.24311829, wantType: rpc.DataType_DOUBLE},
+		{desc: "float32", input: float32(0.5), raw: "0.5", want: 0.5, wantType: rpc.DataType_DOUBLE},
+		{desc: "float32 widened", input: float32(0.1), raw: "0.10000000149011612", want: float64(float32(0.1)), wantType: rpc.DataType_DOUBLE},
+		{desc: "int", input: 38, raw: "38i", want: int64(38), wantType: rpc.DataType_LONG},
+		{desc: "int64", input: int64(5000000000), raw: "5000000000i", want: int64(5000000000), wantType: rpc.DataType_LONG},
+		{desc: "int32", input: int32(-7), raw: "-7i32", want: int32(-7), wantType: rpc.DataType_INTEGER},
+		{desc: "bool", input: true, raw: "true", want: true, wantType: rpc.DataType_BOOLEAN},
+		{desc: "string", input: "a b,\"c\"", raw: `"a b,\"c\""`, want: "a b,\"c\"", wantType: rpc.DataType_BINARY},
+		{desc: "bytes", input: []byte("xyz"), raw: `"xyz"`, want: "xyz", wantType: rpc.DataType_BINARY},
+	}
+	for _, c := range cases {
+		raw := string(appendFieldValue(nil, c.input))
+		if raw != c.raw {
+			t.Errorf("%s: incorrect serialization: got %s want %s", c.desc, raw, c.raw)
+		}
+		got, gotType, err := parseFieldValue(raw)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect value: got %#v want %#v", c.desc, got, c.want)
+		}
+		if gotType != c.wantType {
+			t.Errorf("%s: incorrect type: got %s want %s", c.desc, gotType, c.wantType)
+		}
+	}
+}
+
+func TestParseFieldValueErrors(t *testing.T) {
+	for _, raw := range []string{"", "abc", "1.5i", "99999999999i32", `"unterminated`} {
+		if _, _, err := parseFieldValue(raw); err == nil {
+			t.Errorf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestSplitUnquoted(t *testing.T) {
+	cases := []struct {
+		input string
+		sep   byte
+		want  []string
+	}{
+		{input: "a,b,c", sep: ',', want: []string{"a", "b", "c"}},
+		{input: `a="x,y",b=1`, sep: ',', want: []string{`a="x,y"`, "b=1"}},
+		{input: `cpu s="a \" b" 10`, sep: ' ', want: []string{"cpu", `s="a \" b"`, "10"}},
+	}
+	for _, c := range cases {
+		if got := splitUnquoted(c.input, c.sep); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitUnquoted(%q): got %q want %q", c.input, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package paths maps the points generated by TSBS to IGinX series paths
+package paths
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+// Placeholders of a path template, any other placeholder is a tag key
+const (
+	MeasurementPlaceholder = "measurement"
+	FieldPlaceholder       = "field"
+
+	fieldSuffix = ".{" + FieldPlaceholder + "}"
+	// MissingTagValue replaces the tags a point does not have
+	MissingTagValue = "unknown"
+	// Wildcard matches any value of a path level in IGinX
+	Wildcard = "*"
+)
+
+// TaggedDefault is the default template of tagged series, which keeps all
+// the tags of a point as IGinX tags
+const TaggedDefault = "{" + MeasurementPlaceholder + "}" + fieldSuffix
+
+// Template maps the measurement, tags and field of a point to the IGinX
+// series path it is stored in, e.g.
+//
+//	{measurement}.{hostname}.{region}.{field}
+//
+// The template must end with the '.{field}' level, the path without it is
+// the device path of the point. The series of a tagged template also have
+// the tags of the point that are not levels of the template as IGinX tags,
+// e.g. cpu.usage_user{hostname=host_0}.
+type Template struct {
+	raw string
+	// segments of the device path, even indices are literals and odd
+	// indices are placeholders
+	segments []string
+	tagged   bool
+	// levelTags are the tag keys that are levels of the template
+	levelTags map[string]bool
+}
+
+// Default returns the template with a level for every string
+// tag of the generated data, in the order they are generated
+func Default(tagKeys, tagTypes []string) string {
+	levels := []string{"{" + MeasurementPlaceholder + "}"}
+	for i, key := range tagKeys {
+		if i < len(tagTypes) && tagTypes[i] != "string" {
+			// non-string tags are loaded as fields
+			continue
+		}
+		levels = append(levels, "{"+key+"}")
+	}
+	return strings.Join(levels, ".") + fieldSuffix
+}
+
+// Parse parses a path template
+func Parse(s string) (*Template, error) {
+	if !strings.HasSuffix(s, fieldSuffix) {
+		return nil, fmt.Errorf("path template '%s' must end with '%s'", s, fieldSuffix)
+	}
+	device := strings.TrimSuffix(s, fieldSuffix)
+
+	var segments []string
+	for len(device) > 0 {
+		open := strings.IndexByte(device, '{')
+		if open < 0 {
+			segments = append(segments, device)
+			break
+		}
+		end := strings.IndexByte(device[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("path template '%s' has an unclosed '{'", s)
+		}
+		end += open
+		placeholder := device[open+1 : end]
+		switch {
+		case placeholder == "":
+			return nil, fmt.Errorf("path template '%s' has an empty placeholder", s)
+		case placeholder == FieldPlaceholder:
+			return nil, fmt.Errorf("path template '%s' must only have '%s' as its last level", s, fieldSuffix)
+		case strings.ContainsAny(placeholder, "{."):
+			return nil, fmt.Errorf("path template '%s' has an invalid placeholder '%s'", s, placeholder)
+		}
+		segments = append(segments, device[:open], placeholder)
+		device = device[end+1:]
+	}
+	if strings.ContainsAny(strings.Join(literals(segments), ""), "{}") {
+		return nil, fmt.Errorf("path template '%s' has an unopened '}'", s)
+	}
+	levelTags := make(map[string]bool)
+	for i := 1; i < len(segments); i += 2 {
+		levelTags[segments[i]] = true
+	}
+	return &Template{raw: s, segments: segments, levelTags: levelTags}, nil
+}
+
+// ParseTagged parses a path template of tagged series
+func ParseTagged(s string) (*Template, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	t.tagged = true
+	return t, nil
+}
+
+func literals(segments []string) []string {
+	var l []string
+	for i := 0; i < len(segments); i += 2 {
+		l = append(l, segments[i])
+	}
+	return l
+}
+
+// String returns the template as it was parsed
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Tagged reports whether the series of the template have IGinX tags
+func (t *Template) Tagged() bool {
+	return t.tagged
+}
+
+// TagKeys returns the tag keys used by the template, in order
+func (t *Template) TagKeys() []string {
+	var keys []string
+	for i := 1; i < len(t.segments); i += 2 {
+		if t.segments[i] != MeasurementPlaceholder {
+			keys = append(keys, t.segments[i])
+		}
+	}
+	return keys
+}
+
+// Level returns the 0-based index of the path level holding the given
+// placeholder, or -1 if the template does not use it
+func (t *Template) Level(placeholder string) int {
+	level := 0
+	for i, segment := range t.segments {
+		if i%2 == 0 {
+			level += strings.Count(segment, ".")
+		} else if segment == placeholder {
+			return level
+		}
+	}
+	return -1
+}
+
+// Validate checks that all the tags used by the template are string tags
+// of the generated data described by headers
+func (t *Template) Validate(headers *common.GeneratedDataHeaders) error {
+	types := make(map[string]string, len(headers.TagKeys))
+	for i, key := range headers.TagKeys {
+		if i < len(headers.TagTypes) {
+			types[key] = headers.TagTypes[i]
+		} else {
+			types[key] = "string"
+		}
+	}
+	for _, key := range t.TagKeys() {
+		typ, ok := types[key]
+		if !ok {
+			return fmt.Errorf("path template '%s' uses tag '%s' which is not in the data, tags are: %s",
+				t.raw, key, strings.Join(headers.TagKeys, ", "))
+		}
+		if typ != "string" {
+			return fmt.Errorf("path template '%s' uses tag '%s' of type %s, only string tags can be used",
+				t.raw, key, typ)
+		}
+	}
+	return nil
+}
+
+// DevicePath returns the path of the device of a point, tags the point does
+// not have are replaced with "unknown"
+func (t *Template) DevicePath(measurement string, tags map[string]string) string {
+	return t.device(measurement, tags, MissingTagValue)
+}
+
+// DevicePattern returns the path pattern matching the devices with the given
+// tags, tags that are not given match any value
+func (t *Template) DevicePattern(measurement string, tags map[string]string) string {
+	return t.device(measurement, tags, Wildcard)
+}
+
+// Path returns the full path of a series
+func (t *Template) Path(measurement string, tags map[string]string, field string) string {
+	return t.DevicePath(measurement, tags) + "." + Sanitize(field)
+}
+
+// SeriesTags returns the IGinX tags of the series of a point with the given
+// tags, that is the tags which are not levels of the template. Returns nil
+// if the template is not tagged or there are no such tags.
+func (t *Template) SeriesTags(tags map[string]string) map[string]string {
+	if !t.tagged {
+		return nil
+	}
+	var seriesTags map[string]string
+	for k, v := range tags {
+		if t.levelTags[k] {
+			continue
+		}
+		if seriesTags == nil {
+			seriesTags = make(map[string]string, len(tags))
+		}
+		seriesTags[Sanitize(k)] = Sanitize(v)
+	}
+	return seriesTags
+}
+
+func (t *Template) device(measurement string, tags map[string]string, missing string) string {
+	var sb strings.Builder
+	for i, segment := range t.segments {
+		if i%2 == 0 {
+			sb.WriteString(segment)
+			continue
+		}
+		if segment == MeasurementPlaceholder {
+			sb.WriteString(Sanitize(measurement))
+		} else if v, ok := tags[segment]; ok {
+			sb.WriteString(Sanitize(v))
+		} else {
+			sb.WriteString(missing)
+		}
+	}
+	return sb.String()
+}
+
+// Sanitize replaces the characters that can not be used in an IGinX path level
+func Sanitize(level string) string {
+	return replacer.Replace(level)
+}
+
+var replacer = strings.NewReplacer(".", "_", "-", "_")
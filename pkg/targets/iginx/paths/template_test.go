@@ -0,0 +1,118 @@
+package paths
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+)
+
+func TestDefault(t *testing.T) {
+	got := Default(
+		[]string{"name", "fleet", "load_capacity", "model"},
+		[]string{"string", "string", "float32", "string"})
+	want := "{measurement}.{name}.{fleet}.{model}.{field}"
+	if got != want {
+		t.Errorf("incorrect default template: got %s want %s", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tmpl, err := Parse("{measurement}.{hostname}.dc_{datacenter}.{field}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"hostname", "datacenter"}; !reflect.DeepEqual(tmpl.TagKeys(), want) {
+		t.Errorf("incorrect tag keys: got %v want %v", tmpl.TagKeys(), want)
+	}
+
+	if got := tmpl.Level("datacenter"); got != 2 {
+		t.Errorf("incorrect level of datacenter: got %d want 2", got)
+	}
+	if got := tmpl.Level("region"); got != -1 {
+		t.Errorf("incorrect level of unused tag: got %d want -1", got)
+	}
+
+	tags := map[string]string{"hostname": "host-0", "datacenter": "eu-west-1.a"}
+	if got := tmpl.Path("cpu", tags, "usage-user"); got != "cpu.host_0.dc_eu_west_1_a.usage_user" {
+		t.Errorf("incorrect path: got %s", got)
+	}
+	if got := tmpl.DevicePath("cpu", map[string]string{}); got != "cpu.unknown.dc_unknown" {
+		t.Errorf("incorrect device path for missing tags: got %s", got)
+	}
+	if got := tmpl.DevicePattern("cpu", map[string]string{"hostname": "host_1"}); got != "cpu.host_1.dc_*" {
+		t.Errorf("incorrect device pattern: got %s", got)
+	}
+
+	for _, bad := range []string{
+		"",
+		"{measurement}.{hostname}",
+		"{measurement}.{field}.{hostname}.{field}",
+		"{measurement}.{hostname.{field}",
+		"{measurement}.{}.{field}",
+		"{measurement}.host}.{field}",
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected error for template '%s'", bad)
+		}
+	}
+}
+
+func TestTemplateValidate(t *testing.T) {
+	headers := &common.GeneratedDataHeaders{
+		TagKeys:  []string{"name", "fleet", "load_capacity"},
+		TagTypes: []string{"string", "string", "float32"},
+	}
+	cases := []struct {
+		template string
+		valid    bool
+	}{
+		{template: "{measurement}.{name}.{fleet}.{field}", valid: true},
+		{template: "{fleet}.{field}", valid: true},
+		{template: "{measurement}.{hostname}.{field}", valid: false},
+		{template: "{measurement}.{load_capacity}.{field}", valid: false},
+	}
+	for _, c := range cases {
+		tmpl, err := Parse(c.template)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err = tmpl.Validate(headers); (err == nil) != c.valid {
+			t.Errorf("%s: incorrect validation result: %v", c.template, err)
+		}
+	}
+}
+
+func TestSeriesTags(t *testing.T) {
+	tags := map[string]string{"hostname": "host_0", "region": "us-west-1"}
+
+	tmpl, err := Parse("{measurement}.{hostname}.{field}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := tmpl.SeriesTags(tags); got != nil {
+		t.Errorf("untagged template has series tags: %v", got)
+	}
+
+	tmpl, err = ParseTagged("{measurement}.{hostname}.{field}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := tmpl.SeriesTags(tags), map[string]string{"region": "us_west_1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect series tags: got %v want %v", got, want)
+	}
+	if got := tmpl.Path("cpu", tags, "usage_user"); got != "cpu.host_0.usage_user" {
+		t.Errorf("incorrect path: got %s", got)
+	}
+
+	tmpl, err = ParseTagged(TaggedDefault)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := tmpl.SeriesTags(tags), map[string]string{"hostname": "host_0", "region": "us_west_1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect series tags: got %v want %v", got, want)
+	}
+	if got := tmpl.SeriesTags(nil); got != nil {
+		t.Errorf("point without tags has series tags: %v", got)
+	}
+}
@@ -0,0 +1,49 @@
+package iginx
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/common"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+// newDeviceIndexer returns the indexer sending the points of the same
+// device, i.e. the same device path and IGinX tags, to the same worker
+func newDeviceIndexer(maxPartitions uint, tmpl *paths.Template) targets.PointIndexer {
+	devices := newDeviceCache(tmpl)
+	return common.NewGenericPointIndexer(maxPartitions, func(point *data.LoadedPoint) []byte {
+		return deviceKey(point, devices)
+	})
+}
+
+// deviceKey returns the device path of a point followed by the key of its
+// IGinX tags, if any. Lines are mapped to their device with devices, the
+// records already hold the series paths.
+func deviceKey(point *data.LoadedPoint, devices *deviceCache) []byte {
+	var path string
+	var tags *seriesTags
+	switch p := point.Data.(type) {
+	case []byte:
+		end := bytes.IndexByte(p, ' ')
+		if end < 0 {
+			end = len(p)
+		}
+		d := devices.get(string(p[:end]))
+		path, tags = d.path, d.tags
+	case *record:
+		// all the series of a record have the same device path
+		if len(p.paths) > 0 {
+			if i := strings.LastIndexByte(p.paths[0], '.'); i >= 0 {
+				path = p.paths[0][:i]
+			}
+		}
+		tags = p.tags
+	}
+	if tags == nil {
+		return []byte(path)
+	}
+	return []byte(path + "{" + tags.key + "}")
+}
@@ -0,0 +1,91 @@
+package iginx
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+func TestDeviceKey(t *testing.T) {
+	cases := []struct {
+		desc     string
+		template string
+		tagged   bool
+		want     string
+	}{
+		{
+			desc:     "path levels",
+			template: testPathTemplate,
+			want:     "diagnostics.truck_3.West.unknown",
+		},
+		{
+			desc:     "tagged",
+			template: "{measurement}.{name}.{field}",
+			tagged:   true,
+			want:     "diagnostics.truck_3{fleet=West}",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			parse := paths.Parse
+			if c.tagged {
+				parse = paths.ParseTagged
+			}
+			tmpl, err := parse(c.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := testPoint()
+			buf := new(bytes.Buffer)
+			if err := (&Serializer{}).Serialize(p, buf); err != nil {
+				t.Fatal(err)
+			}
+			devices := newDeviceCache(tmpl)
+			line := data.NewLoadedPoint(bytes.TrimSuffix(buf.Bytes(), newLine))
+			if got := string(deviceKey(&line, devices)); got != c.want {
+				t.Errorf("incorrect key of line: got %s want %s", got, c.want)
+			}
+			r := data.NewLoadedPoint(recordFromPoint(p, tmpl))
+			if got := string(deviceKey(&r, devices)); got != c.want {
+				t.Errorf("incorrect key of record: got %s want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDeviceIndexer(t *testing.T) {
+	tmpl := mustParsePathTemplate(t, "{measurement}.{name}.{field}")
+	lines := make([][]byte, 1000)
+	for i := range lines {
+		lines[i] = []byte(fmt.Sprintf("readings,name=truck_%d,fleet=West velocity=%d 10", i, i))
+	}
+	for _, parts := range []uint{2, 10, 100} {
+		indexer := newDeviceIndexer(parts, tmpl)
+		counts := make([]int, parts)
+		indices := make([]uint, len(lines))
+		for i, line := range lines {
+			idx := indexer.GetIndex(data.NewLoadedPoint(line))
+			if idx >= parts {
+				t.Fatalf("got too large a partition: got %d want < %d", idx, parts)
+			}
+			counts[idx]++
+			indices[i] = idx
+		}
+		// with 1000 devices, very unlikely some partition is empty
+		for _, c := range counts {
+			if c == 0 {
+				t.Errorf("unlikely result of 0 devices in a partition for %d partitions", parts)
+			}
+		}
+		// other points of the same devices go to the same partitions
+		for i := range lines {
+			line := []byte(fmt.Sprintf("readings,name=truck_%d,fleet=East velocity=0 20", i))
+			if idx := indexer.GetIndex(data.NewLoadedPoint(line)); idx != indices[i] {
+				t.Errorf("device truck_%d moved from partition %d to %d", i, indices[i], idx)
+			}
+		}
+	}
+}
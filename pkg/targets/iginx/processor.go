@@ -0,0 +1,166 @@
+package iginx
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	"github.com/iznauy/IGinX-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/iginx/endpoints"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+// session is the part of client_v2.Session used to write the data. Unlike
+// the client, it leaves the paths in their order, see poolSession.
+type session interface {
+	InsertNonAlignedColumnRecords(paths []string, timestamps []int64, valueList [][]interface{},
+		dataTypeList []rpc.DataType, tagsList []map[string]string) error
+	Close() error
+}
+
+type processor struct {
+	pool         *endpoints.Pool
+	pathTemplate *paths.Template
+	bufPool      *sync.Pool
+	session      session
+	// devices of the lines parsed so far by this worker
+	devices *deviceCache
+
+	maxRetries      int
+	retryBackoff    time.Duration
+	retryMaxBackoff time.Duration
+	// failures since the last call to Failures
+	failures targets.WriteFailures
+}
+
+func init() {
+	rand.Seed(time.Now().UTC().UnixNano())
+}
+
+// poolSession writes with a session of the endpoint pool, which moves to
+// another endpoint when its own fails
+type poolSession struct {
+	*endpoints.Session
+}
+
+func (s *poolSession) InsertNonAlignedColumnRecords(paths []string, timestamps []int64, valueList [][]interface{},
+	dataTypeList []rpc.DataType, tagsList []map[string]string) error {
+	return s.Do(func(conn *client_v2.Session) error {
+		// the client sorts the paths in place but not the values and types,
+		// so every attempt needs a copy of the paths in their original order
+		return conn.InsertNonAlignedColumnRecords(append([]string(nil), paths...), timestamps, valueList, dataTypeList, tagsList)
+	})
+}
+
+func (p *processor) Init(_ int, doLoad, _ bool) {
+	p.devices = newDeviceCache(p.pathTemplate)
+	if !doLoad {
+		return
+	}
+	s := p.pool.NewSession()
+	if err := s.Open(); err != nil {
+		log.Fatal(err)
+	}
+	p.session = &poolSession{Session: s}
+}
+
+func (p *processor) Close(doLoad bool) {
+	if !doLoad {
+		return
+	}
+	if err := p.session.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (p *processor) ProcessBatch(b targets.Batch, doLoad bool) (uint64, uint64) {
+	var columns *columnBatch
+	switch batch := b.(type) {
+	case *columnBatch:
+		columns = batch
+	case *batch:
+		// Return the batch buffer to the pool.
+		defer func() {
+			batch.buf.Reset()
+			p.bufPool.Put(batch.buf)
+		}()
+		if !doLoad {
+			return batch.metrics, uint64(batch.rows)
+		}
+		columns = batch.columns(p.devices)
+	}
+
+	metricCnt := columns.metrics
+	rowCnt := columns.rows
+	if !doLoad {
+		return metricCnt, uint64(rowCnt)
+	}
+
+	var failedMetrics, failedRows uint64
+	var err error
+	for _, g := range columns.groups {
+		retries, groupErr := p.insert(g)
+		p.failures.Retries += retries
+		if groupErr != nil {
+			failedMetrics += g.metrics
+			failedRows += uint64(g.rows)
+			err = groupErr
+		}
+	}
+
+	if err != nil {
+		p.failures.Batches++
+		p.failures.Metrics += failedMetrics
+		p.failures.Rows += failedRows
+		log.Printf("write failed: %v\n", err)
+		return metricCnt - failedMetrics, uint64(rowCnt) - failedRows
+	}
+	return metricCnt, uint64(rowCnt)
+}
+
+// Failures returns the write failures since the previous call
+func (p *processor) Failures() targets.WriteFailures {
+	f := p.failures
+	p.failures = targets.WriteFailures{}
+	return f
+}
+
+// insert writes the columns of a group, retrying the writes that fail with
+// a retriable error. Returns the number of retries and the error of the
+// last attempt.
+func (p *processor) insert(g *columnGroup) (uint64, error) {
+	valueList := g.valueList()
+	tagsList := g.tagsList()
+	for retries := uint64(0); ; retries++ {
+		err := p.session.InsertNonAlignedColumnRecords(g.paths, g.timestamps, valueList, g.types, tagsList)
+		if err == nil || !isRetriable(err) || retries >= uint64(p.maxRetries) {
+			return retries, err
+		}
+		log.Printf("retrying write after error: %v\n", err)
+		time.Sleep(p.backoff(retries))
+	}
+}
+
+// backoff returns the wait before the given retry, an exponential backoff
+// with the upper half of it randomized so workers do not retry in lockstep
+func (p *processor) backoff(retry uint64) time.Duration {
+	d := p.retryMaxBackoff
+	// compare before shifting, a large backoff shifted would overflow
+	if p.retryBackoff < p.retryMaxBackoff>>retry {
+		d = p.retryBackoff << retry
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// isRetriable reports whether a write failed with an error that may not
+// happen again, i.e. a network error. Writes rejected by IGinX or the
+// client would fail the same way again.
+func isRetriable(err error) bool {
+	return endpoints.IsNetworkError(err)
+}
@@ -0,0 +1,149 @@
+package iginx
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iznauy/IGinX-client-go/client_v2"
+	"github.com/iznauy/IGinX-client-go/rpc"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets"
+)
+
+// testSession fails the first writes with the given errors
+type testSession struct {
+	errs   []error
+	writes int
+	paths  [][]string
+}
+
+func (s *testSession) InsertNonAlignedColumnRecords(paths []string, _ []int64, _ [][]interface{}, _ []rpc.DataType, _ []map[string]string) error {
+	s.paths = append(s.paths, append([]string(nil), paths...))
+	s.writes++
+	if s.writes <= len(s.errs) {
+		return s.errs[s.writes-1]
+	}
+	return nil
+}
+
+func (s *testSession) Close() error {
+	return nil
+}
+
+func testColumnBatch() *columnBatch {
+	b := newColumnBatch()
+	b.Append(data.NewLoadedPoint(&record{
+		timestamp: 10,
+		paths:     []string{"cpu.usage_user", "cpu.usage_system"},
+		values:    []interface{}{1.0, 2.0},
+		types:     []rpc.DataType{rpc.DataType_DOUBLE, rpc.DataType_DOUBLE},
+	}))
+	return b
+}
+
+func TestProcessBatchRetries(t *testing.T) {
+	networkErr := pkgerrors.Wrap(client_v2.ErrNetwork, "connection reset")
+	executionErr := pkgerrors.Wrap(client_v2.ErrExecution, "type mismatch")
+	cases := []struct {
+		desc         string
+		errs         []error
+		wantWrites   int
+		wantCounts   [2]uint64
+		wantFailures targets.WriteFailures
+	}{
+		{
+			desc:       "success",
+			wantWrites: 1,
+			wantCounts: [2]uint64{2, 1},
+		},
+		{
+			desc:         "retried network error",
+			errs:         []error{networkErr, networkErr},
+			wantWrites:   3,
+			wantCounts:   [2]uint64{2, 1},
+			wantFailures: targets.WriteFailures{Retries: 2},
+		},
+		{
+			desc:         "too many network errors",
+			errs:         []error{networkErr, networkErr, networkErr},
+			wantWrites:   3,
+			wantFailures: targets.WriteFailures{Batches: 1, Metrics: 2, Rows: 1, Retries: 2},
+		},
+		{
+			desc:         "execution error",
+			errs:         []error{executionErr},
+			wantWrites:   1,
+			wantFailures: targets.WriteFailures{Batches: 1, Metrics: 2, Rows: 1},
+		},
+		{
+			desc:         "client error",
+			errs:         []error{errors.New("invalid insert request")},
+			wantWrites:   1,
+			wantFailures: targets.WriteFailures{Batches: 1, Metrics: 2, Rows: 1},
+		},
+	}
+	for _, c := range cases {
+		s := &testSession{errs: c.errs}
+		p := &processor{session: s, maxRetries: 2}
+		metrics, rows := p.ProcessBatch(testColumnBatch(), true)
+		if got := [2]uint64{metrics, rows}; got != c.wantCounts {
+			t.Errorf("%s: incorrect counts: got %v want %v", c.desc, got, c.wantCounts)
+		}
+		if s.writes != c.wantWrites {
+			t.Errorf("%s: incorrect number of writes: got %d want %d", c.desc, s.writes, c.wantWrites)
+		}
+		if got := p.Failures(); got != c.wantFailures {
+			t.Errorf("%s: incorrect failures: got %+v want %+v", c.desc, got, c.wantFailures)
+		}
+		if got := p.Failures(); got != (targets.WriteFailures{}) {
+			t.Errorf("%s: failures not reset: got %+v", c.desc, got)
+		}
+		for i, paths := range s.paths {
+			if want := []string{"cpu.usage_user", "cpu.usage_system"}; !reflect.DeepEqual(paths, want) {
+				t.Errorf("%s: incorrect paths of write %d: got %v want %v", c.desc, i, paths, want)
+			}
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &processor{retryBackoff: 100, retryMaxBackoff: 1000}
+	cases := []struct {
+		retry uint64
+		max   int64
+	}{
+		{retry: 0, max: 100},
+		{retry: 1, max: 200},
+		{retry: 3, max: 800},
+		{retry: 4, max: 1000},
+		{retry: 100, max: 1000},
+	}
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			if got := int64(p.backoff(c.retry)); got < c.max/2 || got > c.max {
+				t.Errorf("retry %d: backoff %d not in [%d, %d]", c.retry, got, c.max/2, c.max)
+			}
+		}
+	}
+
+	// a large backoff shifted would overflow
+	p = &processor{retryBackoff: 10 * time.Second, retryMaxBackoff: time.Hour}
+	cases = []struct {
+		retry uint64
+		max   int64
+	}{
+		{retry: 9, max: int64(time.Hour)},
+		{retry: 31, max: int64(time.Hour)},
+		{retry: 40, max: int64(time.Hour)},
+	}
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			if got := int64(p.backoff(c.retry)); got < c.max/2 || got > c.max {
+				t.Errorf("retry %d: backoff %d not in [%d, %d]", c.retry, got, c.max/2, c.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package iginx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iznauy/IGinX-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+// record is a single row ready to be inserted into IGinX: the full series
+// path of every value along with the value itself and its IGinX type
+type record struct {
+	timestamp int64
+	paths     []string
+	values    []interface{}
+	types     []rpc.DataType
+	// tags are the IGinX tags of all the series of the record, nil if the
+	// series are not tagged
+	tags *seriesTags
+}
+
+// seriesTags are the IGinX tags of some series, along with the key
+// identifying them
+type seriesTags struct {
+	tags map[string]string
+	key  string
+}
+
+func newSeriesTags(tags map[string]string) *seriesTags {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + tags[k]
+	}
+	return &seriesTags{tags: tags, key: strings.Join(keys, ",")}
+}
+
+// seriesTagsKey returns the key of t, the empty string for series without tags
+func seriesTagsKey(t *seriesTags) string {
+	if t == nil {
+		return ""
+	}
+	return t.key
+}
+
+// device holds the device path and the IGinX tags of the series of a point
+type device struct {
+	path string
+	tags *seriesTags
+}
+
+func newDevice(tmpl *paths.Template, measurement string, tags map[string]string) *device {
+	return &device{
+		path: tmpl.DevicePath(measurement, tags),
+		tags: newSeriesTags(tmpl.SeriesTags(tags)),
+	}
+}
+
+// deviceCache builds the device of each distinct measurement and tags
+// string, <measurement>,<tag key>=<tag value>,..., only once
+type deviceCache struct {
+	tmpl    *paths.Template
+	devices map[string]*device
+}
+
+func newDeviceCache(tmpl *paths.Template) *deviceCache {
+	return &deviceCache{tmpl: tmpl, devices: make(map[string]*device)}
+}
+
+func (c *deviceCache) get(s string) *device {
+	d, ok := c.devices[s]
+	if !ok {
+		measurement, tags := parseDevice(s)
+		d = newDevice(c.tmpl, measurement, tags)
+		c.devices[s] = d
+	}
+	return d
+}
+
+// parseLine parses a line in the IGinX line format into a record, with the
+// series paths built by the template of devices
+func parseLine(line string, devices *deviceCache) (*record, error) {
+	parts := splitUnquoted(line, ' ')
+	if len(parts) != 3 {
+		return nil, fmt.Errorf(errNotThreeTuplesFmt, len(parts))
+	}
+	timestamp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse timestamp %s: %v", parts[2], err)
+	}
+	r, err := parseMeasurementAndValues(devices.get(parts[0]), parts[1])
+	if err != nil {
+		return nil, err
+	}
+	r.timestamp = timestamp
+	return r, nil
+}
+
+// Timestamp returns the timestamp in nanoseconds of an item of the data
+// sources of the target, either a line of the line format or a record
+func Timestamp(item data.LoadedPoint) (int64, error) {
+	switch v := item.Data.(type) {
+	case *record:
+		return v.timestamp, nil
+	case []byte:
+		// the timestamp is the last part of a line, string values may
+		// contain spaces but not the timestamp
+		i := bytes.LastIndexByte(v, ' ')
+		if i < 0 {
+			return 0, fmt.Errorf(errNotThreeTuplesFmt, 1)
+		}
+		timestamp, err := strconv.ParseInt(string(v[i+1:]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse timestamp %s: %v", v[i+1:], err)
+		}
+		return timestamp, nil
+	default:
+		return 0, fmt.Errorf("unknown item type %T", item.Data)
+	}
+}
+
+// parseMeasurementAndValues builds the series of a line from its device and
+// parses its field values, keeping the type each value was serialized with.
+func parseMeasurementAndValues(d *device, fields string) (*record, error) {
+	sec := splitUnquoted(fields, ',')
+	r := &record{
+		paths:  make([]string, 0, len(sec)),
+		values: make([]interface{}, 0, len(sec)),
+		types:  make([]rpc.DataType, 0, len(sec)),
+		tags:   d.tags,
+	}
+	for _, field := range sec {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("field %s is not in the format key=value", field)
+		}
+		v, t, err := parseFieldValue(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse value of field %s: %v", kv[0], err)
+		}
+		r.paths = append(r.paths, d.path+"."+paths.Sanitize(kv[0]))
+		r.values = append(r.values, v)
+		r.types = append(r.types, t)
+	}
+	return r, nil
+}
+
+// parseDevice splits the measurement and tags part of a line,
+// <measurement>,<tag key>=<tag value>,..., into its measurement and tags
+func parseDevice(device string) (string, map[string]string) {
+	parts := strings.Split(device, ",")
+	tags := make(map[string]string, len(parts)-1)
+	for _, tag := range parts[1:] {
+		if kv := strings.SplitN(tag, "=", 2); len(kv) == 2 {
+			tags[kv[0]] = kv[1]
+		}
+	}
+	return parts[0], tags
+}
+
+// pointValues calls fn with the key and value of the fields of p, including
+// the non-string tags that are loaded as fields. Returns false, without
+// calling fn, if all the fields of p are nil.
+func pointValues(p *data.Point, fakeTags []int, fn func(key []byte, v interface{})) bool {
+	fieldValues := p.FieldValues()
+	hasField := false
+	for _, v := range fieldValues {
+		if v != nil {
+			hasField = true
+			break
+		}
+	}
+	// same as the line format, points with only nil fields are skipped
+	if !hasField {
+		return false
+	}
+
+	tagKeys := p.TagKeys()
+	tagValues := p.TagValues()
+	for _, i := range fakeTags {
+		fn(tagKeys[i], tagValues[i])
+	}
+	for i, key := range p.FieldKeys() {
+		if fieldValues[i] != nil {
+			fn(key, fieldValues[i])
+		}
+	}
+	return true
+}
+
+// recordFromPoint builds the record of p without going through the line
+// format. Returns nil if all the fields of p are nil.
+func recordFromPoint(p *data.Point, tmpl *paths.Template) *record {
+	tags := make(map[string]string)
+	var fakeTags []int
+	tagValues := p.TagValues()
+	for i, key := range p.TagKeys() {
+		switch v := tagValues[i].(type) {
+		case nil:
+		case string:
+			tags[string(key)] = v
+		default:
+			fakeTags = append(fakeTags, i)
+		}
+	}
+	d := newDevice(tmpl, string(p.MeasurementName()), tags)
+
+	r := &record{timestamp: p.Timestamp().UTC().UnixNano(), tags: d.tags}
+	ok := pointValues(p, fakeTags, func(key []byte, v interface{}) {
+		value, typ := valueAndType(v)
+		r.paths = append(r.paths, d.path+"."+paths.Sanitize(string(key)))
+		r.values = append(r.values, value)
+		r.types = append(r.types, typ)
+	})
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// valueAndType converts a generated value to the Go type the IGinX client
+// expects for it, following the same typing rules as the line format
+func valueAndType(v interface{}) (interface{}, rpc.DataType) {
+	switch x := v.(type) {
+	case float64:
+		return x, rpc.DataType_DOUBLE
+	case float32:
+		return float64(x), rpc.DataType_DOUBLE
+	case int:
+		return int64(x), rpc.DataType_LONG
+	case int64:
+		return x, rpc.DataType_LONG
+	case int32:
+		return x, rpc.DataType_INTEGER
+	case bool:
+		return x, rpc.DataType_BOOLEAN
+	case string:
+		return x, rpc.DataType_BINARY
+	case []byte:
+		return string(x), rpc.DataType_BINARY
+	default:
+		value, typ, err := parseFieldValue(string(appendFieldValue(nil, v)))
+		if err != nil {
+			fatal("cannot convert value %v of type %T: %v", v, v, err)
+		}
+		return value, typ
+	}
+}
+
+// The binary IGinX format starts with binaryMagic, followed by the records,
+// each of them framed as:
+//
+//	uvarint  length of the rest of the record
+//	varint   timestamp in nanoseconds
+//	uvarint  number of values
+//	for each value:
+//	  uvarint  series id, ids are assigned in order of first appearance and
+//	           the first appearance is followed by the series definition:
+//	             uvarint length, followed by <measurement>,<tag>=<value>,...
+//	             uvarint length, followed by the field name
+//	  byte     rpc.DataType of the value
+//	  value    DOUBLE: 8 bytes little endian IEEE 754
+//	           LONG, INTEGER: varint
+//	           BOOLEAN: 1 byte
+//	           BINARY: uvarint length, followed by the bytes
+//
+// The series paths are built once per series from its definition, when the
+// file is loaded, so the same file can be loaded with any path template.
+var binaryMagic = []byte("IGXB\x02")
+
+// isBinaryFormat reports whether b starts with the binary IGinX format magic
+func isBinaryFormat(b []byte) bool {
+	return bytes.HasPrefix(b, binaryMagic)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendValue appends the type and binary encoding of value to buf
+func appendValue(buf []byte, value interface{}, typ rpc.DataType) []byte {
+	buf = append(buf, byte(typ))
+	switch typ {
+	case rpc.DataType_DOUBLE:
+		var tmp [8]byte
+		binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(value.(float64)))
+		return append(buf, tmp[:]...)
+	case rpc.DataType_LONG:
+		return appendVarint(buf, value.(int64))
+	case rpc.DataType_INTEGER:
+		return appendVarint(buf, int64(value.(int32)))
+	case rpc.DataType_BOOLEAN:
+		if value.(bool) {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	default:
+		return appendString(buf, value.(string))
+	}
+}
+
+// binaryEncoder encodes points, keeping the ids of the series written so far
+type binaryEncoder struct {
+	seriesIDs map[string]uint64
+}
+
+func newBinaryEncoder() *binaryEncoder {
+	return &binaryEncoder{seriesIDs: make(map[string]uint64)}
+}
+
+// appendPoint appends the length-prefixed binary record of p to buf.
+// Returns false if all the fields of p are nil, in which case nothing is
+// appended.
+func (e *binaryEncoder) appendPoint(buf []byte, p *data.Point) ([]byte, bool) {
+	device, fakeTags := appendMeasurementAndTags(make([]byte, 0, 256), p)
+
+	n := 0
+	values := make([]byte, 0, 1024)
+	ok := pointValues(p, fakeTags, func(key []byte, v interface{}) {
+		series := string(device) + " " + string(key)
+		id, ok := e.seriesIDs[series]
+		if !ok {
+			id = uint64(len(e.seriesIDs))
+			e.seriesIDs[series] = id
+		}
+		values = appendUvarint(values, id)
+		if !ok {
+			values = appendString(values, string(device))
+			values = appendString(values, string(key))
+		}
+		value, typ := valueAndType(v)
+		values = appendValue(values, value, typ)
+		n++
+	})
+	if !ok {
+		return buf, false
+	}
+
+	body := make([]byte, 0, len(values)+2*binary.MaxVarintLen64)
+	body = appendVarint(body, p.Timestamp().UTC().UnixNano())
+	body = appendUvarint(body, uint64(n))
+	body = append(body, values...)
+	buf = appendUvarint(buf, uint64(len(body)))
+	return append(buf, body...), true
+}
+
+// recordDecoder reads the fields of a single binary record
+type recordDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *recordDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = fmt.Errorf("malformed uvarint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *recordDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = fmt.Errorf("malformed varint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *recordDecoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.b)) < n {
+		d.err = fmt.Errorf("record truncated: want %d bytes, have %d", n, len(d.b))
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+// binaryDecoder decodes records, keeping the paths and tags of the series
+// read so far
+type binaryDecoder struct {
+	devices *deviceCache
+	paths   []string
+	tags    []*seriesTags
+}
+
+func newBinaryDecoder(tmpl *paths.Template) *binaryDecoder {
+	return &binaryDecoder{devices: newDeviceCache(tmpl)}
+}
+
+// define adds the series of a series definition
+func (bd *binaryDecoder) define(device, field string) {
+	d := bd.devices.get(device)
+	bd.paths = append(bd.paths, d.path+"."+paths.Sanitize(field))
+	bd.tags = append(bd.tags, d.tags)
+}
+
+// decodeRecord decodes a binary record body, without its length prefix
+func (bd *binaryDecoder) decodeRecord(body []byte) (*record, error) {
+	d := &recordDecoder{b: body}
+	r := &record{timestamp: d.varint()}
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.b)) {
+		return nil, fmt.Errorf("record claims %d values in %d bytes", n, len(d.b))
+	}
+	r.paths = make([]string, 0, n)
+	r.values = make([]interface{}, 0, n)
+	r.types = make([]rpc.DataType, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		id := d.uvarint()
+		if d.err == nil && id == uint64(len(bd.paths)) {
+			device := string(d.bytes(d.uvarint()))
+			field := string(d.bytes(d.uvarint()))
+			if d.err == nil {
+				bd.define(device, field)
+			}
+		} else if d.err == nil && id > uint64(len(bd.paths)) {
+			d.err = fmt.Errorf("unknown series id %d", id)
+		}
+		typeByte := d.bytes(1)
+		if d.err != nil {
+			break
+		}
+		path := bd.paths[id]
+		if i == 0 {
+			r.tags = bd.tags[id]
+		} else if seriesTagsKey(bd.tags[id]) != seriesTagsKey(r.tags) {
+			d.err = fmt.Errorf("series %s has other tags than the rest of the record", path)
+			break
+		}
+		typ := rpc.DataType(typeByte[0])
+		var value interface{}
+		switch typ {
+		case rpc.DataType_DOUBLE:
+			if b := d.bytes(8); d.err == nil {
+				value = math.Float64frombits(binary.LittleEndian.Uint64(b))
+			}
+		case rpc.DataType_LONG:
+			value = d.varint()
+		case rpc.DataType_INTEGER:
+			value = int32(d.varint())
+		case rpc.DataType_BOOLEAN:
+			if b := d.bytes(1); d.err == nil {
+				value = b[0] != 0
+			}
+		case rpc.DataType_BINARY:
+			value = string(d.bytes(d.uvarint()))
+		default:
+			d.err = fmt.Errorf("unknown data type %d of series %s", typeByte[0], path)
+		}
+		r.paths = append(r.paths, path)
+		r.values = append(r.values, value)
+		r.types = append(r.types, typ)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.b) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after record", len(d.b))
+	}
+	return r, nil
+}
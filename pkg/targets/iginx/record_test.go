@@ -0,0 +1,359 @@
+package iginx
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iznauy/IGinX-client-go/rpc"
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+const testPathTemplate = "{measurement}.{name}.{fleet}.{driver}.{field}"
+
+func mustParsePathTemplate(t *testing.T, s string) *paths.Template {
+	tmpl, err := paths.Parse(s)
+	if err != nil {
+		t.Fatalf("unexpected error parsing path template %s: %v", s, err)
+	}
+	return tmpl
+}
+
+func testPoint() *data.Point {
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("diagnostics"))
+	p.SetTimestamp(&time.Time{})
+	p.AppendTag([]byte("name"), "truck_3")
+	p.AppendTag([]byte("fleet"), "West")
+	p.AppendTag([]byte("load_capacity"), 1500.0)
+	p.AppendField([]byte("status"), int64(4))
+	p.AppendField([]byte("fuel_state"), 0.8)
+	p.AppendField([]byte("ok"), true)
+	p.AppendField([]byte("note"), "a b")
+	p.AppendField([]byte("missing"), nil)
+	return p
+}
+
+func TestRecordFromPointMatchesLineFormat(t *testing.T) {
+	tmpl := mustParsePathTemplate(t, testPathTemplate)
+	p := testPoint()
+	buf := new(bytes.Buffer)
+	if err := (&Serializer{}).Serialize(p, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, err := parseLine(string(bytes.TrimSuffix(buf.Bytes(), newLine)), newDeviceCache(tmpl))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := recordFromPoint(p, tmpl)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect record: got\n%+v\nwant\n%+v", got, want)
+	}
+	if got.paths[0] != "diagnostics.truck_3.West.unknown.load_capacity" {
+		t.Errorf("incorrect path: got %s", got.paths[0])
+	}
+}
+
+func TestRecordFromPointAllNil(t *testing.T) {
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("cpu"))
+	p.SetTimestamp(&time.Time{})
+	p.AppendTag([]byte("hostname"), "host_0")
+	p.AppendTag([]byte("load_capacity"), 1500.0)
+	p.AppendField([]byte("usage_user"), nil)
+	if r := recordFromPoint(p, mustParsePathTemplate(t, "{hostname}.{field}")); r != nil {
+		t.Errorf("expected nil record, got %+v", r)
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	tmpl := mustParsePathTemplate(t, testPathTemplate)
+	cases := []string{
+		"cpu usage_user=1.0",
+		"cpu usage_user=1.0 abc",
+		"cpu usage_user 140",
+		"cpu usage_user=1.0x 140",
+	}
+	for _, line := range cases {
+		if _, err := parseLine(line, newDeviceCache(tmpl)); err == nil {
+			t.Errorf("expected error for line %s", line)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	r := &record{timestamp: 140}
+	cases := []struct {
+		item data.LoadedPoint
+		want int64
+	}{
+		{item: data.NewLoadedPoint([]byte(`cpu,hostname=host_0 usage_user=1.0,note="a b" 140`)), want: 140},
+		{item: data.NewLoadedPoint(r), want: 140},
+	}
+	for _, c := range cases {
+		if got, err := Timestamp(c.item); err != nil || got != c.want {
+			t.Errorf("incorrect timestamp of %v: got %d, %v want %d", c.item.Data, got, err, c.want)
+		}
+	}
+	for _, line := range []string{"cpu", "cpu usage_user=1.0 abc"} {
+		if _, err := Timestamp(data.NewLoadedPoint([]byte(line))); err == nil {
+			t.Errorf("expected error for line %s", line)
+		}
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	tmpl := mustParsePathTemplate(t, testPathTemplate)
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("a"))
+	p.SetTimestamp(&time.Time{})
+	p.AppendTag([]byte("name"), "truck_1")
+	p.AppendField([]byte("d"), -1.5)
+	p.AppendField([]byte("l"), int64(-5000000000))
+	p.AppendField([]byte("i"), int32(7))
+	p.AppendField([]byte("b"), false)
+	p.AppendField([]byte("s"), "x,y z")
+	want := recordFromPoint(p, tmpl)
+
+	e := newBinaryEncoder()
+	var buf []byte
+	// the second record only refers to the series by id
+	for i := 0; i < 2; i++ {
+		var ok bool
+		if buf, ok = e.appendPoint(buf, p); !ok {
+			t.Fatalf("point was not encoded")
+		}
+	}
+
+	ds := &binaryFileDataSource{
+		reader:  bufio.NewReader(bytes.NewReader(buf)),
+		decoder: newBinaryDecoder(tmpl),
+	}
+	for i := 0; i < 2; i++ {
+		got := ds.NextItem().Data.(*record)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("record %d: got\n%+v\nwant\n%+v", i, got, want)
+		}
+	}
+	if p := ds.NextItem(); p.Data != nil {
+		t.Errorf("expected p.Data to be nil, got %v", p.Data)
+	}
+}
+
+func TestFloat32LineMatchesBinary(t *testing.T) {
+	tmpl := mustParsePathTemplate(t, testPathTemplate)
+	p := data.NewPoint()
+	p.SetMeasurementName([]byte("a"))
+	p.SetTimestamp(&time.Time{})
+	p.AppendTag([]byte("name"), "truck_1")
+	p.AppendField([]byte("f"), float32(0.1))
+
+	line := new(bytes.Buffer)
+	if err := (&Serializer{}).Serialize(p, line); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fromLine, err := parseLine(string(bytes.TrimSuffix(line.Bytes(), newLine)), newDeviceCache(tmpl))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	binary := new(bytes.Buffer)
+	if err := (&BinarySerializer{}).Serialize(p, binary); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ds := &binaryFileDataSource{
+		reader:  bufio.NewReader(bytes.NewReader(binary.Bytes()[len(binaryMagic):])),
+		decoder: newBinaryDecoder(tmpl),
+	}
+	fromBinary := ds.NextItem().Data.(*record)
+
+	// both formats store the widened float32
+	if want := float64(float32(0.1)); fromLine.values[0] != want || fromBinary.values[0] != want {
+		t.Errorf("incorrect values: got %v from the line format and %v from the binary format, want %v",
+			fromLine.values[0], fromBinary.values[0], want)
+	}
+}
+
+func TestDecodeRecordErrors(t *testing.T) {
+	tmpl := mustParsePathTemplate(t, testPathTemplate)
+	buf, ok := newBinaryEncoder().appendPoint(nil, testPoint())
+	if !ok {
+		t.Fatalf("point was not encoded")
+	}
+	// skip the length prefix, it fits in a single byte here
+	body := buf[1:]
+	if _, err := newBinaryDecoder(tmpl).decodeRecord(body[:len(body)-1]); err == nil {
+		t.Errorf("expected error for truncated record")
+	}
+	if _, err := newBinaryDecoder(tmpl).decodeRecord(append(body, 0)); err == nil {
+		t.Errorf("expected error for trailing bytes")
+	}
+	// a series id that was never defined
+	if _, err := newBinaryDecoder(tmpl).decodeRecord([]byte{0, 1, 5}); err == nil {
+		t.Errorf("expected error for unknown series id")
+	}
+}
+
+func TestBinarySerializer(t *testing.T) {
+	s := &BinarySerializer{}
+	buf := new(bytes.Buffer)
+	empty := data.NewPoint()
+	empty.SetMeasurementName([]byte("cpu"))
+	empty.SetTimestamp(&time.Time{})
+	empty.AppendField([]byte("usage_user"), nil)
+	for _, p := range []*data.Point{empty, testPoint(), testPoint()} {
+		if err := s.Serialize(p, buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if !isBinaryFormat(buf.Bytes()) {
+		t.Fatalf("output does not start with the binary format magic")
+	}
+	if bytes.Count(buf.Bytes(), binaryMagic) != 1 {
+		t.Errorf("binary format magic written more than once")
+	}
+
+	tmpl := mustParsePathTemplate(t, testPathTemplate)
+	br := bufio.NewReader(buf)
+	br.Discard(len(binaryMagic))
+	ds := &binaryFileDataSource{reader: br, decoder: newBinaryDecoder(tmpl)}
+	want := recordFromPoint(testPoint(), tmpl)
+	for i := 0; i < 2; i++ {
+		p := ds.NextItem()
+		if !reflect.DeepEqual(p.Data, want) {
+			t.Errorf("record %d: got\n%+v\nwant\n%+v", i, p.Data, want)
+		}
+	}
+}
+
+func TestColumnBatch(t *testing.T) {
+	b := (&columnFactory{}).New().(*columnBatch)
+	b.Append(data.NewLoadedPoint(&record{
+		timestamp: 10,
+		paths:     []string{"a.x", "a.y"},
+		values:    []interface{}{1.0, int64(2)},
+		types:     []rpc.DataType{rpc.DataType_DOUBLE, rpc.DataType_LONG},
+	}))
+	b.Append(data.NewLoadedPoint(&record{
+		timestamp: 20,
+		paths:     []string{"a.x"},
+		values:    []interface{}{3.0},
+		types:     []rpc.DataType{rpc.DataType_DOUBLE},
+	}))
+	b.Append(data.NewLoadedPoint(&record{
+		timestamp: 10,
+		paths:     []string{"b.x"},
+		values:    []interface{}{4.0},
+		types:     []rpc.DataType{rpc.DataType_DOUBLE},
+	}))
+	if b.Len() != 3 || b.metrics != 4 {
+		t.Errorf("incorrect counts: got %d rows %d metrics", b.Len(), b.metrics)
+	}
+	if len(b.groups) != 1 {
+		t.Fatalf("incorrect number of groups: got %d", len(b.groups))
+	}
+	g := b.groups[0]
+	if want := []string{"a.x", "a.y", "b.x"}; !reflect.DeepEqual(g.paths, want) {
+		t.Errorf("incorrect paths: got %v want %v", g.paths, want)
+	}
+	if want := []int64{10, 20}; !reflect.DeepEqual(g.timestamps, want) {
+		t.Errorf("incorrect timestamps: got %v want %v", g.timestamps, want)
+	}
+	if g.tagsList() != nil {
+		t.Errorf("untagged series have tags: %v", g.tagsList())
+	}
+	want := [][]interface{}{{1.0, 3.0}, {int64(2), nil}, {4.0, nil}}
+	if got := g.valueList(); !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect values: got %v want %v", got, want)
+	}
+
+	fatalCalled := false
+	oldFatal := fatal
+	defer func() { fatal = oldFatal }()
+	fatal = func(format string, args ...interface{}) {
+		fatalCalled = true
+	}
+	b.Append(data.NewLoadedPoint(&record{
+		timestamp: 30,
+		paths:     []string{"a.x"},
+		values:    []interface{}{true},
+		types:     []rpc.DataType{rpc.DataType_BOOLEAN},
+	}))
+	if !fatalCalled {
+		t.Errorf("fatal was not called for mixed types")
+	}
+}
+
+func TestTaggedRecords(t *testing.T) {
+	tmpl, err := paths.ParseTagged("{measurement}.{fleet}.{field}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := testPoint()
+	want := map[string]string{"name": "truck_3"}
+
+	got := recordFromPoint(p, tmpl)
+	if got.paths[0] != "diagnostics.West.load_capacity" {
+		t.Errorf("incorrect path: got %s", got.paths[0])
+	}
+	if got.tags == nil || !reflect.DeepEqual(got.tags.tags, want) {
+		t.Fatalf("incorrect tags: got %+v want %v", got.tags, want)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := (&Serializer{}).Serialize(p, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fromLine, err := parseLine(string(bytes.TrimSuffix(buf.Bytes(), newLine)), newDeviceCache(tmpl))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fromLine, got) {
+		t.Errorf("incorrect record from line: got\n%+v\nwant\n%+v", fromLine, got)
+	}
+
+	encoded, ok := newBinaryEncoder().appendPoint(nil, p)
+	if !ok {
+		t.Fatalf("point was not encoded")
+	}
+	ds := &binaryFileDataSource{
+		reader:  bufio.NewReader(bytes.NewReader(encoded)),
+		decoder: newBinaryDecoder(tmpl),
+	}
+	if fromBinary := ds.NextItem().Data.(*record); !reflect.DeepEqual(fromBinary, got) {
+		t.Errorf("incorrect record from binary: got\n%+v\nwant\n%+v", fromBinary, got)
+	}
+}
+
+func TestColumnBatchGroupsTags(t *testing.T) {
+	b := (&columnFactory{}).New().(*columnBatch)
+	for i, name := range []string{"truck_1", "truck_2", "truck_1"} {
+		b.Append(data.NewLoadedPoint(&record{
+			timestamp: int64(10 * (i + 1)),
+			paths:     []string{"readings.velocity", "readings.heading"},
+			values:    []interface{}{float64(i), float64(i)},
+			types:     []rpc.DataType{rpc.DataType_DOUBLE, rpc.DataType_DOUBLE},
+			tags:      newSeriesTags(map[string]string{"name": name}),
+		}))
+	}
+	if b.Len() != 3 || b.metrics != 6 {
+		t.Errorf("incorrect counts: got %d rows %d metrics", b.Len(), b.metrics)
+	}
+	if len(b.groups) != 2 {
+		t.Fatalf("incorrect number of groups: got %d", len(b.groups))
+	}
+	g := b.groups[0]
+	if want := []int64{10, 30}; !reflect.DeepEqual(g.timestamps, want) {
+		t.Errorf("incorrect timestamps: got %v want %v", g.timestamps, want)
+	}
+	wantTags := []map[string]string{{"name": "truck_1"}, {"name": "truck_1"}}
+	if got := g.tagsList(); !reflect.DeepEqual(got, wantTags) {
+		t.Errorf("incorrect tags: got %v want %v", got, wantTags)
+	}
+	if got := b.groups[1].tagsList(); got[0]["name"] != "truck_2" {
+		t.Errorf("incorrect tags of the second group: got %v", got)
+	}
+}
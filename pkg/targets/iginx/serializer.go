@@ -6,38 +6,22 @@ import (
 	"io"
 )
 
-// Serializer writes a Point in a serialized form for MongoDB
+// Serializer writes a Point in a serialized form for IGinX
 type Serializer struct{}
 
 // Serialize writes Point data to the given writer, conforming to the
-// InfluxDB wire protocol.
+// InfluxDB wire protocol, with field values typed as described by
+// appendFieldValue.
 //
 // This function writes output that looks like:
 // <measurement>,<tag key>=<tag value> <field name>=<field value> <timestamp>\n
 //
 // For example:
-// foo,tag0=bar baz=-1.0 100\n
+// foo,tag0=bar baz=-1.0,qux=7i 100\n
 func (s *Serializer) Serialize(p *data.Point, w io.Writer) (err error) {
-	buf := make([]byte, 0, 1024)
-	buf = append(buf, p.MeasurementName()...)
-
-	fakeTags := make([]int, 0)
+	buf, fakeTags := appendMeasurementAndTags(make([]byte, 0, 1024), p)
 	tagKeys := p.TagKeys()
 	tagValues := p.TagValues()
-	for i := 0; i < len(tagKeys); i++ {
-		if tagValues[i] == nil {
-			continue
-		}
-		switch v := tagValues[i].(type) {
-		case string:
-			buf = append(buf, ',')
-			buf = append(buf, tagKeys[i]...)
-			buf = append(buf, '=')
-			buf = append(buf, []byte(v)...)
-		default:
-			fakeTags = append(fakeTags, i)
-		}
-	}
 	fieldKeys := p.FieldKeys()
 	if len(fakeTags) > 0 || len(fieldKeys) > 0 {
 		buf = append(buf, ' ')
@@ -83,9 +67,61 @@ func appendField(buf, key []byte, v interface{}) []byte {
 	buf = append(buf, key...)
 	buf = append(buf, '=')
 
-	buf = serialize.FastFormatAppend(v, buf)
+	return appendFieldValue(buf, v)
+}
 
-	// Influx uses 'i' to indicate integers:
+// appendMeasurementAndTags appends the measurement name of p and its string
+// tags to buf. The indices of the tags that are not strings, and are thus
+// written as fields, are returned alongside.
+func appendMeasurementAndTags(buf []byte, p *data.Point) ([]byte, []int) {
+	buf = append(buf, p.MeasurementName()...)
 
-	return buf
+	fakeTags := make([]int, 0)
+	tagKeys := p.TagKeys()
+	tagValues := p.TagValues()
+	for i := 0; i < len(tagKeys); i++ {
+		if tagValues[i] == nil {
+			continue
+		}
+		switch v := tagValues[i].(type) {
+		case string:
+			buf = append(buf, ',')
+			buf = append(buf, tagKeys[i]...)
+			buf = append(buf, '=')
+			buf = append(buf, []byte(v)...)
+		default:
+			fakeTags = append(fakeTags, i)
+		}
+	}
+	return buf, fakeTags
+}
+
+// BinarySerializer writes a Point in the binary IGinX format, with the values
+// kept in their binary representation and each series defined only once, so
+// the loader can build column batches without parsing any text
+type BinarySerializer struct {
+	encoder *binaryEncoder
+}
+
+// Serialize writes the binary record of p to w, preceded by the format magic
+// if it is the first record written by this serializer. Series are defined
+// only the first time, so all the output must go to the same stream. Points without any
+// non-nil field are skipped.
+func (s *BinarySerializer) Serialize(p *data.Point, w io.Writer) error {
+	buf := make([]byte, 0, 1024)
+	first := s.encoder == nil
+	if first {
+		s.encoder = newBinaryEncoder()
+		buf = append(buf, binaryMagic...)
+	}
+	buf, ok := s.encoder.appendPoint(buf, p)
+	if !ok {
+		if first {
+			// the magic goes with the first record actually written
+			s.encoder = nil
+		}
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
 }
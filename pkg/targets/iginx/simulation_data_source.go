@@ -0,0 +1,49 @@
+package iginx
+
+import (
+	"github.com/timescale/tsbs/pkg/data"
+	"github.com/timescale/tsbs/pkg/data/usecases/common"
+	"github.com/timescale/tsbs/pkg/targets"
+	"github.com/timescale/tsbs/pkg/targets/iginx/paths"
+)
+
+func newSimulationDataSource(sim common.Simulator, tmpl *paths.Template) targets.DataSource {
+	return &simulationDataSource{
+		simulator:    sim,
+		headers:      sim.Headers(),
+		pathTemplate: tmpl,
+	}
+}
+
+// simulationDataSource generates points with a simulator and converts them
+// to records directly, the same as the binary file data source returns
+type simulationDataSource struct {
+	simulator    common.Simulator
+	headers      *common.GeneratedDataHeaders
+	pathTemplate *paths.Template
+}
+
+func (d *simulationDataSource) Headers() *common.GeneratedDataHeaders {
+	if d.headers != nil {
+		return d.headers
+	}
+
+	d.headers = d.simulator.Headers()
+	return d.headers
+}
+
+func (d *simulationDataSource) NextItem() data.LoadedPoint {
+	newSimulatorPoint := data.NewPoint()
+	for !d.simulator.Finished() {
+		if !d.simulator.Next(newSimulatorPoint) {
+			newSimulatorPoint.Reset()
+			continue
+		}
+		// points without any non-nil field are skipped
+		if r := recordFromPoint(newSimulatorPoint, d.pathTemplate); r != nil {
+			return data.NewLoadedPoint(r)
+		}
+		newSimulatorPoint.Reset()
+	}
+	return data.LoadedPoint{}
+}
@@ -0,0 +1,54 @@
+// Package udfs lists the Python UDFs of IGinX called by the generated
+// queries and builds the statements registering them
+package udfs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ShowStatement lists the registered UDFs, a row per UDF starting with its
+// name
+const ShowStatement = "SHOW REGISTER PYTHON TASK"
+
+// UDF is a Python UDF registered in IGinX. The file is read by the IGinX
+// node.
+type UDF struct {
+	Type  string `yaml:"type"`
+	Name  string `yaml:"name"`
+	Class string `yaml:"class"`
+	File  string `yaml:"file"`
+}
+
+// RegisterStatement returns the statement registering the UDF
+func (u UDF) RegisterStatement() string {
+	return fmt.Sprintf(`REGISTER %s PYTHON TASK "%s" IN "%s" AS "%s"`,
+		strings.ToUpper(u.Type), u.Class, u.File, u.Name)
+}
+
+// DropStatement returns the statement dropping the UDF
+func (u UDF) DropStatement() string {
+	return fmt.Sprintf(`DROP PYTHON TASK "%s"`, u.Name)
+}
+
+// Queries are the UDFs the generated queries call, by name. Their files are
+// relative to the iginx_py_udfs directory of the repository.
+var Queries = map[string]UDF{
+	"device_rows":      {Type: "udsf", Name: "device_rows", Class: "UDSFDeviceRows", File: "udsf_iot.py"},
+	"driving_sessions": {Type: "udsf", Name: "driving_sessions", Class: "UDSFDrivingSessions", File: "udsf_iot.py"},
+	"breakdowns":       {Type: "udsf", Name: "breakdowns", Class: "UDSFBreakdowns", File: "udsf_iot.py"},
+}
+
+// Called returns the names of the UDFs of Queries called by a statement,
+// sorted
+func Called(statement string) []string {
+	var names []string
+	for name := range Queries {
+		if strings.Contains(statement, name+"(") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
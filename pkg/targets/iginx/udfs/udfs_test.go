@@ -0,0 +1,39 @@
+package udfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalled(t *testing.T) {
+	cases := []struct {
+		statement string
+		want      []string
+	}{
+		{
+			statement: "SELECT max(usage_user) FROM cpu",
+			want:      nil,
+		},
+		{
+			statement: "SELECT level4 AS model, sum(breakdowns) AS total_breakdowns FROM (SELECT breakdowns(status) FROM diagnostics.*.*.*.*.*) GROUP BY level4",
+			want:      []string{"breakdowns"},
+		},
+		{
+			statement: "SELECT * FROM (SELECT driving_sessions(*) FROM readings), (SELECT device_rows(*) FROM diagnostics)",
+			want:      []string{"device_rows", "driving_sessions"},
+		},
+	}
+	for _, c := range cases {
+		if got := Called(c.statement); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("incorrect UDFs of %s: got %v want %v", c.statement, got, c.want)
+		}
+	}
+}
+
+func TestRegisterStatement(t *testing.T) {
+	u := UDF{Type: "udsf", Name: "breakdowns", Class: "UDSFBreakdowns", File: "/udfs/udsf_iot.py"}
+	want := `REGISTER UDSF PYTHON TASK "UDSFBreakdowns" IN "/udfs/udsf_iot.py" AS "breakdowns"`
+	if got := u.RegisterStatement(); got != want {
+		t.Errorf("incorrect statement: got %s want %s", got, want)
+	}
+}
@@ -48,6 +48,8 @@ func GetTarget(format string) targets.ImplementedTarget {
 		return questdb.NewTarget()
 	case constants.FormatIginx:
 		return iginx.NewTarget()
+	case constants.FormatIginxBinary:
+		return iginx.NewBinaryTarget()
 	}
 
 	supportedFormatsStr := strings.Join(constants.SupportedFormats(), ",")
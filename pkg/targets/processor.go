@@ -14,3 +14,19 @@ type ProcessorCloser interface {
 	// Close cleans up after a Processor
 	Close(doLoad bool)
 }
+
+// WriteFailures counts the data a Processor failed to write, along with the
+// writes it retried
+type WriteFailures struct {
+	Batches uint64
+	Metrics uint64
+	Rows    uint64
+	Retries uint64
+}
+
+// ProcessorFailureCounter is a Processor that also counts its write failures
+type ProcessorFailureCounter interface {
+	Processor
+	// Failures returns the write failures since the previous call
+	Failures() WriteFailures
+}
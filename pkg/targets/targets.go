@@ -71,6 +71,24 @@ type Benchmark interface {
 	GetDBCreator() DBCreator
 }
 
+// BenchmarkSummarizer is a Benchmark that also has statistics of its own to
+// print after the summary of the load, e.g. per database node
+type BenchmarkSummarizer interface {
+	Benchmark
+	// Summary returns the statistics to print, possibly on several lines
+	Summary() string
+}
+
+// FileSplitter is a Benchmark reading its data from several files that can
+// also read each of them on its own, so that with hashed workers every file
+// is loaded by a single worker
+type FileSplitter interface {
+	Benchmark
+	// FileDataSources returns a DataSource per file, in order, or nil if
+	// the data is not read from several files
+	FileDataSources() []DataSource
+}
+
 type DataSource interface {
 	NextItem() data.LoadedPoint
 	Headers() *common.GeneratedDataHeaders
@@ -2,6 +2,7 @@ package timescaledb
 
 import (
 	"github.com/timescale/tsbs/internal/inputs"
+	"github.com/timescale/tsbs/load"
 	"github.com/timescale/tsbs/pkg/data/source"
 	"github.com/timescale/tsbs/pkg/targets"
 )
@@ -11,8 +12,10 @@ const pqDriver = "postgres"
 
 func NewBenchmark(dbName string, opts *LoadingOptions, dataSourceConfig *source.DataSourceConfig) (targets.Benchmark, error) {
 	var ds targets.DataSource
+	var fileName string
 	if dataSourceConfig.Type == source.FileDataSourceType {
-		ds = newFileDataSource(dataSourceConfig.File.Location)
+		fileName = dataSourceConfig.File.Location
+		ds = newFileDataSource(fileName)
 	} else {
 		dataGenerator := &inputs.DataGenerator{}
 		simulator, err := dataGenerator.CreateSimulator(dataSourceConfig.Simulator)
@@ -23,22 +26,42 @@ func NewBenchmark(dbName string, opts *LoadingOptions, dataSourceConfig *source.
 	}
 
 	return &benchmark{
-		opts:   opts,
-		ds:     ds,
-		dbName: dbName,
+		opts:     opts,
+		fileName: fileName,
+		ds:       ds,
+		dbName:   dbName,
 	}, nil
 }
 
 type benchmark struct {
-	opts   *LoadingOptions
-	ds     targets.DataSource
-	dbName string
+	opts     *LoadingOptions
+	fileName string
+	ds       targets.DataSource
+	dbName   string
 }
 
 func (b *benchmark) GetDataSource() targets.DataSource {
 	return b.ds
 }
 
+// FileDataSources returns a data source per data file, with its headers
+// read, for hashed workers to load each file with a single worker
+func (b *benchmark) FileDataSources() []targets.DataSource {
+	if b.fileName == "" {
+		return nil
+	}
+	fileNames, err := load.FileNames(b.fileName)
+	if err != nil || len(fileNames) < 2 {
+		return nil
+	}
+	sources := make([]targets.DataSource, len(fileNames))
+	for i, fileName := range fileNames {
+		sources[i] = newFileDataSource(fileName)
+		sources[i].Headers()
+	}
+	return sources
+}
+
 func (b *benchmark) GetBatchFactory() targets.BatchFactory {
 	return &factory{}
 }